@@ -0,0 +1,297 @@
+package assignments
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/pastgames"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+type errAssignmentClosed struct{}
+
+func (errAssignmentClosed) Error() string {
+	return "assignment is closed"
+}
+
+type errUsernameTaken struct{}
+
+func (errUsernameTaken) Error() string {
+	return "username is already taken"
+}
+
+// Assignment is a quiz played without a live host
+// Every player gets their own single player game and progresses through the questions at their own pace,
+// the results of all attempts are collected into a single past game once the assignment closes
+type Assignment struct {
+	mu         sync.Mutex
+	ID         string // Token used in the shareable link
+	Owner      common.ClientID
+	Quiz       quiz.Quiz
+	Settings   game.RoundSettings
+	CreatedAt  time.Time
+	Deadline   time.Time
+	closedAt   time.Time
+	pastGameID int64
+	clock      game.Clock
+	attempts   map[common.ClientID]*Attempt
+}
+
+// NewAssignment creates an assignment that is timed using the clock, a nil clock uses game.SystemClock
+func NewAssignment(owner common.ClientID, q quiz.Quiz, settings game.RoundSettings, deadline time.Time, clock game.Clock) (*Assignment, error) {
+	if clock == nil {
+		clock = game.SystemClock
+	}
+
+	if q.QuestionsCount() == 0 {
+		return nil, errors.New("Quiz has no questions")
+	}
+
+	now := clock.Now()
+	if !deadline.After(now) {
+		return nil, errors.New("Deadline has to be in the future")
+	}
+
+	return &Assignment{
+		Owner:     owner,
+		Quiz:      q,
+		Settings:  settings,
+		CreatedAt: now,
+		Deadline:  deadline,
+		clock:     clock,
+		attempts:  make(map[common.ClientID]*Attempt),
+	}, nil
+}
+
+// Attempt is the progress of a single player through the assignment
+type Attempt struct {
+	Username game.Username
+	game.Game
+	earlierPoints int // Points collected before the attempt was restored from the repository
+}
+
+// Score returns the points collected in the attempt so far
+func (a *Attempt) Score() int {
+	return a.earlierPoints + a.Scores()[a.Username]
+}
+
+// Answer submits the answer to the current question
+func (a *Attempt) Answer(answerIdx int) error {
+	return a.SubmitAnswer(a.Username, answerIdx)
+}
+
+// Next starts the next question, the attempt is finished after the last one
+func (a *Attempt) Next() error {
+	err := a.StartNextRound()
+	if _, ok := err.(game.ErrNoMoreQuestions); ok {
+		return a.Finish()
+	}
+	return err
+}
+
+// QuestionsDone returns the number of questions the player has finished
+func (a *Attempt) QuestionsDone() int {
+	if a.IsFinished() {
+		return a.QuestionsCount()
+	}
+	if a.InRound() {
+		return a.RoundNum()
+	}
+	return a.RoundNum() + 1
+}
+
+// IsClosed reports whether the assignment no longer accepts answers
+func (a *Assignment) IsClosed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.isClosed()
+}
+
+// Thread unsafe
+func (a *Assignment) isClosed() bool {
+	return !a.closedAt.IsZero() || !a.clock.Now().Before(a.Deadline)
+}
+
+// PastGameID returns the ID of the past game with the results, 0 until the results are stored
+func (a *Assignment) PastGameID() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pastGameID
+}
+
+// Join starts an attempt of the client, returns the existing attempt if the client has already joined
+func (a *Assignment) Join(clientID common.ClientID, username game.Username) (*Attempt, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if attempt, ok := a.attempts[clientID]; ok {
+		return attempt, nil
+	}
+
+	if a.isClosed() {
+		return nil, errAssignmentClosed{}
+	}
+
+	for _, attempt := range a.attempts {
+		if attempt.Username == username {
+			return nil, errUsernameTaken{}
+		}
+	}
+
+	attempt, err := a.newAttempt(username)
+	if err != nil {
+		return nil, err
+	}
+
+	a.attempts[clientID] = attempt
+	return attempt, nil
+}
+
+// newAttempt starts an attempt at the first question
+func (a *Assignment) newAttempt(username game.Username) (*Attempt, error) {
+	attempt := &Attempt{
+		Username: username,
+		Game: game.CreateGame(game.GameSettings{
+			Quiz:          a.Quiz,
+			RoundSettings: a.Settings,
+		}, a.clock),
+	}
+	if err := attempt.AddPlayer(username); err != nil {
+		return nil, err
+	}
+	if err := attempt.Start(); err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// restoreAttempt recreates a stored attempt of the client
+// It continues after the questions already done, a question in progress when the attempt was stored starts over
+// Thread unsafe, meant for assignments that aren't shared yet
+func (a *Assignment) restoreAttempt(clientID common.ClientID, username game.Username, score, questionsDone int, finished bool) error {
+	attempt, err := a.newAttempt(username)
+	if err != nil {
+		return err
+	}
+	attempt.earlierPoints = score
+
+	if finished || questionsDone > 0 {
+		if err := attempt.FinishRoundEarly(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case finished || questionsDone >= attempt.QuestionsCount():
+		if err := attempt.Finish(); err != nil {
+			return err
+		}
+	case questionsDone > 0:
+		if err := attempt.StartRound(questionsDone); err != nil {
+			return err
+		}
+	}
+
+	a.attempts[clientID] = attempt
+	return nil
+}
+
+// Attempt returns the attempt of the client
+func (a *Assignment) Attempt(clientID common.ClientID) (*Attempt, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attempt, ok := a.attempts[clientID]
+	return attempt, ok
+}
+
+// Attempts returns all attempts sorted by score, descending
+func (a *Assignment) Attempts() []*Attempt {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sortedAttempts()
+}
+
+// Thread unsafe
+func (a *Assignment) sortedAttempts() []*Attempt {
+	attempts := make([]*Attempt, 0, len(a.attempts))
+	for _, attempt := range a.attempts {
+		attempts = append(attempts, attempt)
+	}
+	slices.SortFunc(attempts, func(x, y *Attempt) int {
+		if diff := y.Score() - x.Score(); diff != 0 {
+			return diff
+		}
+		return compareUsernames(x.Username, y.Username)
+	})
+	return attempts
+}
+
+func compareUsernames(x, y game.Username) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Close stores the results of the assignment as a past game using store and closes the assignment
+// The assignment is closed only if store succeeds, it returns the ID of the past game returned by store
+// Unfinished attempts are stopped and scored with the points collected so far
+// store is called with the assignment locked, so it must not call its methods
+func (a *Assignment) Close(store func(pastgames.PastGame) (int64, error)) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.closedAt.IsZero() {
+		return 0, errAssignmentClosed{}
+	}
+	closedAt := a.clock.Now()
+
+	// A player is alone in the round, so it finishes as soon as they answer
+	// and the points collected so far don't change by stopping the attempt
+	attempts := a.sortedAttempts()
+	scores := make([]pastgames.PlayerScore, len(attempts))
+	for i, attempt := range attempts {
+		scores[i] = pastgames.PlayerScore{
+			Username: string(attempt.Username),
+			Score:    attempt.Score(),
+		}
+	}
+
+	id, err := store(pastgames.PastGame{
+		StartedAt:    a.CreatedAt,
+		EndedAt:      closedAt,
+		QuizTitle:    a.Quiz.Title(),
+		QuizID:       a.Quiz.ID,
+		QuizRevision: a.Quiz.Revision,
+		Questions:    a.Quiz.QuestionsCount(),
+		Scores:       scores,
+	})
+	if err != nil {
+		return 0, err
+	}
+	a.closedAt = closedAt
+	a.pastGameID = id
+
+	for _, attempt := range a.attempts {
+		if attempt.InRound() {
+			if err := attempt.FinishRoundEarly(); err != nil && !errors.Is(err, game.ErrRoundAlreadyEnded) {
+				slog.Error("Error stopping attempt", "id", a.ID, "username", attempt.Username, "err", err)
+			}
+		}
+		if err := attempt.Finish(); err != nil {
+			if _, ok := err.(game.ErrGameFinished); !ok {
+				slog.Error("Error stopping attempt", "id", a.ID, "username", attempt.Username, "err", err)
+			}
+		}
+	}
+
+	return id, nil
+}
@@ -0,0 +1,166 @@
+package assignments
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/pastgames"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+func newTestAssignment(t *testing.T) (*Assignment, *game.FakeClock) {
+	t.Helper()
+	clock := game.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	settings := game.RoundSettings{AnswerTime: 10 * time.Second}
+	a, err := NewAssignment("owner", quiz.ExampleQuizMath, settings, clock.Now().Add(time.Hour), clock)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return a, clock
+}
+
+func TestNewAssignmentDeadline(t *testing.T) {
+	clock := game.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	_, err := NewAssignment("owner", quiz.ExampleQuizMath, game.RoundSettings{}, clock.Now(), clock)
+	if err == nil {
+		t.Fatal("Expected error for a deadline that isn't in the future")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	a, _ := newTestAssignment(t)
+
+	alice, err := a.Join("client-1", "Alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Joining again continues the same attempt
+	again, err := a.Join("client-1", "Alice2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again != alice {
+		t.Error("Expected the existing attempt to be returned")
+	}
+
+	if _, err := a.Join("client-2", "Alice"); err != (errUsernameTaken{}) {
+		t.Errorf("Expected errUsernameTaken, got %v", err)
+	}
+
+	if _, err := a.Join("client-2", ""); err == nil {
+		t.Error("Expected error for an empty username")
+	}
+
+	if !alice.InRound() || alice.RoundNum() != 0 {
+		t.Error("Expected the attempt to start at the first question")
+	}
+}
+
+func TestSelfPacedAttempts(t *testing.T) {
+	a, clock := newTestAssignment(t)
+
+	alice, err := a.Join("client-1", "Alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bob, err := a.Join("client-2", "Bob")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Bob answers the first question wrong and moves on, the others are not waited for
+	if err := bob.Answer(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := bob.Next(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Alice answers both questions correctly, the second one after 2 seconds
+	if err := alice.Answer(0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if alice.Score() != 1000 {
+		t.Errorf("Expected score 1000 right after answering, got %d", alice.Score())
+	}
+	if err := alice.Next(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(2 * time.Second)
+	if err := alice.Answer(0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := alice.Next(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !alice.IsFinished() {
+		t.Error("Expected the attempt to finish after the last question")
+	}
+	if done := alice.QuestionsDone(); done != 2 {
+		t.Errorf("Expected 2 questions done, got %d", done)
+	}
+	if done := bob.QuestionsDone(); done != 1 {
+		t.Errorf("Expected 1 question done, got %d", done)
+	}
+
+	// The assignment stays open if the results can't be stored
+	if _, err := a.Close(func(pastgames.PastGame) (int64, error) { return 0, errors.New("disk full") }); err == nil {
+		t.Fatal("Expected the error of storing the results")
+	}
+	if a.IsClosed() || bob.IsFinished() {
+		t.Fatal("Expected the assignment to stay open")
+	}
+
+	var pastGame pastgames.PastGame
+	id, err := a.Close(func(pg pastgames.PastGame) (int64, error) {
+		pastGame = pg
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if id != 42 || a.PastGameID() != 42 {
+		t.Errorf("Expected the ID of the stored past game, got %d", id)
+	}
+
+	expected := []pastgames.PlayerScore{
+		{Username: "Alice", Score: 1900},
+		{Username: "Bob", Score: 0},
+	}
+	if !slices.Equal(pastGame.Scores, expected) {
+		t.Errorf("Expected scores %v, got %v", expected, pastGame.Scores)
+	}
+	if pastGame.QuizID != quiz.ExampleQuizMath.ID || pastGame.QuizTitle != quiz.ExampleQuizMath.Title() {
+		t.Errorf("Expected the past game to reference the quiz, got %+v", pastGame)
+	}
+	if !bob.IsFinished() {
+		t.Error("Expected unfinished attempts to be stopped when closing")
+	}
+
+	if _, err := a.Close(func(pastgames.PastGame) (int64, error) { return 43, nil }); err != (errAssignmentClosed{}) {
+		t.Errorf("Expected errAssignmentClosed, got %v", err)
+	}
+	if _, err := a.Join("client-3", "Carol"); err != (errAssignmentClosed{}) {
+		t.Errorf("Expected errAssignmentClosed, got %v", err)
+	}
+}
+
+func TestClosesAtDeadline(t *testing.T) {
+	a, clock := newTestAssignment(t)
+
+	if a.IsClosed() {
+		t.Fatal("Expected the assignment to be open before the deadline")
+	}
+
+	clock.Advance(time.Hour)
+	if !a.IsClosed() {
+		t.Fatal("Expected the assignment to be closed at the deadline")
+	}
+	if _, err := a.Join("client-1", "Alice"); err != (errAssignmentClosed{}) {
+		t.Errorf("Expected errAssignmentClosed, got %v", err)
+	}
+}
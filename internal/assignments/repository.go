@@ -0,0 +1,25 @@
+package assignments
+
+import (
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+)
+
+type errAssignmentNotFound struct{}
+
+func (errAssignmentNotFound) Error() string {
+	return "assignment not found"
+}
+
+type Repository interface {
+	AddAssignment(*Assignment) error
+	GetAssignment(id string) (*Assignment, error)
+	GetAssignmentsByOwner(common.ClientID) ([]*Assignment, error)
+	// GetOpenAssignments returns the assignments that weren't closed yet, including those past their deadline
+	GetOpenAssignments() ([]*Assignment, error)
+	// SaveAttempt stores the progress of the attempt of the client
+	SaveAttempt(assignmentID string, clientID common.ClientID, attempt *Attempt) error
+	// SaveClosed stores when the assignment was closed and the ID of the past game with its results
+	SaveClosed(assignmentID string, closedAt time.Time, pastGameID int64) error
+}
@@ -0,0 +1,380 @@
+package assignments
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+// Layout of the datetime-local input used for the deadline
+// The deadline is entered in UTC, the server and the players can be in different time zones
+const deadlineLayout = "2006-01-02T15:04"
+
+// Returns a handler for routes starting with /assignments
+func (s Service) NewAssignmentsRouter() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /assignments/{$}", s.getAssignmentsHandler)
+	mux.HandleFunc("POST /assignments/{$}", s.postAssignmentsHandler)
+	mux.HandleFunc("GET /assignments/{id}", s.getAssignmentHandler)
+	mux.HandleFunc("POST /assignments/{id}/join", s.postJoinHandler)
+	mux.HandleFunc("GET /assignments/{id}/play", s.getPlayHandler)
+	mux.HandleFunc("POST /assignments/{id}/answer", s.postAnswerHandler)
+	mux.HandleFunc("POST /assignments/{id}/next", s.postNextHandler)
+	mux.HandleFunc("GET /assignments/{id}/results", s.getResultsHandler)
+	mux.HandleFunc("POST /assignments/{id}/close", s.postCloseHandler)
+
+	return mux
+}
+
+// getAssignmentsHandler shows the form for creating an assignment and the assignments of the client
+func (s Service) getAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	assignments, err := s.aRepo.GetAssignmentsByOwner(clientID)
+	if err != nil {
+		slog.Error("Error getting assignments", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	quizzes, err := s.qRepo.GetAllQuizzesMetadata()
+	if err != nil {
+		slog.Error("Error getting quizzes", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	// The quiz can be preselected, e.g. when coming from the quiz page
+	quizID, _ := strconv.ParseInt(r.URL.Query().Get("quiz"), 10, 64)
+
+	data := AssignmentsData{
+		Assignments: assignments,
+		CreateFormData: CreateFormData{
+			Quizzes:     quizzes,
+			QuizID:      quizID,
+			ReadingTime: 5,
+			AnswerTime:  30,
+			Deadline:    time.Now().UTC().Add(7 * 24 * time.Hour).Format(deadlineLayout),
+		},
+	}
+
+	if err := AssignmentsTmpl.Execute(w, data); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+func (s Service) postAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := CreateFormData{
+		Deadline: r.FormValue("deadline"),
+	}
+	data.QuizID, _ = strconv.ParseInt(r.FormValue("quiz"), 10, 64)
+	data.ReadingTime, _ = strconv.Atoi(r.FormValue("time-for-reading"))
+	data.AnswerTime, _ = strconv.Atoi(r.FormValue("time-per-question"))
+
+	renderError := func(msg string) {
+		data.Quizzes, err = s.qRepo.GetAllQuizzesMetadata()
+		if err != nil {
+			slog.Error("Error getting quizzes", "err", err)
+		}
+		data.Error = msg
+		if err := CreateFormTmpl.Execute(w, data); err != nil {
+			slog.Error("Error rendering template", "err", err)
+		}
+	}
+
+	if data.ReadingTime < 0 || data.AnswerTime <= 0 {
+		renderError("Reading time can't be negative and answer time has to be positive")
+		return
+	}
+
+	deadline, err := time.ParseInLocation(deadlineLayout, data.Deadline, time.UTC)
+	if err != nil {
+		renderError("Invalid deadline")
+		return
+	}
+
+	q, err := s.qRepo.Get(data.QuizID)
+	if err != nil {
+		slog.Error("Error getting quiz", "quizID", data.QuizID, "err", err)
+		renderError("Choose a quiz")
+		return
+	}
+
+	settings := game.RoundSettings{
+		ReadingTime: time.Duration(data.ReadingTime) * time.Second,
+		AnswerTime:  time.Duration(data.AnswerTime) * time.Second,
+	}
+	assignment, err := NewAssignment(clientID, *q, settings, deadline, game.SystemClock)
+	if err != nil {
+		renderError(err.Error())
+		return
+	}
+
+	if err := s.aRepo.AddAssignment(assignment); err != nil {
+		slog.Error("Error adding assignment", "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.scheduleClose(assignment)
+	slog.Info("Created new assignment", "id", assignment.ID, "quizID", q.ID, "deadline", deadline)
+
+	w.Header().Add("HX-Redirect", "/assignments/"+assignment.ID+"/results")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// getAssignment returns the assignment from the path, writes the error response if it fails
+func (s Service) getAssignment(w http.ResponseWriter, r *http.Request) (*Assignment, bool) {
+	a, err := s.aRepo.GetAssignment(r.PathValue("id"))
+	switch err.(type) {
+	case nil:
+		return a, true
+	case errAssignmentNotFound:
+		common.ErrorHandler(w, r, http.StatusNotFound)
+	default:
+		slog.Error("Error getting assignment", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+	}
+	return nil, false
+}
+
+// getAttempt returns the assignment, the client and their attempt, writes the error response if it fails
+func (s Service) getAttempt(w http.ResponseWriter, r *http.Request) (*Assignment, common.ClientID, *Attempt, bool) {
+	a, ok := s.getAssignment(w, r)
+	if !ok {
+		return nil, "", nil, false
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+
+	attempt, ok := a.Attempt(clientID)
+	if !ok {
+		// The client hasn't joined yet
+		w.Header().Add("HX-Redirect", "/assignments/"+a.ID)
+		http.Redirect(w, r, "/assignments/"+a.ID, http.StatusSeeOther)
+		return nil, "", nil, false
+	}
+	return a, clientID, attempt, true
+}
+
+// getAssignmentHandler shows the join form of the shareable link
+func (s Service) getAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, ok := s.getAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := a.Attempt(clientID); ok {
+		http.Redirect(w, r, "/assignments/"+a.ID+"/play", http.StatusSeeOther)
+		return
+	}
+
+	if err := AssignmentTmpl.Execute(w, JoinFormData{Assignment: a}); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+func (s Service) postJoinHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, ok := s.getAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := JoinFormData{
+		Assignment: a,
+		Username:   strings.TrimSpace(r.FormValue("username")),
+	}
+
+	attempt, err := a.Join(clientID, game.Username(data.Username))
+	if err != nil {
+		switch err.(type) {
+		case game.ErrInvalidUsername, errUsernameTaken, errAssignmentClosed:
+			data.Error = err.Error()
+		default:
+			slog.Error("Error joining assignment", "id", a.ID, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := JoinFormTmpl.Execute(w, data); err != nil {
+			slog.Error("Error rendering template", "err", err)
+		}
+		return
+	}
+	s.saveAttempt(a, clientID, attempt)
+
+	w.Header().Add("HX-Redirect", "/assignments/"+a.ID+"/play")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s Service) getPlayHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, _, attempt, ok := s.getAttempt(w, r)
+	if !ok {
+		return
+	}
+
+	tmpl := PlayTmpl
+	if r.Header.Get("HX-Request") == "true" {
+		tmpl = PlayStateTmpl
+	}
+	if err := tmpl.Execute(w, PlayData{Assignment: a, Attempt: attempt}); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+func (s Service) postAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, clientID, attempt, ok := s.getAttempt(w, r)
+	if !ok {
+		return
+	}
+
+	if a.IsClosed() {
+		s.renderPlayState(w, a, attempt)
+		return
+	}
+
+	answerIdx, err := strconv.Atoi(r.FormValue("answer"))
+	if err != nil {
+		common.ErrorHandler(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// An answer submitted too late is ignored, the player sees the result of the round instead
+	if err := attempt.Answer(answerIdx); err != nil {
+		slog.Debug("Answer not accepted", "id", a.ID, "username", attempt.Username, "err", err)
+	}
+	s.saveAttempt(a, clientID, attempt)
+	s.renderPlayState(w, a, attempt)
+}
+
+func (s Service) postNextHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, clientID, attempt, ok := s.getAttempt(w, r)
+	if !ok {
+		return
+	}
+
+	if a.IsClosed() {
+		s.renderPlayState(w, a, attempt)
+		return
+	}
+
+	if err := attempt.Next(); err != nil {
+		slog.Debug("Next question not started", "id", a.ID, "username", attempt.Username, "err", err)
+	}
+	s.saveAttempt(a, clientID, attempt)
+	s.renderPlayState(w, a, attempt)
+}
+
+func (s Service) renderPlayState(w http.ResponseWriter, a *Assignment, attempt *Attempt) {
+	if err := PlayStateTmpl.Execute(w, PlayData{Assignment: a, Attempt: attempt}); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// getOwnedAssignment returns the assignment if the client created it, writes the error response otherwise
+func (s Service) getOwnedAssignment(w http.ResponseWriter, r *http.Request) (*Assignment, bool) {
+	a, ok := s.getAssignment(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if a.Owner != clientID {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	return a, true
+}
+
+func (s Service) getResultsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, ok := s.getOwnedAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	data := ResultsData{
+		Assignment: a,
+		Link:       fmt.Sprintf("%s://%s/assignments/%s", scheme, r.Host, a.ID),
+	}
+
+	if err := ResultsTmpl.Execute(w, data); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// postCloseHandler closes the assignment before its deadline
+func (s Service) postCloseHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	a, ok := s.getOwnedAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := s.closeAssignment(a)
+	switch err.(type) {
+	case nil:
+		slog.Info("Assignment closed by owner", "id", a.ID)
+	case errAssignmentClosed:
+		id = a.PastGameID()
+	default:
+		slog.Error("Error closing assignment", "id", a.ID, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	redirect := "/assignments/" + a.ID + "/results"
+	if id != 0 {
+		redirect = fmt.Sprintf("/past-games/%d", id)
+	}
+	w.Header().Add("HX-Redirect", redirect)
+	w.WriteHeader(http.StatusOK)
+}
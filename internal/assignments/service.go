@@ -0,0 +1,93 @@
+package assignments
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/pastgames"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+type Service struct {
+	aRepo  Repository           // Assignments Repository
+	pgRepo pastgames.Repository // PastGames Repository
+	qRepo  quiz.Repository      // Quizzes Repository
+}
+
+func NewService(assignmentsRepo Repository, pastGamesRepo pastgames.Repository, quizRepo quiz.Repository) Service {
+	return Service{
+		aRepo:  assignmentsRepo,
+		pgRepo: pastGamesRepo,
+		qRepo:  quizRepo,
+	}
+}
+
+// ScheduleOpenAssignments arms the deadlines of the assignments that weren't closed yet, e.g. after a restart
+// Assignments past their deadline are closed right away
+func (s Service) ScheduleOpenAssignments() error {
+	assignments, err := s.aRepo.GetOpenAssignments()
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		s.scheduleClose(a)
+	}
+	slog.Info("Scheduled open assignments", "count", len(assignments))
+	return nil
+}
+
+// scheduleClose closes the assignment and stores its results once the deadline passes
+func (s Service) scheduleClose(a *Assignment) {
+	time.AfterFunc(time.Until(a.Deadline), func() {
+		_, err := s.closeAssignment(a)
+		switch err.(type) {
+		case nil:
+			slog.Info("Assignment closed at deadline", "id", a.ID)
+		case errAssignmentClosed:
+			// Closed by the owner before the deadline
+		default:
+			slog.Error("Error closing assignment at deadline", "id", a.ID, "err", err)
+		}
+	})
+}
+
+// closeAssignment stores the results of the assignment and closes it, returns the ID of the past game
+// The assignment stays open if the results can't be stored
+func (s Service) closeAssignment(a *Assignment) (int64, error) {
+	var pastGame pastgames.PastGame
+	id, err := a.Close(func(pg pastgames.PastGame) (int64, error) {
+		id, err := s.pgRepo.Insert(&pg)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.aRepo.SaveClosed(a.ID, pg.EndedAt, id); err != nil {
+			// Otherwise the results would be stored again when the assignment is closed later
+			if err := s.pgRepo.Delete(id); err != nil {
+				slog.Error("Error deleting results of unclosed assignment", "id", a.ID, "pastGameID", id, "err", err)
+			}
+			return 0, err
+		}
+		pastGame = pg
+		return id, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Play counts are only used for sorting quizzes, the results are stored regardless
+	if pastGame.QuizID != 0 {
+		if err := s.qRepo.RecordPlay(pastGame.QuizID); err != nil {
+			slog.Error("Error recording quiz play", "quizID", pastGame.QuizID, "err", err)
+		}
+	}
+	return id, nil
+}
+
+// saveAttempt stores the progress of the attempt, so it survives a restart
+// Failing to store it doesn't stop the player, the error is only logged
+func (s Service) saveAttempt(a *Assignment, clientID common.ClientID, attempt *Attempt) {
+	if err := s.aRepo.SaveAttempt(a.ID, clientID, attempt); err != nil {
+		slog.Error("Error saving attempt", "id", a.ID, "username", attempt.Username, "err", err)
+	}
+}
@@ -0,0 +1,246 @@
+package assignments
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/jmoiron/sqlx"
+)
+
+type RepositorySQLite struct {
+	*repositorySQLite
+}
+
+// NewRepositorySQLite returns the repository storing the assignments in the database
+// The quizzes of the assignments are loaded from quizRepo when the assignments are restored
+func NewRepositorySQLite(db *sqlx.DB, quizRepo quiz.Repository) (RepositorySQLite, error) {
+	repo := RepositorySQLite{
+		&repositorySQLite{
+			db:          db,
+			qRepo:       quizRepo,
+			assignments: make(map[string]*Assignment),
+		},
+	}
+	return repo, repo.createTables()
+}
+
+// repositorySQLite stores the assignments and the progress of their attempts
+// Attempts are running games, so the assignments are kept in memory once they are loaded
+type repositorySQLite struct {
+	db          *sqlx.DB
+	qRepo       quiz.Repository
+	mu          sync.Mutex
+	assignments map[string]*Assignment // Loaded assignments by ID
+}
+
+func (repo *repositorySQLite) createTables() error {
+	const schema = `
+		CREATE TABLE IF NOT EXISTS assignment (
+			assignment_id TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			quiz_id INTEGER NOT NULL,
+			quiz_revision INTEGER NOT NULL,
+			reading_time INTEGER NOT NULL,
+			answer_time INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			deadline DATETIME NOT NULL,
+			closed_at DATETIME,
+			past_game_id INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_assignment_owner ON assignment(owner);
+
+		CREATE TABLE IF NOT EXISTS assignment_attempt (
+			assignment_id TEXT REFERENCES assignment(assignment_id) ON DELETE CASCADE,
+			client_id TEXT,
+			username TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			questions_done INTEGER NOT NULL,
+			finished INTEGER NOT NULL,
+			PRIMARY KEY (assignment_id, client_id)
+		);
+	`
+	_, err := repo.db.Exec(schema)
+	return err
+}
+
+// AddAssignment stores the assignment and sets its ID to a new random token
+func (repo *repositorySQLite) AddAssignment(a *Assignment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The token is part of the shareable link, so it has to be hard to guess
+	token := make([]byte, 9)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	a.ID = base64.RawURLEncoding.EncodeToString(token)
+
+	_, err := repo.db.Exec(`
+		INSERT INTO assignment (
+			assignment_id, owner, quiz_id, quiz_revision, reading_time, answer_time, created_at, deadline
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.Owner, a.Quiz.ID, a.Quiz.Revision, a.Settings.ReadingTime, a.Settings.AnswerTime, a.CreatedAt, a.Deadline)
+	if err != nil {
+		return err
+	}
+
+	repo.assignments[a.ID] = a
+	return nil
+}
+
+func (repo *repositorySQLite) GetAssignment(id string) (*Assignment, error) {
+	assignments, err := repo.loadAssignments("assignment_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignments) == 0 {
+		return nil, errAssignmentNotFound{}
+	}
+	return assignments[0], nil
+}
+
+// GetAssignmentsByOwner returns the assignments created by the client, newest first
+func (repo *repositorySQLite) GetAssignmentsByOwner(owner common.ClientID) ([]*Assignment, error) {
+	return repo.loadAssignments("owner = ?", owner)
+}
+
+func (repo *repositorySQLite) GetOpenAssignments() ([]*Assignment, error) {
+	return repo.loadAssignments("closed_at IS NULL")
+}
+
+func (repo *repositorySQLite) SaveAttempt(assignmentID string, clientID common.ClientID, attempt *Attempt) error {
+	_, err := repo.db.Exec(`
+		INSERT INTO assignment_attempt (assignment_id, client_id, username, score, questions_done, finished)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (assignment_id, client_id) DO UPDATE SET
+		score = excluded.score,
+		questions_done = excluded.questions_done,
+		finished = excluded.finished
+	`, assignmentID, clientID, attempt.Username, attempt.Score(), attempt.QuestionsDone(), attempt.IsFinished())
+	return err
+}
+
+func (repo *repositorySQLite) SaveClosed(assignmentID string, closedAt time.Time, pastGameID int64) error {
+	res, err := repo.db.Exec(`
+		UPDATE assignment SET closed_at = ?, past_game_id = ? WHERE assignment_id = ?
+	`, closedAt, pastGameID, assignmentID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errAssignmentNotFound{}
+	}
+	return err
+}
+
+type assignmentRow struct {
+	ID           string          `db:"assignment_id"`
+	Owner        common.ClientID `db:"owner"`
+	QuizID       int64           `db:"quiz_id"`
+	QuizRevision int64           `db:"quiz_revision"`
+	ReadingTime  time.Duration   `db:"reading_time"`
+	AnswerTime   time.Duration   `db:"answer_time"`
+	CreatedAt    time.Time       `db:"created_at"`
+	Deadline     time.Time       `db:"deadline"`
+	ClosedAt     sql.NullTime    `db:"closed_at"`
+	PastGameID   int64           `db:"past_game_id"`
+}
+
+type attemptRow struct {
+	ClientID      common.ClientID `db:"client_id"`
+	Username      game.Username   `db:"username"`
+	Score         int             `db:"score"`
+	QuestionsDone int             `db:"questions_done"`
+	Finished      bool            `db:"finished"`
+}
+
+// loadAssignments returns the assignments matching the condition, newest first
+// Assignments that aren't loaded yet are restored from the database together with their attempts,
+// those that can't be restored are left out
+func (repo *repositorySQLite) loadAssignments(condition string, args ...any) ([]*Assignment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var rows []assignmentRow
+	err := repo.db.Select(&rows, `
+		SELECT
+			assignment_id, owner, quiz_id, quiz_revision, reading_time, answer_time,
+			created_at, deadline, closed_at, past_game_id
+		FROM assignment
+		WHERE `+condition+`
+		ORDER BY created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]*Assignment, 0, len(rows))
+	for _, row := range rows {
+		a, ok := repo.assignments[row.ID]
+		if !ok {
+			a, err = repo.restore(row)
+			if err != nil {
+				// E.g. the quiz was deleted, the other assignments are still usable
+				slog.Error("Error restoring assignment", "id", row.ID, "err", err)
+				continue
+			}
+			repo.assignments[row.ID] = a
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+// restore recreates the assignment from its row and the stored attempts
+func (repo *repositorySQLite) restore(row assignmentRow) (*Assignment, error) {
+	q, err := repo.qRepo.GetRevision(row.QuizID, row.QuizRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Assignment{
+		ID:    row.ID,
+		Owner: row.Owner,
+		Quiz:  *q,
+		Settings: game.RoundSettings{
+			ReadingTime: row.ReadingTime,
+			AnswerTime:  row.AnswerTime,
+		},
+		CreatedAt:  row.CreatedAt,
+		Deadline:   row.Deadline,
+		closedAt:   row.ClosedAt.Time,
+		pastGameID: row.PastGameID,
+		clock:      game.SystemClock,
+		attempts:   make(map[common.ClientID]*Attempt),
+	}
+
+	var attempts []attemptRow
+	err = repo.db.Select(&attempts, `
+		SELECT client_id, username, score, questions_done, finished
+		FROM assignment_attempt
+		WHERE assignment_id = ?
+	`, row.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range attempts {
+		// Attempts of a closed assignment are only shown with their score
+		finished := attempt.Finished || !a.closedAt.IsZero()
+		err := a.restoreAttempt(attempt.ClientID, attempt.Username, attempt.Score, attempt.QuestionsDone, finished)
+		if err != nil {
+			return nil, fmt.Errorf("restoring attempt of %s: %w", attempt.Username, err)
+		}
+	}
+	return a, nil
+}
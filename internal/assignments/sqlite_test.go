@@ -0,0 +1,127 @@
+package assignments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRepositorySQLiteRestore(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Every connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	quizRepo, err := quiz.NewRepositorySQLite(db)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q := quiz.ExampleQuizMath
+	if q.ID, err = quizRepo.Insert(&q); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q.Revision = 1
+
+	repo, err := NewRepositorySQLite(db, quizRepo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	settings := game.RoundSettings{AnswerTime: time.Minute}
+	a, err := NewAssignment("owner", q, settings, time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.AddAssignment(a); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Alice answers the first question, Bob only joins
+	alice, err := a.Join("client-1", "Alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := alice.Answer(0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bob, err := a.Join("client-2", "Bob")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for clientID, attempt := range map[common.ClientID]*Attempt{"client-1": alice, "client-2": bob} {
+		if err := repo.SaveAttempt(a.ID, clientID, attempt); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Deleting the quiz keeps the revision the assignment is pinned to
+	if err := quizRepo.Delete(q.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A new repository on the same database is what the server sees after a restart
+	restarted, err := NewRepositorySQLite(db, quizRepo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	open, err := restarted.GetOpenAssignments()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(open) != 1 || open[0].ID != a.ID {
+		t.Fatalf("Expected the assignment to be open, got %v", open)
+	}
+	restored := open[0]
+	if restored == a || !restored.Deadline.Equal(a.Deadline) || restored.Settings != settings || restored.Quiz.Title() != q.Title() {
+		t.Errorf("Expected the assignment to be restored, got %+v", restored)
+	}
+
+	restoredAlice, ok := restored.Attempt("client-1")
+	if !ok {
+		t.Fatal("Expected the attempt of Alice to be restored")
+	}
+	if restoredAlice.Score() != alice.Score() || restoredAlice.Score() == 0 {
+		t.Errorf("Expected score %d, got %d", alice.Score(), restoredAlice.Score())
+	}
+	if !restoredAlice.InRound() || restoredAlice.RoundNum() != 1 {
+		t.Errorf("Expected Alice to continue with the second question, got round %d", restoredAlice.RoundNum())
+	}
+	if restoredBob, ok := restored.Attempt("client-2"); !ok || restoredBob.RoundNum() != 0 || restoredBob.Score() != 0 {
+		t.Error("Expected Bob to start over with the first question")
+	}
+	if _, err := restored.Join("client-3", "Alice"); err != (errUsernameTaken{}) {
+		t.Errorf("Expected errUsernameTaken, got %v", err)
+	}
+
+	// Closed assignments aren't scheduled again
+	if err := restarted.SaveClosed(a.ID, time.Now(), 7); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := restarted.SaveClosed("missing", time.Now(), 7); err != (errAssignmentNotFound{}) {
+		t.Errorf("Expected errAssignmentNotFound, got %v", err)
+	}
+	again, err := NewRepositorySQLite(db, quizRepo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if open, err := again.GetOpenAssignments(); err != nil || len(open) != 0 {
+		t.Errorf("Expected no open assignments, got %v (%v)", open, err)
+	}
+	owned, err := again.GetAssignmentsByOwner("owner")
+	if err != nil || len(owned) != 1 {
+		t.Fatalf("Expected the assignment of the owner, got %v (%v)", owned, err)
+	}
+	if !owned[0].IsClosed() || owned[0].PastGameID() != 7 {
+		t.Errorf("Expected the assignment to be closed with past game 7")
+	}
+	if attempt, ok := owned[0].Attempt("client-2"); !ok || !attempt.IsFinished() {
+		t.Error("Expected the attempts of a closed assignment to be finished")
+	}
+}
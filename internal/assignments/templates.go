@@ -0,0 +1,48 @@
+package assignments
+
+import (
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+var AssignmentsTmpl = common.TmplParseWithBase("templates/assignments/assignments.html")
+var CreateFormTmpl = AssignmentsTmpl.Lookup("create-form")
+
+type AssignmentsData struct {
+	Assignments []*Assignment // Assignments created by the client
+	CreateFormData
+}
+
+type CreateFormData struct {
+	Quizzes     []quiz.QuizMetadata
+	QuizID      int64
+	ReadingTime int    // In seconds
+	AnswerTime  int    // In seconds
+	Deadline    string // In the format of a datetime-local input
+	Error       string
+}
+
+var AssignmentTmpl = common.TmplParseWithBase("templates/assignments/assignment.html")
+var JoinFormTmpl = AssignmentTmpl.Lookup("join-form")
+
+type JoinFormData struct {
+	Assignment *Assignment
+	Username   string
+	Error      string
+}
+
+// PlayTmpl renders the current state of an attempt, the "play" block is swapped after every action
+var PlayTmpl = common.ParseTmplWithFuncs("templates/assignments/play.html")
+var PlayStateTmpl = PlayTmpl.Lookup("play")
+
+type PlayData struct {
+	Assignment *Assignment
+	Attempt    *Attempt
+}
+
+var ResultsTmpl = common.ParseTmplWithFuncs("templates/assignments/results.html")
+
+type ResultsData struct {
+	Assignment *Assignment
+	Link       string // Shareable link to the assignment
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AddColumnIfMissing adds a column to an existing table, it is a no-op if the column already exists
+// It migrates databases created before the column was added to the schema
+func AddColumnIfMissing(db sqlx.Ext, table, column, definition string) error {
+	var count int
+	err := sqlx.Get(db, &count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// RunMigrationOnce runs the migration in a transaction, unless a migration with the name already ran
+// The names of the migrations that ran are recorded in the schema_migration table
+func RunMigrationOnce(db *sqlx.DB, name string, migrate func(tx *sqlx.Tx) error) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migration (
+			name       TEXT PRIMARY KEY,
+			applied_at DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	res, err := tx.Exec("INSERT OR IGNORE INTO schema_migration (name, applied_at) VALUES (?, ?)", name, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err := migrate(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -40,6 +40,7 @@ type Quiz interface {
 }
 
 type Question interface {
+	Prompt() string // text of the question shown to the players
 	Answers() []Answer
 	IsAnswerCorrect(answerIndex int) bool
 	IsAnswerValid(answerIndex int) bool // should check if the answerIndex corresponds to an answer
@@ -3,13 +3,30 @@ package game
 import (
 	"errors"
 	"log/slog"
+	"maps"
+	"math/rand"
 	"slices"
 	"sync"
 	"time"
 )
 
 type GameSettings struct {
-	Quiz Quiz
+	Quiz             Quiz
+	ShuffleQuestions bool // Ask the questions in random order
+	QuestionPoolSize int  // Ask only this many randomly drawn questions, 0 means all questions
+	AllowLateJoin    bool // Players can join after the game has started, missed questions give them no points
+	// Quiz question indices in the order they are asked, it overrides ShuffleQuestions and QuestionPoolSize
+	// It is meant for replaying a game, nil means the order follows the other settings
+	QuestionOrder []int
+	// Pause after a round before the next one is started without the host, 0 means the host advances manually
+	// The game only stores it, advancing is up to the one running the game
+	AutoAdvance time.Duration
+	// Players away for longer are removed, 0 means they are never removed
+	// Like AutoAdvance, it is up to the one running the game to remove them
+	RemoveAwayAfter time.Duration
+	// The host is replaced after being disconnected for longer, 0 means the host is never replaced
+	// The game has no host, replacing it is up to the one running the game
+	HostGracePeriod time.Duration
 	RoundSettings
 }
 
@@ -49,15 +66,28 @@ type game struct {
 	startedAt time.Time
 	endedAt   time.Time
 	quiz      Quiz
+	clock     Clock
 	points    map[Username]int
+	away      map[Username]bool // Players who can't answer right now, rounds don't wait for them
+	retired   map[Username]bool // Players who left the game but keep their points
 	Round     *Round
 	roundNum  int
+	order     []int // Round number -> question index in the quiz, set when the game starts
+	scored    bool  // Points of the current round were already added
 }
 
-func CreateGame(settings GameSettings) Game {
+// CreateGame creates a game that is timed using the clock, a nil clock uses SystemClock
+func CreateGame(settings GameSettings, clock Clock) Game {
+	if clock == nil {
+		clock = SystemClock
+	}
+
 	game := Game{
 		&game{
-			points: make(map[Username]int),
+			clock:   clock,
+			points:  make(map[Username]int),
+			away:    make(map[Username]bool),
+			retired: make(map[Username]bool),
 		},
 	}
 
@@ -84,11 +114,101 @@ func (game *game) UpdateSettings(settings GameSettings) error {
 		return ErrGameAlreadyStarted{}
 	}
 
+	if settings.QuestionPoolSize < 0 {
+		return errors.New("Question pool size can't be negative")
+	}
+
+	if settings.AutoAdvance < 0 {
+		return errors.New("Auto advance pause can't be negative")
+	}
+
+	if settings.RemoveAwayAfter < 0 {
+		return errors.New("Time before removing away players can't be negative")
+	}
+
+	if settings.HostGracePeriod < 0 {
+		return errors.New("Host grace period can't be negative")
+	}
+
+	if !settings.AnswerShuffle.IsValid() {
+		return errors.New("Invalid answer shuffle setting")
+	}
+
+	if settings.QuestionOrder != nil {
+		asked := make(map[int]bool, len(settings.QuestionOrder))
+		for _, idx := range settings.QuestionOrder {
+			if idx < 0 || idx >= settings.Quiz.QuestionsCount() || asked[idx] {
+				return errors.New("Invalid question order")
+			}
+			asked[idx] = true
+		}
+	}
+
 	game.quiz = settings.Quiz
 	game.settings = settings
 	return nil
 }
 
+// questionOrder returns the quiz question indices in the order they will be asked
+// Thread unsafe
+func (game *game) questionOrder() []int {
+	if game.settings.QuestionOrder != nil {
+		return slices.Clone(game.settings.QuestionOrder)
+	}
+
+	count := game.quiz.QuestionsCount()
+	poolSize := game.settings.QuestionPoolSize
+	if poolSize == 0 || poolSize > count {
+		poolSize = count
+	}
+
+	if !game.settings.ShuffleQuestions && poolSize == count {
+		order := make([]int, count)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	order := rand.Perm(count)[:poolSize]
+	if !game.settings.ShuffleQuestions {
+		// Only drawing from the pool, keep the order of the quiz
+		slices.Sort(order)
+	}
+	return order
+}
+
+// QuestionsCount returns the number of questions that are asked in the game
+func (game *game) QuestionsCount() int {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+
+	if game.order != nil {
+		return len(game.order)
+	}
+	if game.settings.QuestionOrder != nil {
+		return len(game.settings.QuestionOrder)
+	}
+
+	count := game.quiz.QuestionsCount()
+	if poolSize := game.settings.QuestionPoolSize; poolSize > 0 && poolSize < count {
+		return poolSize
+	}
+	return count
+}
+
+// QuizQuestionIdx returns the index of the question in the quiz asked in the given round
+// Rounds are of index 0, returns -1 if there is no such round
+func (game *game) QuizQuestionIdx(roundNum int) int {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+
+	if roundNum < 0 || roundNum >= len(game.order) {
+		return -1
+	}
+	return game.order[roundNum]
+}
+
 func (game *game) Quiz() Quiz {
 	game.mu.RLock()
 	defer game.mu.RUnlock()
@@ -107,13 +227,20 @@ func (game *game) EndedAt() time.Time {
 	return game.endedAt
 }
 
+// AddPlayer adds a player to the game
+// After the game has started, players can only join if the settings allow late joins,
+// they then start with zero points and also play the current round if it is still running
 func (game *game) AddPlayer(username Username) error {
 	game.mu.Lock()
 	defer game.mu.Unlock()
-	if !game.startedAt.IsZero() {
+	if !game.startedAt.IsZero() && !game.settings.AllowLateJoin {
 		return ErrGameAlreadyStarted{}
 	}
 
+	if !game.endedAt.IsZero() {
+		return ErrGameFinished{}
+	}
+
 	isValid, err := username.IsValid()
 	if !isValid {
 		return ErrInvalidUsername{
@@ -127,6 +254,14 @@ func (game *game) AddPlayer(username Username) error {
 	}
 
 	game.points[username] = 0
+
+	// Future rounds pick up the player on their own, only the running round needs to know
+	if game.Round != nil {
+		err := game.Round.addPlayer(username)
+		if err != nil && !errors.Is(err, ErrRoundAlreadyEnded) {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -155,14 +290,20 @@ func (game *game) ChangeUsername(oldName, newName Username) error {
 
 	game.points[newName] = oldUsernamePoints
 	delete(game.points, oldName)
+	if game.away[oldName] {
+		game.away[newName] = true
+		delete(game.away, oldName)
+	}
 	return nil
 }
 
+// RemovePlayer removes the player and their points from the game
+// A running round stops waiting for the player's answer
 func (game *game) RemovePlayer(username Username) error {
 	game.mu.Lock()
 	defer game.mu.Unlock()
-	if !game.startedAt.IsZero() {
-		return ErrGameAlreadyStarted{}
+	if !game.endedAt.IsZero() {
+		return ErrGameFinished{}
 	}
 
 	_, isUsernameInGame := game.points[username]
@@ -170,10 +311,80 @@ func (game *game) RemovePlayer(username Username) error {
 		return errors.New("Username not in game")
 	}
 
+	if err := game.leaveRound(username); err != nil {
+		return err
+	}
 	delete(game.points, username)
+	delete(game.away, username)
+	delete(game.retired, username)
+	return nil
+}
+
+// RetirePlayer removes the player from the current and future rounds,
+// but keeps their points in the scores and the leaderboard
+func (game *game) RetirePlayer(username Username) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if !game.endedAt.IsZero() {
+		return ErrGameFinished{}
+	}
+
+	_, isUsernameInGame := game.points[username]
+	if !isUsernameInGame || game.retired[username] {
+		return errors.New("Username not in game")
+	}
+
+	if err := game.leaveRound(username); err != nil {
+		return err
+	}
+	delete(game.away, username)
+	game.retired[username] = true
+	return nil
+}
+
+// leaveRound removes the player from the current round if it is still running
+// Thread unsafe
+func (game *game) leaveRound(username Username) error {
+	if game.Round == nil || game.Round.HasFinished() {
+		return nil
+	}
+
+	err := game.Round.removePlayer(username)
+	if err != nil && !errors.Is(err, ErrRoundAlreadyEnded) {
+		return err
+	}
 	return nil
 }
 
+// SetPlayerAway marks the player as away (or back), e.g. when the player loses the connection
+// Rounds don't wait for away players to answer, they can still answer if they come back in time
+func (game *game) SetPlayerAway(username Username, away bool) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	if _, isUsernameInGame := game.points[username]; !isUsernameInGame || game.retired[username] {
+		return errors.New("Username not in game")
+	}
+
+	if away {
+		game.away[username] = true
+	} else {
+		delete(game.away, username)
+	}
+
+	if game.Round == nil || game.Round.HasFinished() {
+		return nil
+	}
+	return game.Round.setAway(username, away)
+}
+
+// IsPlayerAway reports whether the player is marked as away
+func (game *game) IsPlayerAway(username Username) bool {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+	return game.away[username]
+}
+
 func (game *game) Start() error {
 	game.mu.Lock()
 	defer game.mu.Unlock()
@@ -185,12 +396,14 @@ func (game *game) Start() error {
 		return ErrGameFinished{}
 	}
 
+	game.order = game.questionOrder()
 	err := game.startRound(0)
 	if err != nil {
+		game.order = nil
 		return err
 	}
 
-	game.startedAt = time.Now()
+	game.startedAt = game.clock.Now()
 	return nil
 }
 
@@ -210,7 +423,7 @@ func (game *game) StartNextRound() error {
 		return errors.New("Previous round not finished")
 	}
 
-	if game.roundNum+1 >= game.quiz.QuestionsCount() {
+	if game.roundNum+1 >= len(game.order) {
 		return ErrNoMoreQuestions{}
 	}
 
@@ -225,7 +438,7 @@ func (game *game) Finish() error {
 		return ErrGameFinished{}
 	}
 
-	game.endedAt = time.Now()
+	game.endedAt = game.clock.Now()
 	return nil
 }
 
@@ -271,48 +484,71 @@ func (game *game) startRound(num int) error {
 		if !game.Round.HasFinished() {
 			return errors.New("Round not finished")
 		}
+		game.scoreRound()
 	}
 
 	if !game.endedAt.IsZero() {
 		return ErrGameFinished{}
 	}
 
-	if len(game.points) == 0 {
+	// Retired players keep their points, but can't answer anymore
+	if len(game.players()) == 0 {
 		return errors.New("No players in game")
 	}
 
-	question, err := game.quiz.GetQuestion(num)
+	if num < 0 || num >= len(game.order) {
+		return ErrNoMoreQuestions{}
+	}
+
+	question, err := game.quiz.GetQuestion(game.order[num])
 	if err != nil {
 		return err
 	}
 
-	newRound := CreateRound(game.players(), question, game.settings.RoundSettings)
+	newRound := CreateRound(game.players(), question, game.settings.RoundSettings, game.clock)
+	newRound.away = maps.Clone(game.away)
 	game.Round = newRound
 	game.roundNum = num
+	game.scored = false
 	err = newRound.start()
 	if err != nil {
 		return err
 	}
 
 	go func() {
-		<-game.Round.Finished()
+		<-newRound.Finished()
 		slog.Debug("Round finished, adding points")
 		game.mu.Lock()
-
-		results, err := game.Round.Results()
-		if err != nil {
-			slog.Error("Error getting round results", "err", err)
-			return
+		defer game.mu.Unlock()
+		if game.Round == newRound {
+			game.scoreRound()
 		}
-		for username, points := range results {
-			game.points[username] = points
-		}
-
-		game.mu.Unlock()
 	}()
 	return nil
 }
 
+// scoreRound adds the points of the current round to the players if it has finished
+// Points of a round are added only once, so it is safe to call it repeatedly
+// Players removed after the round finished get no points, they are no longer in the game
+// Thread unsafe
+func (game *game) scoreRound() {
+	if game.Round == nil || game.scored || !game.Round.HasFinished() {
+		return
+	}
+
+	results, err := game.Round.Results()
+	if err != nil {
+		slog.Error("Error getting round results", "err", err)
+		return
+	}
+	for username, points := range results {
+		if _, inGame := game.points[username]; inGame {
+			game.points[username] += points
+		}
+	}
+	game.scored = true
+}
+
 func (game *game) FinishRoundEarly() error {
 	game.mu.RLock()
 	defer game.mu.RUnlock()
@@ -323,6 +559,39 @@ func (game *game) FinishRoundEarly() error {
 	return game.Round.FinishEarly()
 }
 
+// PauseRound pauses the timer of the current round
+func (game *game) PauseRound() error {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+	if game.Round == nil {
+		return errors.New("Not in round")
+	}
+
+	return game.Round.Pause()
+}
+
+// ResumeRound resumes the timer of the current round
+func (game *game) ResumeRound() error {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+	if game.Round == nil {
+		return errors.New("Not in round")
+	}
+
+	return game.Round.Resume()
+}
+
+// ExtendRound gives the players of the current round extra time for answering
+func (game *game) ExtendRound(d time.Duration) error {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+	if game.Round == nil {
+		return errors.New("Not in round")
+	}
+
+	return game.Round.Extend(d)
+}
+
 func (game *game) PlayerInGame(u Username) bool {
 	game.mu.RLock()
 	defer game.mu.RUnlock()
@@ -339,11 +608,11 @@ func (game *game) Players() []Username {
 
 // Thread unsafe
 func (game *game) players() []Username {
-	players := make([]Username, len(game.points))
-	i := 0
+	players := make([]Username, 0, len(game.points))
 	for username := range game.points {
-		players[i] = username
-		i++
+		if !game.retired[username] {
+			players = append(players, username)
+		}
 	}
 
 	return players
@@ -359,15 +628,19 @@ func (game *game) RoundNum() int {
 	return int(game.roundNum)
 }
 
+// Scores returns a copy of the points of all players
+// Points of the last round are included as soon as it finishes
 func (game *game) Scores() map[Username]int {
-	game.mu.RLock()
-	defer game.mu.RUnlock()
-	return game.points
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	game.scoreRound()
+	return maps.Clone(game.points)
 }
 
 func (game *game) Leaderboard() []Score {
-	game.mu.RLock()
-	defer game.mu.RUnlock()
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	game.scoreRound()
 	leaderboard := make([]Score, len(game.points))
 	i := 0
 	for username, points := range game.points {
@@ -406,5 +679,5 @@ func (game *game) SubmitAnswer(username Username, answerIndex int) error {
 func (game *game) InRound() bool {
 	game.mu.RLock()
 	defer game.mu.RUnlock()
-	return game.Round.HasStarted() && !game.Round.HasFinished()
+	return game.Round != nil && game.Round.HasStarted() && !game.Round.HasFinished()
 }
@@ -39,7 +39,7 @@ func createMockGame() Game {
 			AnswerTime:  1 * time.Second,
 		},
 	}
-	return CreateGame(settings)
+	return CreateGame(settings, newTestClock())
 }
 
 // Contains checks if a slice contains a specific element
@@ -135,11 +135,153 @@ func TestRemovePlayer(t *testing.T) {
 		t.Errorf("Unexpected error: %v", err)
 	}
 
-	if err := game.RemovePlayer("Bob"); err == nil {
-		t.Errorf("Expected error for removing player after game started, got nil")
+	if err := game.RemovePlayer("Bob"); err != nil {
+		t.Errorf("Unexpected error removing player after game started: %v", err)
 	}
 }
 
+func TestRemovePlayerDuringGame(t *testing.T) {
+	game := createMockGame()
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := game.RemovePlayer("Bob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if Contains(game.Players(), "Bob") {
+		t.Errorf("Bob should not be in players")
+	}
+	if answering := game.Round.PlayersAnswering(); answering != 1 {
+		t.Errorf("Expected the removed player to leave the round, got %d answering", answering)
+	}
+
+	if err := game.Finish(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.RemovePlayer("Alice"); !errors.As(err, &ErrGameFinished{}) {
+		t.Errorf("Expected ErrGameFinished, got %v", err)
+	}
+}
+
+func TestRemovePlayerAfterRound(t *testing.T) {
+	game := CreateGame(GameSettings{
+		Quiz:          MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}}},
+		RoundSettings: RoundSettings{AnswerTime: 10 * time.Second},
+	}, newTestClock())
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The round finishes with both answers, Bob is kicked before or after its points are added
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.SubmitAnswer("Bob", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.RemovePlayer("Bob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 1000})
+
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := game.Scores()["Bob"]; ok {
+		t.Error("Expected the kicked player to stay out of the scores")
+	}
+}
+
+func TestRetirePlayer(t *testing.T) {
+	clock := newTestClock()
+	game := CreateGame(GameSettings{
+		Quiz:          MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}}},
+		RoundSettings: RoundSettings{AnswerTime: 10 * time.Second},
+	}, clock)
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := game.SubmitAnswer("Bob", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Bob leaves while Alice already answered, so the round finishes
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.RetirePlayer("Bob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !game.Round.HasFinished() {
+		t.Error("Expected the round to finish once the last player left to answer retired")
+	}
+	if Contains(game.Players(), "Bob") {
+		t.Errorf("Retired players should not be in players")
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 2000, "Bob": 1000})
+
+	if err := game.RetirePlayer("Bob"); err == nil {
+		t.Error("Expected error for retiring a player twice")
+	}
+	if err := game.AddPlayer("Bob"); err == nil {
+		t.Error("Expected the username of a retired player to stay taken")
+	}
+}
+
+func TestStartRoundAllRetired(t *testing.T) {
+	game := CreateGame(GameSettings{
+		Quiz:          MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}}},
+		RoundSettings: RoundSettings{AnswerTime: 10 * time.Second},
+	}, newTestClock())
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Both players are kicked with their points after the first round
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.SubmitAnswer(player, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.RetirePlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.StartNextRound(); err == nil {
+		t.Error("Expected error for starting a round nobody can answer")
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 1000, "Bob": 1000})
+}
+
 func TestStartGame(t *testing.T) {
 	game := createMockGame()
 
@@ -263,3 +405,325 @@ func TestGetPlayers(t *testing.T) {
 		}
 	}
 }
+
+func TestQuestionPool(t *testing.T) {
+	questions := []Question{MyQuestion{}, MyQuestion{}, MyQuestion{}, MyQuestion{}}
+	game := CreateGame(GameSettings{
+		Quiz:             MockQuiz{questions: questions},
+		ShuffleQuestions: true,
+		QuestionPoolSize: 2,
+		RoundSettings: RoundSettings{
+			ReadingTime: 1 * time.Second,
+			AnswerTime:  1 * time.Second,
+		},
+	}, newTestClock())
+
+	if count := game.QuestionsCount(); count != 2 {
+		t.Errorf("Expected 2 questions, got %d", count)
+	}
+
+	if err := game.AddPlayer("Jack"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	first, second := game.QuizQuestionIdx(0), game.QuizQuestionIdx(1)
+	if first < 0 || first >= len(questions) || second < 0 || second >= len(questions) || first == second {
+		t.Errorf("Expected two distinct questions of the quiz, got %d and %d", first, second)
+	}
+
+	if err := game.FinishRoundEarly(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.FinishRoundEarly(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.StartNextRound(); err != (ErrNoMoreQuestions{}) {
+		t.Errorf("Expected no more questions error, got: %v", err)
+	}
+}
+
+func TestQuestionPoolKeepsOrder(t *testing.T) {
+	game := CreateGame(GameSettings{
+		Quiz:             MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}, MyQuestion{}, MyQuestion{}, MyQuestion{}}},
+		QuestionPoolSize: 3,
+	}, newTestClock())
+
+	if err := game.AddPlayer("Jack"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for round := 1; round < game.QuestionsCount(); round++ {
+		if game.QuizQuestionIdx(round-1) >= game.QuizQuestionIdx(round) {
+			t.Errorf("Expected questions drawn from the pool to keep the quiz order")
+		}
+	}
+}
+
+func TestQuestionOrder(t *testing.T) {
+	game := CreateGame(GameSettings{
+		Quiz:             MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}, MyQuestion{}, MyQuestion{}}},
+		ShuffleQuestions: true,
+		QuestionOrder:    []int{3, 0},
+	}, newTestClock())
+
+	if count := game.QuestionsCount(); count != 2 {
+		t.Errorf("Expected 2 questions, got %d", count)
+	}
+
+	if err := game.AddPlayer("Jack"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first, second := game.QuizQuestionIdx(0), game.QuizQuestionIdx(1); first != 3 || second != 0 {
+		t.Errorf("Expected the questions 3 and 0, got %d and %d", first, second)
+	}
+}
+
+func TestInvalidQuestionOrder(t *testing.T) {
+	for _, order := range [][]int{{0, 0}, {-1}, {2}} {
+		game := createMockGame()
+		settings := game.Settings()
+		settings.QuestionOrder = order
+		if err := game.UpdateSettings(settings); err == nil {
+			t.Errorf("Expected error for question order %v, got nil", order)
+		}
+	}
+}
+
+func TestInvalidQuestionPool(t *testing.T) {
+	game := createMockGame()
+	settings := game.Settings()
+	settings.QuestionPoolSize = -1
+	if err := game.UpdateSettings(settings); err == nil {
+		t.Errorf("Expected error for negative question pool size, got nil")
+	}
+}
+
+func TestInvalidAutoAdvance(t *testing.T) {
+	game := createMockGame()
+	settings := game.Settings()
+	settings.AutoAdvance = -time.Second
+	if err := game.UpdateSettings(settings); err == nil {
+		t.Errorf("Expected error for negative auto advance pause, got nil")
+	}
+
+	settings = game.Settings()
+	settings.HostGracePeriod = -time.Second
+	if err := game.UpdateSettings(settings); err == nil {
+		t.Errorf("Expected error for negative host grace period, got nil")
+	}
+}
+
+func TestGameScores(t *testing.T) {
+	clock := newTestClock()
+	game := CreateGame(GameSettings{
+		Quiz: MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}}},
+		RoundSettings: RoundSettings{
+			ReadingTime: 5 * time.Second,
+			AnswerTime:  10 * time.Second,
+		},
+	}, clock)
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !game.StartedAt().Equal(clock.Now()) {
+		t.Errorf("Expected game to start at the time of the clock")
+	}
+
+	// Round 1: Alice answers immediately, Bob after 5 seconds
+	clock.Advance(5 * time.Second)
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(5 * time.Second)
+	if err := game.SubmitAnswer("Bob", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 1000, "Bob": 750})
+
+	// Round 2: only Alice answers, the round times out
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(7 * time.Second)
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(8 * time.Second)
+	waitForScores(t, game, map[Username]int{"Alice": 1900, "Bob": 750})
+}
+
+func TestLateJoin(t *testing.T) {
+	clock := newTestClock()
+	game := CreateGame(GameSettings{
+		Quiz:          MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}}},
+		AllowLateJoin: true,
+		RoundSettings: RoundSettings{
+			ReadingTime: 5 * time.Second,
+			AnswerTime:  10 * time.Second,
+		},
+	}, clock)
+	if err := game.AddPlayer("Alice"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Bob joins during the first round and has to answer it too
+	clock.Advance(5 * time.Second)
+	if err := game.AddPlayer("Bob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if answering := game.Round.PlayersAnswering(); answering != 2 {
+		t.Errorf("Expected 2 players answering, got %d", answering)
+	}
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if game.Round.HasFinished() {
+		t.Fatal("Expected the round to wait for the late player")
+	}
+
+	// The time to answer is measured from the end of the reading time, not from joining
+	clock.Advance(5 * time.Second)
+	if err := game.SubmitAnswer("Bob", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 1000, "Bob": 750})
+
+	// Charlie joins between rounds, misses the first one and plays the next one
+	if err := game.AddPlayer("Charlie"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(5 * time.Second)
+	for _, player := range []Username{"Alice", "Bob", "Charlie"} {
+		if err := game.SubmitAnswer(player, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 2000, "Bob": 1750, "Charlie": 1000})
+
+	if err := game.Finish(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.AddPlayer("Dave"); !errors.As(err, &ErrGameFinished{}) {
+		t.Errorf("Expected ErrGameFinished, got %v", err)
+	}
+}
+
+func TestPlayerAway(t *testing.T) {
+	clock := newTestClock()
+	game := CreateGame(GameSettings{
+		Quiz: MockQuiz{questions: []Question{MyQuestion{}, MyQuestion{}, MyQuestion{}}},
+		RoundSettings: RoundSettings{
+			AnswerTime: 10 * time.Second,
+		},
+	}, clock)
+	for _, player := range []Username{"Alice", "Bob"} {
+		if err := game.AddPlayer(player); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := game.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Round 1: Bob is away, the round doesn't wait for him
+	if err := game.SetPlayerAway("Bob", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if answering := game.Round.PlayersAnswering(); answering != 1 {
+		t.Errorf("Expected 1 player answering, got %d", answering)
+	}
+	if game.Round.HasFinished() {
+		t.Fatal("Expected the round not to finish before anyone answered")
+	}
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !game.Round.HasFinished() {
+		t.Fatal("Expected the round to finish once every connected player answered")
+	}
+
+	// Round 2: Bob is still away, comes back and answers
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if answering := game.Round.PlayersAnswering(); answering != 1 {
+		t.Errorf("Expected away players to stay away in new rounds, got %d answering", answering)
+	}
+	if err := game.SetPlayerAway("Bob", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if game.Round.HasFinished() {
+		t.Fatal("Expected the round to wait for the returned player")
+	}
+	if err := game.SubmitAnswer("Bob", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Round 3: the last player left to answer leaves
+	if err := game.StartNextRound(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.SubmitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := game.SetPlayerAway("Bob", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !game.Round.HasFinished() {
+		t.Fatal("Expected the round to finish when the last player left to answer leaves")
+	}
+	waitForScores(t, game, map[Username]int{"Alice": 3000, "Bob": 1000})
+
+	if err := game.SetPlayerAway("Nobody", true); err == nil {
+		t.Error("Expected error for a player not in the game")
+	}
+}
+
+// waitForScores waits until the points of a finished round are added to the game
+func waitForScores(t *testing.T, game Game, expected map[Username]int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		scores := game.Scores()
+		matching := len(scores) == len(expected)
+		for player, points := range expected {
+			matching = matching && scores[player] == points
+		}
+		if matching {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected scores %v, got %v", expected, scores)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
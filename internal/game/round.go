@@ -3,43 +3,141 @@ package game
 import (
 	"errors"
 	"log/slog"
+	"math/rand"
 	"strconv"
 	"sync"
 	"time"
 )
 
 var ErrRoundAlreadyEnded = errors.New("Round already ended")
+var ErrRoundPaused = errors.New("Round is paused")
+var ErrRoundNotPaused = errors.New("Round is not paused")
+
+// MaxPointsPerQuestion is awarded for a correct answer given in less than 500ms
+const MaxPointsPerQuestion = 1000
+
+// AnswerShuffle defines if and how the answers of a question are shuffled
+type AnswerShuffle int
+
+const (
+	AnswerShuffleOff       AnswerShuffle = iota // Answers are shown in the order they are stored
+	AnswerShufflePerGame                        // All players see the same shuffled order
+	AnswerShufflePerPlayer                      // Every player sees their own shuffled order
+)
+
+func (s AnswerShuffle) IsValid() bool {
+	return s >= AnswerShuffleOff && s <= AnswerShufflePerPlayer
+}
 
 type RoundSettings struct {
-	ReadingTime time.Duration
-	AnswerTime  time.Duration
+	ReadingTime   time.Duration
+	AnswerTime    time.Duration
+	AnswerShuffle AnswerShuffle
 }
 
 type Round struct {
-	mu       sync.RWMutex
-	question Question
-	startAt  time.Time
-	endedAt  time.Time
-	players  map[Username]bool
-	answers  map[Username]roundAnswer
-	finished chan struct{} // channel that closes once a round has finished
-	settings RoundSettings
+	mu          sync.RWMutex
+	clock       Clock
+	question    Question
+	startAt     time.Time
+	endedAt     time.Time
+	players     map[Username]bool
+	away        map[Username]bool        // Players who can't answer right now, e.g. they lost the connection
+	answers     map[Username]roundAnswer // Index of the answers is the one in Question.Answers
+	finished    chan struct{}            // channel that closes once a round has finished
+	settings    RoundSettings
+	sharedOrder []int              // Shown answer index -> question answer index, nil if not shuffled
+	playerOrder map[Username][]int // Same as sharedOrder, but for every player
+
+	// Timer adjustments made by the host
+	pausedAt     time.Time     // Zero if the round isn't paused
+	readingShift time.Duration // How much the end of the reading time has been moved
+	timeoutShift time.Duration // How much the end of the round has been moved
+	answerPauses time.Duration // Time the round was paused while players were answering
+	timerChanged chan struct{} // Notifies the timer goroutine about adjustments
 }
 
-func CreateRound(players []Username, question Question, settings RoundSettings) *Round {
+// CreateRound creates a round that is timed using the clock, a nil clock uses SystemClock
+func CreateRound(players []Username, question Question, settings RoundSettings, clock Clock) *Round {
+	if clock == nil {
+		clock = SystemClock
+	}
+
 	round := Round{
-		question: question,
-		settings: settings,
-		finished: make(chan struct{}),
-		players:  make(map[Username]bool),
-		answers:  make(map[Username]roundAnswer),
+		clock:        clock,
+		question:     question,
+		settings:     settings,
+		finished:     make(chan struct{}),
+		players:      make(map[Username]bool),
+		away:         make(map[Username]bool),
+		answers:      make(map[Username]roundAnswer),
+		playerOrder:  make(map[Username][]int),
+		timerChanged: make(chan struct{}, 1),
+	}
+	answersCount := len(question.Answers())
+	if settings.AnswerShuffle == AnswerShufflePerGame {
+		round.sharedOrder = rand.Perm(answersCount)
 	}
 	for _, player := range players {
 		round.players[player] = true
+		if settings.AnswerShuffle == AnswerShufflePerPlayer {
+			round.playerOrder[player] = rand.Perm(answersCount)
+		}
 	}
 	return &round
 }
 
+// answerOrder returns the mapping from the answer index shown to the player
+// to the index in Question.Answers, nil means the answers aren't shuffled
+// Thread unsafe
+func (round *Round) answerOrder(player Username) []int {
+	if order, ok := round.playerOrder[player]; ok {
+		return order
+	}
+	return round.sharedOrder
+}
+
+// AnswersFor returns the answers of the question in the order shown to the player
+func (round *Round) AnswersFor(player Username) []Answer {
+	round.mu.RLock()
+	defer round.mu.RUnlock()
+
+	answers := round.question.Answers()
+	order := round.answerOrder(player)
+	if order == nil {
+		return answers
+	}
+
+	shuffled := make([]Answer, len(order))
+	for shownIdx, answerIdx := range order {
+		shuffled[shownIdx] = answers[answerIdx]
+	}
+	return shuffled
+}
+
+// SubmittedAnswerIdx returns the index (as shown to the player) of the answer
+// submitted by the player, -1 if the player hasn't answered
+func (round *Round) SubmittedAnswerIdx(player Username) int {
+	round.mu.RLock()
+	defer round.mu.RUnlock()
+
+	answer, ok := round.answers[player]
+	if !ok {
+		return -1
+	}
+
+	order := round.answerOrder(player)
+	if order == nil {
+		return answer.Index
+	}
+	for shownIdx, answerIdx := range order {
+		if answerIdx == answer.Index {
+			return shownIdx
+		}
+	}
+	return -1
+}
+
 func (round *Round) start() error {
 	round.mu.Lock()
 	defer round.mu.Unlock()
@@ -52,28 +150,174 @@ func (round *Round) start() error {
 		return ErrRoundAlreadyEnded
 	}
 
-	round.startAt = time.Now()
+	round.startAt = round.clock.Now()
 
-	go func() {
-		select {
-		case <-time.After(round.settings.ReadingTime + round.settings.AnswerTime):
-			round.mu.Lock()
-			defer round.mu.Unlock()
+	go round.runTimer()
 
-			err := round.finishRound()
-			if err != nil {
-				slog.Error("Error finishing while round after timer", "err", err)
+	return nil
+}
+
+// runTimer finishes the round once its (possibly adjusted) timeout is reached
+func (round *Round) runTimer() {
+	for {
+		round.mu.Lock()
+		if !round.endedAt.IsZero() {
+			round.mu.Unlock()
+			return
+		}
+
+		// A paused round only waits for being resumed
+		var timer <-chan time.Time
+		if round.pausedAt.IsZero() {
+			remaining := round.timeout().Sub(round.clock.Now())
+			if remaining <= 0 {
+				err := round.finishRound()
+				if err != nil {
+					slog.Error("Error finishing while round after timer", "err", err)
+				}
+				round.mu.Unlock()
+				return
 			}
+			timer = round.clock.After(remaining)
+		}
+		round.mu.Unlock()
 
+		select {
+		case <-timer:
+		case <-round.timerChanged:
 		case <-round.finished:
 			// Round was ended early
 			return
 		}
-	}()
+	}
+}
 
+// notifyTimer wakes up the timer goroutine so it picks up the adjusted timeout
+// Thread unsafe
+func (round *Round) notifyTimer() {
+	select {
+	case round.timerChanged <- struct{}{}:
+	default:
+		// Goroutine has already been notified
+	}
+}
+
+// ongoingPause returns for how long the round has been paused, 0 if it isn't paused
+// Thread unsafe
+func (round *Round) ongoingPause() time.Duration {
+	if round.pausedAt.IsZero() {
+		return 0
+	}
+	return round.clock.Now().Sub(round.pausedAt)
+}
+
+// pausedWhileReading reports whether the ongoing pause started during the reading time
+// Thread unsafe
+func (round *Round) pausedWhileReading() bool {
+	readingEnd := round.startAt.Add(round.settings.ReadingTime + round.readingShift)
+	return !round.pausedAt.IsZero() && round.pausedAt.Before(readingEnd)
+}
+
+// Thread unsafe
+func (round *Round) readingTimeout() time.Time {
+	timeout := round.startAt.Add(round.settings.ReadingTime + round.readingShift)
+	if round.pausedWhileReading() {
+		timeout = timeout.Add(round.ongoingPause())
+	}
+	return timeout
+}
+
+// Thread unsafe
+func (round *Round) timeout() time.Time {
+	duration := round.settings.ReadingTime + round.settings.AnswerTime + round.timeoutShift
+	return round.startAt.Add(duration + round.ongoingPause())
+}
+
+// Pause stops the round timer until the round is resumed
+// Answers can't be submitted while the round is paused
+func (round *Round) Pause() error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if round.startAt.IsZero() {
+		return errors.New("round has not started")
+	}
+	if !round.endedAt.IsZero() {
+		return ErrRoundAlreadyEnded
+	}
+	if !round.pausedAt.IsZero() {
+		return ErrRoundPaused
+	}
+
+	round.pausedAt = round.clock.Now()
+	round.notifyTimer()
 	return nil
 }
 
+// Resume continues a paused round, its deadlines are moved by the length of the pause
+func (round *Round) Resume() error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if !round.endedAt.IsZero() {
+		return ErrRoundAlreadyEnded
+	}
+	if round.pausedAt.IsZero() {
+		return ErrRoundNotPaused
+	}
+
+	pause := round.ongoingPause()
+	if round.pausedWhileReading() {
+		round.readingShift += pause
+	} else {
+		round.answerPauses += pause
+	}
+	round.timeoutShift += pause
+	round.pausedAt = time.Time{}
+
+	round.notifyTimer()
+	return nil
+}
+
+// Extend gives the players extra time for answering
+func (round *Round) Extend(d time.Duration) error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if d <= 0 {
+		return errors.New("extension must be positive")
+	}
+	if round.startAt.IsZero() {
+		return errors.New("round has not started")
+	}
+	if !round.endedAt.IsZero() {
+		return ErrRoundAlreadyEnded
+	}
+
+	round.timeoutShift += d
+	round.notifyTimer()
+	return nil
+}
+
+func (round *Round) IsPaused() bool {
+	round.mu.RLock()
+	defer round.mu.RUnlock()
+	return !round.pausedAt.IsZero()
+}
+
+// Remaining returns the time left until the round times out
+func (round *Round) Remaining() time.Duration {
+	round.mu.RLock()
+	defer round.mu.RUnlock()
+	return max(0, round.timeout().Sub(round.clock.Now()))
+}
+
+// answerTime returns the time players have for answering, including extensions
+// Thread unsafe
+func (round *Round) answerTime() time.Duration {
+	return round.settings.AnswerTime + round.timeoutShift - round.readingShift - round.answerPauses
+}
+
 func (round *Round) Question() Question {
 	round.mu.RLock()
 	defer round.mu.RUnlock()
@@ -98,17 +342,18 @@ func (round *Round) HasStarted() bool {
 	return !round.startAt.IsZero()
 }
 
+// ReadingTimeout returns when the players can start answering, including pauses
 func (round *Round) ReadingTimeout() time.Time {
 	round.mu.RLock()
 	defer round.mu.RUnlock()
-	return round.startAt.Add(round.settings.ReadingTime)
+	return round.readingTimeout()
 }
 
+// Timeout returns when the round ends, including pauses and extensions
 func (round *Round) Timeout() time.Time {
 	round.mu.RLock()
 	defer round.mu.RUnlock()
-	duration := round.settings.ReadingTime + round.settings.AnswerTime
-	return round.startAt.Add(duration)
+	return round.timeout()
 }
 
 // FinishEarly closes the finished channel, effectively ending the round early
@@ -125,7 +370,7 @@ func (round *Round) finishRound() error {
 		return ErrRoundAlreadyEnded
 	}
 
-	round.endedAt = time.Now()
+	round.endedAt = round.clock.Now()
 	close(round.finished)
 	return nil
 }
@@ -142,18 +387,52 @@ func (round *Round) HasFinished() bool {
 	return !round.endedAt.IsZero()
 }
 
+// addPlayer adds a player who joined the game while the round is running
+// The late player plays the round like everyone else: they count in PlayersAnswering,
+// so the round only finishes early once they have answered too, and their time to answer
+// is measured from the end of the reading time, so joining late gives no extra time
+func (round *Round) addPlayer(player Username) error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if !round.endedAt.IsZero() {
+		return ErrRoundAlreadyEnded
+	}
+
+	if round.players[player] {
+		return errors.New("player already in round")
+	}
+
+	round.players[player] = true
+	if round.settings.AnswerShuffle == AnswerShufflePerPlayer {
+		round.playerOrder[player] = rand.Perm(len(round.question.Answers()))
+	}
+	return nil
+}
+
 type roundAnswer struct {
-	Index       int
-	SubmittedAt time.Time
+	Index        int
+	SubmittedAt  time.Time
+	TimeToAnswer time.Duration // Time from the end of reading until submitting, without pauses
 }
 
+// submitAnswer submits the answer of the player
+// answerIndex is the index of the answer as shown to the player (see AnswersFor)
 func (round *Round) submitAnswer(player Username, answerIndex int) error {
 	round.mu.Lock()
 	defer round.mu.Unlock()
 
+	// Map the shown answer back to the answer of the question
+	if order := round.answerOrder(player); order != nil {
+		if answerIndex < 0 || answerIndex >= len(order) {
+			return errors.New("invalid answer index")
+		}
+		answerIndex = order[answerIndex]
+	}
+
 	rAnswer := roundAnswer{
 		Index:       answerIndex,
-		SubmittedAt: time.Now(),
+		SubmittedAt: round.clock.Now(),
 	}
 
 	// Check if index is valid
@@ -170,9 +449,14 @@ func (round *Round) submitAnswer(player Username, answerIndex int) error {
 		return errors.New("round has not started")
 	}
 
-	if rAnswer.SubmittedAt.Before(round.startAt.Add(round.settings.ReadingTime)) {
+	if !round.pausedAt.IsZero() {
+		return ErrRoundPaused
+	}
+
+	if rAnswer.SubmittedAt.Before(round.readingTimeout()) {
 		return errors.New("answer submitted before answering allowed")
 	}
+	rAnswer.TimeToAnswer = rAnswer.SubmittedAt.Sub(round.readingTimeout()) - round.answerPauses
 
 	// Check if answer was submitted after the round ended
 	if !round.endedAt.IsZero() {
@@ -188,7 +472,7 @@ func (round *Round) submitAnswer(player Username, answerIndex int) error {
 	round.answers[player] = rAnswer
 
 	// If all players have answered, finish the round
-	if len(round.answers) == len(round.players) {
+	if round.allAnswered() {
 		err := round.finishRound()
 		if err != nil {
 			slog.Error("Error finishing round after all players answered", "err", err)
@@ -198,10 +482,88 @@ func (round *Round) submitAnswer(player Username, answerIndex int) error {
 	return nil
 }
 
+// allAnswered reports whether the round can finish early:
+// at least one player has answered and so did every player who isn't away
+// Thread unsafe
+func (round *Round) allAnswered() bool {
+	if len(round.answers) == 0 {
+		return false
+	}
+
+	for player := range round.players {
+		if _, answered := round.answers[player]; !answered && !round.away[player] {
+			return false
+		}
+	}
+	return true
+}
+
+// removePlayer removes the player and their answer from the round
+// If the player was the last one left to answer, the round finishes
+func (round *Round) removePlayer(player Username) error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if !round.endedAt.IsZero() {
+		return ErrRoundAlreadyEnded
+	}
+
+	if !round.players[player] {
+		return errors.New("player not in round")
+	}
+
+	delete(round.players, player)
+	delete(round.answers, player)
+	delete(round.away, player)
+	delete(round.playerOrder, player)
+
+	if !round.startAt.IsZero() && round.allAnswered() {
+		err := round.finishRound()
+		if err != nil {
+			slog.Error("Error finishing round after player was removed", "err", err)
+		}
+	}
+	return nil
+}
+
+// setAway marks the player as away (or back), away players aren't waited for
+// If the player leaves while being the last one to answer, the round finishes
+func (round *Round) setAway(player Username, away bool) error {
+	round.mu.Lock()
+	defer round.mu.Unlock()
+
+	if !round.players[player] {
+		return errors.New("player not in round")
+	}
+
+	if !away {
+		delete(round.away, player)
+		return nil
+	}
+
+	round.away[player] = true
+	if !round.startAt.IsZero() && round.endedAt.IsZero() && round.allAnswered() {
+		err := round.finishRound()
+		if err != nil {
+			slog.Error("Error finishing round after player left", "err", err)
+		}
+	}
+	return nil
+}
+
+// PlayersAnswering returns the number of players in the round who haven't answered yet,
+// including players who joined after the round started and excluding players who are away
 func (round *Round) PlayersAnswering() int {
 	round.mu.RLock()
 	defer round.mu.RUnlock()
-	return len(round.players) - len(round.answers)
+
+	answering := 0
+	for player := range round.players {
+		if _, answered := round.answers[player]; !answered && !round.away[player] {
+			answering++
+		}
+	}
+	return answering
 }
 
 func (round *Round) PlayerAnswers() map[Username]roundAnswer {
@@ -226,12 +588,12 @@ func (round *Round) Results() (map[Username]int, error) {
 		pointsAwarded := 0
 
 		if hasAnswered && round.question.IsAnswerCorrect(answer.Index) {
-			time2Answer := answer.SubmittedAt.Sub(round.startAt.Add(round.settings.ReadingTime))
+			time2Answer := answer.TimeToAnswer
 			if time2Answer < time.Millisecond*500 {
 				// Maximum points for answering in less than 500ms
-				pointsAwarded = 1000
+				pointsAwarded = MaxPointsPerQuestion
 			} else {
-				pointsAwarded = int((1 - (float64(time2Answer) / float64(round.settings.AnswerTime) / 2.0)) * 1000)
+				pointsAwarded = int((1 - (float64(time2Answer) / float64(round.answerTime()) / 2.0)) * MaxPointsPerQuestion)
 			}
 		}
 		scores[username] = pointsAwarded
@@ -8,6 +8,10 @@ import (
 // Mock MyQuestion struct
 type MyQuestion struct{}
 
+func (q MyQuestion) Prompt() string {
+	return "Question"
+}
+
 func (q MyQuestion) IsAnswerCorrect(index int) bool {
 	return index == 1 // Mock implementation
 }
@@ -36,7 +40,7 @@ func TestCreateRound(t *testing.T) {
 		AnswerTime:  10 * time.Second,
 	}
 
-	round := CreateRound(players, question, settings)
+	round := CreateRound(players, question, settings, newTestClock())
 
 	if len(round.players) != len(players) {
 		t.Errorf("Expected %d players, got %d", len(players), len(round.players))
@@ -57,7 +61,7 @@ func TestStart(t *testing.T) {
 		AnswerTime:  3 * time.Second,
 	}
 
-	round := CreateRound(players, question, settings)
+	round := CreateRound(players, question, settings, newTestClock())
 
 	if err := round.start(); err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -76,7 +80,7 @@ func TestFinishEarly(t *testing.T) {
 		AnswerTime:  10 * time.Second,
 	}
 
-	round := CreateRound(players, question, settings)
+	round := CreateRound(players, question, settings, newTestClock())
 	if err := round.FinishEarly(); err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -95,7 +99,8 @@ func TestSubmitAnswer(t *testing.T) {
 		AnswerTime:  2 * time.Second,
 	}
 
-	round := CreateRound(players, question, settings)
+	clock := newTestClock()
+	round := CreateRound(players, question, settings, clock)
 	err := round.start()
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -105,7 +110,7 @@ func TestSubmitAnswer(t *testing.T) {
 		t.Errorf("Expected error for submitting answer during reading time, got nil")
 	}
 
-	time.Sleep(settings.ReadingTime)
+	clock.Advance(settings.ReadingTime)
 
 	if err := round.submitAnswer("Alice", 1); err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -125,15 +130,17 @@ func TestGetResults(t *testing.T) {
 	question := MyQuestion{}
 	settings := RoundSettings{
 		ReadingTime: 0,
-		AnswerTime:  100 * time.Millisecond,
+		AnswerTime:  10 * time.Second,
 	}
 
-	round := CreateRound(players, question, settings)
+	clock := newTestClock()
+	round := CreateRound(players, question, settings, clock)
 	err := round.start()
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
 
+	clock.Advance(2 * time.Second)
 	err = round.submitAnswer("Alice", 1)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -144,7 +151,8 @@ func TestGetResults(t *testing.T) {
 		t.Errorf("Expected error for getting results before round ends, got nil")
 	}
 
-	time.Sleep(settings.ReadingTime + settings.AnswerTime + 100*time.Millisecond)
+	clock.Advance(settings.AnswerTime)
+	expectFinished(t, round, true)
 
 	results, err := round.Results()
 	if err != nil {
@@ -155,8 +163,60 @@ func TestGetResults(t *testing.T) {
 		t.Errorf("Expected %d results, got %d", len(players), len(results))
 	}
 
-	if results["Alice"] == 0 {
-		t.Errorf("Expected Alice to have points, got 0")
+	if results["Alice"] != 900 {
+		t.Errorf("Expected Alice to have 900 points, got %d", results["Alice"])
+	}
+
+	if results["Bob"] != 0 {
+		t.Errorf("Expected Bob to have 0 points, got %d", results["Bob"])
+	}
+}
+
+func TestScoring(t *testing.T) {
+	tests := []struct {
+		timeToAnswer time.Duration
+		correct      bool
+		expected     int
+	}{
+		{0, true, 1000},
+		{499 * time.Millisecond, true, 1000},
+		{500 * time.Millisecond, true, 975},
+		{2 * time.Second, true, 900},
+		{5 * time.Second, true, 750},
+		{10 * time.Second, true, 500},
+		{2 * time.Second, false, 0},
+	}
+
+	for _, tt := range tests {
+		clock := newTestClock()
+		round := CreateRound([]Username{"Alice", "Bob"}, MyQuestion{}, RoundSettings{
+			ReadingTime: 3 * time.Second,
+			AnswerTime:  10 * time.Second,
+		}, clock)
+		if err := round.start(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		answer := 1
+		if !tt.correct {
+			answer = 0
+		}
+
+		clock.Advance(3*time.Second + tt.timeToAnswer)
+		if err := round.submitAnswer("Alice", answer); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := round.FinishEarly(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		results, err := round.Results()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if results["Alice"] != tt.expected {
+			t.Errorf("Answering (correct: %v) after %v: expected %d points, got %d", tt.correct, tt.timeToAnswer, tt.expected, results["Alice"])
+		}
 	}
 }
 
@@ -168,17 +228,258 @@ func TestFinished(t *testing.T) {
 		AnswerTime:  200 * time.Millisecond,
 	}
 
-	round := CreateRound(players, question, settings)
+	clock := newTestClock()
+	round := CreateRound(players, question, settings, clock)
 
-	startTime := time.Now()
 	err := round.start()
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
 
-	<-round.Finished()
+	clock.Advance(settings.ReadingTime + settings.AnswerTime - time.Millisecond)
+	expectFinished(t, round, false)
+
+	clock.Advance(time.Millisecond)
+	expectFinished(t, round, true)
+
+	if round.endedAt.Sub(round.startAt) != settings.ReadingTime+settings.AnswerTime {
+		t.Errorf("Expected round to finish after ReadingTime + AnswerTime, got %v", round.endedAt.Sub(round.startAt))
+	}
+}
+
+// Mock question with distinguishable answers
+type textQuestion struct {
+	answers []string
+	correct int
+}
+
+type textAnswer string
+
+func (a textAnswer) Text() string {
+	return string(a)
+}
+
+func (q textQuestion) Prompt() string {
+	return "Which letter?"
+}
+
+func (q textQuestion) IsAnswerCorrect(index int) bool {
+	return index == q.correct
+}
+
+func (q textQuestion) IsAnswerValid(index int) bool {
+	return index > -1 && index < len(q.answers)
+}
+
+func (q textQuestion) Answers() []Answer {
+	answers := make([]Answer, len(q.answers))
+	for i, answer := range q.answers {
+		answers[i] = textAnswer(answer)
+	}
+	return answers
+}
+
+func TestAnswerShuffle(t *testing.T) {
+	for _, shuffle := range []AnswerShuffle{AnswerShuffleOff, AnswerShufflePerGame, AnswerShufflePerPlayer} {
+		players := []Username{"Alice", "Bob", "Charlie"}
+		question := textQuestion{answers: []string{"A", "B", "C", "D", "E", "F"}, correct: 2}
+		round := CreateRound(players, question, RoundSettings{
+			AnswerTime:    10 * time.Second,
+			AnswerShuffle: shuffle,
+		}, newTestClock())
+
+		if err := round.start(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, player := range players {
+			answers := round.AnswersFor(player)
+			if len(answers) != len(question.answers) {
+				t.Fatalf("Expected %d answers, got %d", len(question.answers), len(answers))
+			}
+
+			// Submit the correct answer as shown to the player
+			shownIdx := -1
+			for i, answer := range answers {
+				if answer.Text() == "C" {
+					shownIdx = i
+				}
+			}
+			if shownIdx == -1 {
+				t.Fatalf("Correct answer not shown to %s", player)
+			}
+
+			if err := round.submitAnswer(player, shownIdx); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := round.SubmittedAnswerIdx(player); got != shownIdx {
+				t.Errorf("Expected submitted answer index %d, got %d", shownIdx, got)
+			}
+
+			// Stored answers use the order of the question
+			if got := round.Answers()[player].Index; got != question.correct {
+				t.Errorf("Expected stored answer index %d, got %d", question.correct, got)
+			}
+		}
 
-	if time.Since(startTime).Round(100*time.Millisecond) != settings.ReadingTime+settings.AnswerTime {
-		t.Errorf("Expected round to finish after ReadingTime + AnswerTime, got %v", time.Since(startTime))
+		// The round ends once every player has answered
+		results, err := round.Results()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, player := range players {
+			if results[player] == 0 {
+				t.Errorf("Expected %s to score points with answer shuffle %d", player, shuffle)
+			}
+		}
+	}
+}
+
+// newTestClock returns a fake clock, so tests don't depend on real time passing
+func newTestClock() *FakeClock {
+	return NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
+// expectFinished checks whether the round timer has (not) finished the round
+func expectFinished(t *testing.T, round *Round, finished bool) {
+	t.Helper()
+	wait := 10 * time.Millisecond
+	if finished {
+		wait = time.Second
+	}
+
+	select {
+	case <-round.Finished():
+		if !finished {
+			t.Fatalf("Expected round to still be running")
+		}
+	case <-time.After(wait):
+		if finished {
+			t.Fatalf("Expected round to be finished")
+		}
+	}
+}
+
+func TestPauseResumeExtend(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	round := CreateRound([]Username{"Alice", "Bob"}, MyQuestion{}, RoundSettings{
+		ReadingTime: 5 * time.Second,
+		AnswerTime:  10 * time.Second,
+	}, clock)
+
+	if err := round.Pause(); err == nil {
+		t.Errorf("Expected error for pausing a round that has not started")
+	}
+	if err := round.start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Pause while the players are answering
+	clock.Advance(6 * time.Second)
+	if err := round.Pause(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := round.Pause(); err != ErrRoundPaused {
+		t.Errorf("Expected ErrRoundPaused, got %v", err)
+	}
+	if err := round.submitAnswer("Alice", 1); err != ErrRoundPaused {
+		t.Errorf("Expected ErrRoundPaused for answering while paused, got %v", err)
+	}
+	if remaining := round.Remaining(); remaining != 9*time.Second {
+		t.Errorf("Expected 9s remaining, got %v", remaining)
+	}
+
+	clock.Advance(time.Minute)
+	expectFinished(t, round, false)
+	if remaining := round.Remaining(); remaining != 9*time.Second {
+		t.Errorf("Expected remaining time to be frozen at 9s, got %v", remaining)
+	}
+
+	if err := round.Resume(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := round.Resume(); err != ErrRoundNotPaused {
+		t.Errorf("Expected ErrRoundNotPaused, got %v", err)
+	}
+	if got := round.ReadingTimeout(); !got.Equal(start.Add(5 * time.Second)) {
+		t.Errorf("Expected reading timeout to stay unchanged, got %v", got.Sub(start))
+	}
+	if got := round.Timeout(); !got.Equal(start.Add(75 * time.Second)) {
+		t.Errorf("Expected timeout to move by the pause, got %v", got.Sub(start))
+	}
+
+	// The pause doesn't count towards the time it took to answer
+	clock.Advance(time.Second)
+	if err := round.submitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := round.Answers()["Alice"].TimeToAnswer; got != 2*time.Second {
+		t.Errorf("Expected time to answer of 2s, got %v", got)
+	}
+
+	if err := round.Extend(10 * time.Second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := round.Timeout(); !got.Equal(start.Add(85 * time.Second)) {
+		t.Errorf("Expected timeout to move by the extension, got %v", got.Sub(start))
+	}
+
+	clock.Advance(17 * time.Second)
+	expectFinished(t, round, false)
+	clock.Advance(time.Second)
+	expectFinished(t, round, true)
+
+	if err := round.Extend(time.Second); err != ErrRoundAlreadyEnded {
+		t.Errorf("Expected ErrRoundAlreadyEnded, got %v", err)
+	}
+}
+
+func TestPauseWhileReading(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	round := CreateRound([]Username{"Alice", "Bob"}, MyQuestion{}, RoundSettings{
+		ReadingTime: 5 * time.Second,
+		AnswerTime:  10 * time.Second,
+	}, clock)
+	if err := round.start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock.Advance(2 * time.Second)
+	if err := round.Pause(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(10 * time.Second)
+	if err := round.Resume(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := round.ReadingTimeout(); !got.Equal(start.Add(15 * time.Second)) {
+		t.Errorf("Expected reading timeout to move by the pause, got %v", got.Sub(start))
+	}
+	if got := round.Timeout(); !got.Equal(start.Add(25 * time.Second)) {
+		t.Errorf("Expected timeout to move by the pause, got %v", got.Sub(start))
+	}
+
+	if err := round.submitAnswer("Alice", 1); err == nil {
+		t.Errorf("Expected error for answering during the reading time")
+	}
+
+	clock.Advance(3 * time.Second)
+	if err := round.submitAnswer("Alice", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := round.FinishEarly(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	results, err := round.Results()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if results["Alice"] != 1000 {
+		t.Errorf("Expected 1000 points for answering immediately, got %d", results["Alice"])
 	}
 }
@@ -0,0 +1,459 @@
+package lobbies
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/webhooks"
+	"github.com/gorilla/websocket"
+)
+
+// Cluster connects the kwikquiz instances sharing lobbies
+// Every lobby runs on the instance that created it. Clients connected to another instance
+// are proxied to it: their messages are published to the lobby's topic and views come back on the connection's topic.
+// Websockets and event streams are proxied as connections, the settings and the posted events as single requests
+type Cluster struct {
+	InstanceID string
+	Directory  Directory
+	PubSub     PubSub
+}
+
+// NewLocalCluster returns a cluster of a single instance, it is used when kwikquiz runs as one process
+func NewLocalCluster() Cluster {
+	return Cluster{
+		InstanceID: newRandomID(),
+		Directory:  NewDirectoryInMemory(),
+		PubSub:     NewLocalPubSub(),
+	}
+}
+
+// Kinds of cluster messages
+const (
+	// Sent by the proxying instance to the lobby's topic
+	msgConnect    = "connect"
+	msgEvent      = "event"
+	msgDisconnect = "disconnect"
+	msgRequest    = "request"
+	// Sent by the instance running the lobby to the connection's topic
+	msgView     = "view"
+	msgClose    = "close"
+	msgResponse = "response"
+)
+
+// Maximum size of the body of a proxied request, the largest is the settings form
+const maxProxiedBody = 64 * 1024
+
+// How long the instance running the lobby has to respond to a proxied request
+const proxiedRequestTimeout = 10 * time.Second
+
+type clusterMessage struct {
+	Kind     string
+	ConnID   string          `json:",omitempty"` // Identifies the proxied connection
+	ClientID common.ClientID `json:",omitempty"`
+	Spectate bool            `json:",omitempty"`
+	Protocol string          `json:",omitempty"` // Websocket subprotocol of the proxied connection
+	SSE      bool            `json:",omitempty"` // The proxied connection is an event stream, its events are posted
+	Data     []byte          `json:",omitempty"` // Event sent by the client, view sent to it or body of a request
+	// Proxied requests are answered on the topic of their ConnID
+	Method string      `json:",omitempty"`
+	URL    string      `json:",omitempty"`
+	Header http.Header `json:",omitempty"` // Of the request or of the response
+	Status int         `json:",omitempty"` // Of the response
+}
+
+func lobbyTopic(pin string) string {
+	return "lobbies/" + pin
+}
+
+func connTopic(connID string) string {
+	return "conns/" + connID
+}
+
+func newRandomID() string {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func publishMessage(ps PubSub, topic string, msg clusterMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return ps.Publish(topic, data)
+}
+
+// AddLobby adds the lobby to the repository and makes it reachable from the other instances
+// If the lobby doesn't have a pin, a pin no other instance uses is generated
+func (s Service) AddLobby(l *Lobby) error {
+	generatePin := l.Pin == ""
+	for attempt := 0; ; attempt++ {
+		if err := s.lRepo.AddLobby(l); err != nil {
+			return err
+		}
+
+		err := s.cluster.Directory.Register(l.Pin, s.cluster.InstanceID)
+		if err == nil {
+			break
+		}
+		if err := s.lRepo.DeleteLobby(l.Pin); err != nil {
+			return err
+		}
+		// Another instance runs a lobby with the same pin, try a new one
+		if _, taken := err.(errLobbyAlreadyExists); !taken || !generatePin || attempt >= 10 {
+			return err
+		}
+		l.Pin = ""
+	}
+
+	msgs, unsubscribe := s.cluster.PubSub.Subscribe(lobbyTopic(l.Pin))
+	l.mu.Lock()
+	l.unsubscribe = unsubscribe
+	s.notify(l, webhooks.EventLobbyCreated)
+	l.mu.Unlock()
+	go s.serveRemoteClients(l, msgs)
+	return nil
+}
+
+// removeLobby deletes the lobby from the repository and stops serving it to the other instances
+// It is executed with the lobby's mutex locked
+func (s Service) removeLobby(l *Lobby) error {
+	// Lobbies added straight to the repository were never registered
+	if l.unsubscribe != nil {
+		l.unsubscribe()
+		l.unsubscribe = nil
+		if err := s.cluster.Directory.Unregister(l.Pin); err != nil {
+			slog.Error("Error unregistering lobby", "Lobby-Pin", l.Pin, "err", err)
+		}
+	}
+	return s.lRepo.DeleteLobby(l.Pin)
+}
+
+// isRemoteLobby reports whether the lobby is run by another instance of the cluster
+func (s Service) isRemoteLobby(pin string) bool {
+	owner, err := s.cluster.Directory.Owner(pin)
+	return err == nil && owner != s.cluster.InstanceID
+}
+
+// remoteClient is a client connected to the lobby through another instance
+type remoteClient struct {
+	user *User
+	conn *remoteConn
+}
+
+// serveRemoteClients handles the messages of clients proxied from other instances until the lobby is removed
+func (s Service) serveRemoteClients(l *Lobby, msgs <-chan []byte) {
+	for data := range msgs {
+		var msg clusterMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			slog.Error("Error parsing cluster message", "Lobby-Pin", l.Pin, "err", err)
+			continue
+		}
+
+		// Requests are handled like the requests to this instance, the handlers lock the lobby themselves
+		if msg.Kind == msgRequest {
+			go s.serveProxiedRequest(msg)
+			continue
+		}
+
+		l.mu.Lock()
+		// Messages still buffered when the lobby was removed are dropped
+		if l.unsubscribe != nil {
+			s.handleRemoteMessage(l, msg)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// handleRemoteMessage is executed with the lobby's mutex locked
+func (s Service) handleRemoteMessage(l *Lobby, msg clusterMessage) {
+	switch msg.Kind {
+	case msgConnect:
+		conn := &remoteConn{ps: s.cluster.PubSub, topic: connTopic(msg.ConnID), protocol: msg.Protocol}
+		user, err := s.connectClient(l, conn, msg.ClientID, msg.Spectate)
+		if err != nil {
+			return
+		}
+		l.remoteClients[msg.ConnID] = remoteClient{user: user, conn: conn}
+		if msg.SSE {
+			l.sseClients[msg.ClientID] = user
+		}
+
+	case msgEvent:
+		client, ok := l.remoteClients[msg.ConnID]
+		if !ok {
+			slog.Warn("Event from unknown remote connection, skipping", "Lobby-Pin", l.Pin, "connID", msg.ConnID)
+			return
+		}
+		s.handleClientMessage(l, client.user, msg.Data)
+
+	case msgDisconnect:
+		client, ok := l.remoteClients[msg.ConnID]
+		if !ok {
+			return
+		}
+		delete(l.remoteClients, msg.ConnID)
+		if l.sseClients[client.user.ClientID] == client.user {
+			delete(l.sseClients, client.user.ClientID)
+		}
+		s.disconnectClient(l, client.user, client.conn)
+
+	default:
+		slog.Warn("Unknown cluster message, skipping", "Lobby-Pin", l.Pin, "kind", msg.Kind)
+	}
+}
+
+// remoteConn is the connection to a client connected through another instance
+// Every written view is published to the connection's topic
+type remoteConn struct {
+	ps       PubSub
+	topic    string
+	protocol string
+}
+
+func (c *remoteConn) NextWriter(_ int) (io.WriteCloser, error) {
+	return &remoteWriter{conn: c}, nil
+}
+
+func (c *remoteConn) Subprotocol() string {
+	return c.protocol
+}
+
+func (c *remoteConn) Close() error {
+	return publishMessage(c.ps, c.topic, clusterMessage{Kind: msgClose})
+}
+
+// remoteWriter publishes the written view once it is closed
+type remoteWriter struct {
+	bytes.Buffer
+	conn *remoteConn
+}
+
+func (w *remoteWriter) Close() error {
+	return publishMessage(w.conn.ps, w.conn.topic, clusterMessage{Kind: msgView, Data: w.Bytes()})
+}
+
+// proxyWebsocket forwards the client's websocket connection to the instance running the lobby
+func (s Service) proxyWebsocket(ws *websocket.Conn, pin string, clientID common.ClientID, spectate bool) {
+	connID := newRandomID()
+	msgs, unsubscribe := s.cluster.PubSub.Subscribe(connTopic(connID))
+	defer unsubscribe()
+
+	// Views from the lobby, the connection is only written to here
+	go func() {
+		for data := range msgs {
+			var msg clusterMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				slog.Error("Error parsing cluster message", "Lobby-Pin", pin, "err", err)
+				continue
+			}
+			switch msg.Kind {
+			case msgView:
+				if err := ws.WriteMessage(websocket.TextMessage, msg.Data); err != nil {
+					slog.Error("Error writing proxied view", "Lobby-Pin", pin, "err", err)
+				}
+			case msgClose:
+				ws.Close()
+				return
+			}
+		}
+	}()
+
+	topic := lobbyTopic(pin)
+	connect := clusterMessage{Kind: msgConnect, ConnID: connID, ClientID: clientID, Spectate: spectate, Protocol: ws.Subprotocol()}
+	if err := publishMessage(s.cluster.PubSub, topic, connect); err != nil {
+		slog.Error("Error proxying connection", "Lobby-Pin", pin, "err", err)
+		ws.Close()
+		return
+	}
+
+	for {
+		messageType, message, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) &&
+				!strings.Contains(err.Error(), "use of closed network connection") {
+				slog.Error("Unexpected error while reading proxied ws message, disconnecting", "err", err)
+			}
+			disconnect := clusterMessage{Kind: msgDisconnect, ConnID: connID}
+			if err := publishMessage(s.cluster.PubSub, topic, disconnect); err != nil {
+				slog.Error("Error proxying disconnection", "Lobby-Pin", pin, "err", err)
+			}
+			return
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		event := clusterMessage{Kind: msgEvent, ConnID: connID, Data: message}
+		if err := publishMessage(s.cluster.PubSub, topic, event); err != nil {
+			slog.Error("Error proxying event", "Lobby-Pin", pin, "err", err)
+		}
+	}
+}
+
+// proxySSE forwards the client's event stream to the instance running the lobby
+// The views are queued on conn like the views of a local lobby, the returned function disconnects the stream
+func (s Service) proxySSE(conn *sseConn, pin string, clientID common.ClientID, spectate bool) func() {
+	connID := newRandomID()
+	msgs, unsubscribe := s.cluster.PubSub.Subscribe(connTopic(connID))
+
+	go func() {
+		for data := range msgs {
+			var msg clusterMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				slog.Error("Error parsing cluster message", "Lobby-Pin", pin, "err", err)
+				continue
+			}
+			switch msg.Kind {
+			case msgView:
+				select {
+				case conn.views <- msg.Data:
+				case <-conn.closed:
+				}
+			case msgClose:
+				conn.Close()
+			}
+		}
+	}()
+
+	topic := lobbyTopic(pin)
+	connect := clusterMessage{Kind: msgConnect, ConnID: connID, ClientID: clientID, Spectate: spectate, SSE: true}
+	if err := publishMessage(s.cluster.PubSub, topic, connect); err != nil {
+		slog.Error("Error proxying event stream", "Lobby-Pin", pin, "err", err)
+		conn.Close()
+	}
+
+	return func() {
+		disconnect := clusterMessage{Kind: msgDisconnect, ConnID: connID}
+		if err := publishMessage(s.cluster.PubSub, topic, disconnect); err != nil {
+			slog.Error("Error proxying disconnection", "Lobby-Pin", pin, "err", err)
+		}
+		unsubscribe()
+	}
+}
+
+// proxiedRequestKey marks the requests received from other instances, they are never proxied again
+type proxiedRequestKey struct{}
+
+// proxyRequest forwards the request to the instance running the lobby and writes its response
+// It returns false without writing anything if the request can't be proxied,
+// e.g. it came from another instance or no instance runs the lobby
+func (s Service) proxyRequest(w http.ResponseWriter, r *http.Request, pin string) bool {
+	if r.Context().Value(proxiedRequestKey{}) != nil || !s.isRemoteLobby(pin) {
+		return false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProxiedBody))
+	if err != nil {
+		slog.Warn("Error reading proxied request", "err", err)
+		common.ErrorHandler(w, r, http.StatusBadRequest)
+		return true
+	}
+
+	connID := newRandomID()
+	msgs, unsubscribe := s.cluster.PubSub.Subscribe(connTopic(connID))
+	defer unsubscribe()
+
+	request := clusterMessage{
+		Kind:   msgRequest,
+		ConnID: connID,
+		Method: r.Method,
+		URL:    r.URL.RequestURI(),
+		Header: r.Header.Clone(),
+		Data:   body,
+	}
+	if err := publishMessage(s.cluster.PubSub, lobbyTopic(pin), request); err != nil {
+		slog.Error("Error proxying request", "Lobby-Pin", pin, "err", err)
+		common.ErrorHandler(w, r, http.StatusBadGateway)
+		return true
+	}
+
+	timeout := time.NewTimer(proxiedRequestTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case data := <-msgs:
+			var msg clusterMessage
+			if err := json.Unmarshal(data, &msg); err != nil || msg.Kind != msgResponse {
+				slog.Error("Unexpected reply to proxied request", "Lobby-Pin", pin, "err", err)
+				continue
+			}
+			for key, values := range msg.Header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(msg.Status)
+			_, _ = w.Write(msg.Data)
+			return true
+		case <-timeout.C:
+			slog.Error("Proxied request timed out", "Lobby-Pin", pin, "path", r.URL.Path)
+			common.ErrorHandler(w, r, http.StatusGatewayTimeout)
+			return true
+		case <-r.Context().Done():
+			return true
+		}
+	}
+}
+
+// serveProxiedRequest handles the request proxied from another instance and publishes the response
+func (s Service) serveProxiedRequest(msg clusterMessage) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), proxiedRequestKey{}, true), proxiedRequestTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, msg.Method, msg.URL, bytes.NewReader(msg.Data))
+	if err != nil {
+		slog.Error("Error parsing proxied request", "err", err)
+		return
+	}
+	r.Header = msg.Header
+
+	w := &bufferedResponse{header: make(http.Header)}
+	s.proxiedRouter().ServeHTTP(w, r)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	response := clusterMessage{Kind: msgResponse, Status: w.status, Header: w.header, Data: w.body.Bytes()}
+	if err := publishMessage(s.cluster.PubSub, connTopic(msg.ConnID), response); err != nil {
+		slog.Error("Error answering proxied request", "err", err)
+	}
+}
+
+// proxiedRouter routes the requests other instances proxy to the instance running the lobby
+func (s Service) proxiedRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lobbies/{pin}/events", s.postLobbyEventHandler)
+	mux.HandleFunc("/lobbies/{pin}/settings", s.lobbySettingsHandler)
+	return mux
+}
+
+// bufferedResponse keeps the response of a proxied request, so it can be published as a whole
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -0,0 +1,273 @@
+package lobbies
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestCluster returns two services sharing their lobbies, as if they ran on different instances
+func newTestCluster() (Service, Service) {
+	directory := NewDirectoryInMemory()
+	pubSub := NewLocalPubSub()
+	a := NewClusteredService(NewRepositoryInMemory(), nil, nil, Cluster{InstanceID: "a", Directory: directory, PubSub: pubSub})
+	b := NewClusteredService(NewRepositoryInMemory(), nil, nil, Cluster{InstanceID: "b", Directory: directory, PubSub: pubSub})
+	return a, b
+}
+
+// dialLobby connects a new client to the lobby's websocket on the server
+func dialLobby(t *testing.T, server *httptest.Server, pin string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/lobbies/" + pin + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readView returns the next view sent to the client
+func readView(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return string(msg)
+}
+
+func TestProxiedLobby(t *testing.T) {
+	a, b := newTestCluster()
+	lobby := Example1234Lobby()
+	if err := a.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(b.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/lobbies/" + lobby.Pin)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the lobby page of a remote lobby, got status %d", resp.StatusCode)
+	}
+
+	host := dialLobby(t, server, lobby.Pin)
+	if view := readView(t, host); !strings.Contains(view, "view") {
+		t.Errorf("Expected the host to get a view, got %q", view)
+	}
+
+	player := dialLobby(t, server, lobby.Pin)
+	if view := readView(t, player); !strings.Contains(view, "username") {
+		t.Errorf("Expected the player to choose a username, got %q", view)
+	}
+	event := `{"Username": "remote", "HEADERS": {"HX-Trigger-Name": "new-username-form"}}`
+	if err := player.WriteMessage(websocket.TextMessage, []byte(event)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_ = readView(t, player)
+
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	if lobby.Host == nil || !lobby.Host.IsConnected() {
+		t.Error("Expected the host connected through the other instance")
+	}
+	if !lobby.HasUser("remote") {
+		t.Error("Expected the player to join the lobby through the other instance")
+	}
+}
+
+func TestAddLobbyPinTaken(t *testing.T) {
+	a, b := newTestCluster()
+	if err := a.AddLobby(Example1234Lobby()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := b.AddLobby(Example1234Lobby()); err == nil {
+		t.Error("Expected error when another instance runs a lobby with the pin")
+	}
+	if _, err := b.lRepo.GetLobby("1234"); err == nil {
+		t.Error("Expected the refused lobby not to be kept")
+	}
+
+	// A new lobby gets a pin no instance uses
+	generated := createLobby(NewLobbyOptions())
+	if err := b.AddLobby(generated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if owner, err := b.cluster.Directory.Owner(generated.Pin); err != nil || owner != "b" {
+		t.Errorf("Expected the lobby to be run by b, got %q, %v", owner, err)
+	}
+}
+
+func TestRemoveLobbyUnregisters(t *testing.T) {
+	a, b := newTestCluster()
+	lobby := Example1234Lobby()
+	if err := a.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lobby.mu.Lock()
+	err := a.removeLobby(lobby)
+	lobby.mu.Unlock()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.isRemoteLobby(lobby.Pin) {
+		t.Error("Expected the removed lobby to be unregistered")
+	}
+	if err := b.AddLobby(Example1234Lobby()); err != nil {
+		t.Errorf("Expected the pin to be free again, got %v", err)
+	}
+}
+
+func TestProxiedSSELobby(t *testing.T) {
+	a, b := newTestCluster()
+	lobby := Example1234Lobby()
+	if err := a.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(b.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	host := connectSSE(t, server, lobby.Pin)
+	if _, view := host.next(t); !strings.Contains(view, `id="view"`) {
+		t.Errorf("Expected the host to get a view, got %q", view)
+	}
+	player := connectSSE(t, server, lobby.Pin)
+	if _, view := player.next(t); !strings.Contains(view, "new-username-form") {
+		t.Errorf("Expected the player to choose a username, got %q", view)
+	}
+
+	event := `{"Username": "remote-sse", "HEADERS": {"HX-Trigger-Name": "new-username-form"}}`
+	if status := player.post(t, server, lobby.Pin, event); status != http.StatusNoContent {
+		t.Fatalf("Expected the event to be accepted, got status %d", status)
+	}
+	if _, view := player.next(t); !strings.Contains(view, "remote-sse") {
+		t.Errorf("Expected the waiting room of the player, got %q", view)
+	}
+
+	lobby.mu.Lock()
+	joined := lobby.HasUser("remote-sse")
+	streams := len(lobby.sseClients)
+	lobby.mu.Unlock()
+	if !joined {
+		t.Error("Expected the player to join through the other instance")
+	}
+	if streams != 2 {
+		t.Errorf("Expected 2 proxied streams, got %d", streams)
+	}
+
+	// The instance running the lobby decides, also when the event is refused
+	resp, err := http.Post(server.URL+"/lobbies/"+lobby.Pin+"/events", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status %d for a client without a stream, got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestProxiedSettings(t *testing.T) {
+	withQuizzes, _ := newTestService(t)
+	directory := NewDirectoryInMemory()
+	pubSub := NewLocalPubSub()
+	a := NewClusteredService(NewRepositoryInMemory(), nil, withQuizzes.qRepo, Cluster{InstanceID: "a", Directory: directory, PubSub: pubSub})
+	b := NewClusteredService(NewRepositoryInMemory(), nil, nil, Cluster{InstanceID: "b", Directory: directory, PubSub: pubSub})
+
+	lobby := Example1234Lobby()
+	if err := a.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lobby.mu.Lock()
+	lobby.Host = &User{ClientID: "host"}
+	lobby.mu.Unlock()
+
+	server := httptest.NewServer(b.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	request := func(method, clientID, pin, form string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+"/lobbies/"+pin+"/settings", strings.NewReader(form))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "client-id", Value: clientID})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := request(http.MethodPut, "host", lobby.Pin, "time-per-question=12")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the settings of the host to be updated, got status %d", resp.StatusCode)
+	}
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "time-per-question") {
+		t.Errorf("Expected the settings form, got %q", body)
+	}
+	if answerTime := lobby.Settings().AnswerTime; answerTime != 12*time.Second {
+		t.Errorf("Expected the answer time to be 12s, got %v", answerTime)
+	}
+
+	if resp := request(http.MethodGet, "player", lobby.Pin, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a player, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp := request(http.MethodGet, "host", "0000", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown lobby, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestLocalPubSubFullSubscriber(t *testing.T) {
+	ps := NewLocalPubSub()
+	_, unsubscribeSlow := ps.Subscribe("slow")
+	for i := 0; i < subscriptionBuffer; i++ {
+		if err := ps.Publish("slow", []byte("msg")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		_ = ps.Publish("slow", []byte("one too many"))
+	}()
+	// Gives the publisher the time to block
+	time.Sleep(50 * time.Millisecond)
+
+	// Other topics work while the publisher waits for the slow subscriber
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		msgs, unsubscribe := ps.Subscribe("other")
+		defer unsubscribe()
+		_ = ps.Publish("other", []byte("msg"))
+		<-msgs
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the other topic not to wait for the slow subscriber")
+	}
+
+	unsubscribeSlow()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the publisher to give up once the subscriber is gone")
+	}
+}
@@ -0,0 +1,306 @@
+package lobbies
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/pastgames"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+// LogEntry is an event handled in a lobby, the event log of a lobby is stored with its past game
+// Replaying the log against a fresh game reproduces the final scores, see Replay
+type LogEntry struct {
+	At        time.Time       `json:"at"`
+	Initiator game.Username   `json:"initiator"`       // Username of the initiator when the event was handled, SYSTEM for system events
+	Event     string          `json:"event"`           // Type of the event, like leAnswerSubmitted
+	Data      json.RawMessage `json:"data"`            // Fields of the event, or what the event logs of itself (see loggedEvent)
+	Error     string          `json:"error,omitempty"` // Set if handling the event failed
+}
+
+// loggedEvent is implemented by events that need more than their fields to be replayed
+type loggedEvent interface {
+	// logData returns the data logged for the event, it is called once the event was handled successfully
+	logData(l *Lobby, initiator *User) any
+}
+
+// eventClock is the clock of the lobby's game, it stands still while an event is handled
+// Everything the event changes in the game happens at the logged time of the event, which makes replays exact
+type eventClock struct {
+	mu     sync.Mutex
+	frozen time.Time
+}
+
+func (c *eventClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.frozen.IsZero() {
+		return c.frozen
+	}
+	return time.Now()
+}
+
+func (c *eventClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// freeze stops the clock at the current time and returns it
+func (c *eventClock) freeze() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Logged times have no monotonic reading, so durations between them are the same once they are stored
+	c.frozen = time.Now().Round(0)
+	return c.frozen
+}
+
+func (c *eventClock) unfreeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = time.Time{}
+}
+
+// eventName returns the type of the event as it is logged
+func eventName(event lobbyEvent) string {
+	return reflect.TypeOf(event).Name()
+}
+
+// handleEvent handles the event and appends it to the lobby's event log
+// All events, from clients and the system, are handled through it. It is executed with the lobby's mutex locked
+func (s Service) handleEvent(l *Lobby, event lobbyEvent, initiator *User) error {
+	entry := LogEntry{
+		At:        l.clock.freeze(),
+		Initiator: initiator.Username,
+		Event:     eventName(event),
+	}
+	defer l.clock.unfreeze()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	entry.Data = data
+
+	// The entry is appended first, so the game saved by the event ending it has the entry in its log
+	i := len(l.log)
+	l.log = append(l.log, entry)
+
+	err = event.Handle(s, l, initiator)
+	if err != nil {
+		l.log[i].Error = err.Error()
+		return err
+	}
+	// System events count too, e.g. a lobby on auto-advance is active without any clicks
+	l.touch()
+
+	if logged, ok := event.(loggedEvent); ok {
+		data, err := json.Marshal(logged.logData(l, initiator))
+		if err != nil {
+			slog.Error("Error logging event data", "event", entry.Event, "err", err)
+			return nil
+		}
+		l.log[i].Data = data
+	}
+	return nil
+}
+
+// gameStartLog is logged for leGameStartRequested, it is what's needed to set up the same game again
+type gameStartLog struct {
+	QuizID        int64
+	QuizRevision  int64
+	QuestionOrder []int // Quiz question indices in the order they are asked
+	ReadingTime   time.Duration
+	AnswerTime    time.Duration
+}
+
+func (e leGameStartRequested) logData(l *Lobby, _ *User) any {
+	settings := l.Settings()
+	data := gameStartLog{
+		QuestionOrder: make([]int, l.QuestionsCount()),
+		ReadingTime:   settings.ReadingTime,
+		AnswerTime:    settings.AnswerTime,
+	}
+	if q, ok := l.Quiz().(quiz.Quiz); ok {
+		data.QuizID = q.ID
+		data.QuizRevision = q.Revision
+	}
+	for round := range data.QuestionOrder {
+		data.QuestionOrder[round] = l.QuizQuestionIdx(round)
+	}
+	return data
+}
+
+// answerLog is logged for leAnswerSubmitted
+// Shuffled answers are shown in a random order, so the answer is logged as its index in the question
+type answerLog struct {
+	Round  int
+	Answer int
+}
+
+func (e leAnswerSubmitted) logData(l *Lobby, initiator *User) any {
+	return answerLog{
+		Round:  e.QuestionIdx,
+		Answer: l.Round.Answers()[initiator.Username].Index,
+	}
+}
+
+// Replay re-executes the event log of a game against a fresh game of the quiz and returns the final leaderboard
+// Events that failed are skipped, the game is timed by a fake clock moved to the time of every event.
+// Only what affects the scores is replayed, things like connections and co-hosts are left out
+func Replay(log []LogEntry, q game.Quiz) ([]game.Score, error) {
+	var start *gameStartLog
+	for _, entry := range log {
+		if entry.Event == eventName(leGameStartRequested{}) && entry.Error == "" {
+			start = &gameStartLog{}
+			if err := json.Unmarshal(entry.Data, start); err != nil {
+				return nil, fmt.Errorf("parsing game start: %w", err)
+			}
+			break
+		}
+	}
+	if start == nil {
+		return nil, errors.New("the game was never started")
+	}
+
+	clock := game.NewFakeClock(log[0].At)
+	g := game.CreateGame(game.GameSettings{
+		Quiz:          q,
+		QuestionOrder: start.QuestionOrder,
+		// Only the players who managed to join are in the log
+		AllowLateJoin: true,
+		RoundSettings: game.RoundSettings{
+			ReadingTime:   start.ReadingTime,
+			AnswerTime:    start.AnswerTime,
+			AnswerShuffle: game.AnswerShuffleOff,
+		},
+	}, clock)
+
+	for _, entry := range log {
+		if entry.Error != "" {
+			continue
+		}
+		if d := entry.At.Sub(clock.Now()); d > 0 {
+			clock.Advance(d)
+		}
+		if err := replayEntry(g, entry); err != nil {
+			return nil, fmt.Errorf("replaying %s at %s: %w", entry.Event, entry.At.Format(time.RFC3339Nano), err)
+		}
+	}
+	return g.Leaderboard(), nil
+}
+
+// replayEntry applies what the logged event changed in the game
+func replayEntry(g game.Game, entry LogEntry) error {
+	// Players leaving the lobby keep their points only once the game has started
+	leave := func(username game.Username) error {
+		if g.HasStarted() {
+			return g.RetirePlayer(username)
+		}
+		return g.RemovePlayer(username)
+	}
+	finishRound := func() error {
+		err := g.FinishRoundEarly()
+		if errors.Is(err, game.ErrRoundAlreadyEnded) {
+			return nil
+		}
+		return err
+	}
+
+	switch entry.Event {
+	case eventName(leNewUsernameSubmitted{}):
+		var e leNewUsernameSubmitted
+		if err := json.Unmarshal(entry.Data, &e); err != nil {
+			return err
+		}
+		if entry.Initiator == "" {
+			return g.AddPlayer(e.Username)
+		}
+		return g.ChangeUsername(entry.Initiator, e.Username)
+	case eventName(leGameStartRequested{}):
+		return g.Start()
+	case eventName(leAnswerSubmitted{}):
+		var e answerLog
+		if err := json.Unmarshal(entry.Data, &e); err != nil {
+			return err
+		}
+		if e.Round != g.RoundNum() {
+			return fmt.Errorf("answer for round %d in round %d", e.Round, g.RoundNum())
+		}
+		return g.SubmitAnswer(entry.Initiator, e.Answer)
+	case eventName(leSkipToAnswerRequested{}), eventName(leShowAnswerRequested{}):
+		return finishRound()
+	case eventName(leRoundPauseRequested{}):
+		return g.PauseRound()
+	case eventName(leRoundResumeRequested{}):
+		return g.ResumeRound()
+	case eventName(leRoundExtendRequested{}):
+		var e leRoundExtendRequested
+		if err := json.Unmarshal(entry.Data, &e); err != nil {
+			return err
+		}
+		return g.ExtendRound(e.By)
+	case eventName(leNextQuestionRequested{}):
+		return g.StartNextRound()
+	case eventName(leKickPlayerRequested{}):
+		var e leKickPlayerRequested
+		if err := json.Unmarshal(entry.Data, &e); err != nil {
+			return err
+		}
+		if g.HasStarted() && e.KeepPoints != "" {
+			return g.RetirePlayer(e.Username)
+		}
+		return g.RemovePlayer(e.Username)
+	case eventName(leHostTransferRequested{}), eventName(leHostHandoverRequested{}), eventName(leAwayPlayerRemoved{}):
+		// All of them only carry the username of the player
+		var e struct{ Username game.Username }
+		if err := json.Unmarshal(entry.Data, &e); err != nil {
+			return err
+		}
+		return leave(e.Username)
+	case eventName(leLobbyCloseRequested{}):
+		if g.InRound() {
+			if err := finishRound(); err != nil {
+				return err
+			}
+		}
+		return g.Finish()
+	case eventName(leEndGameRequested{}):
+		return g.Finish()
+	default:
+		// The event doesn't change the game
+		return nil
+	}
+}
+
+// ReplayPastGame replays the event log stored with the past game against the quiz revision it was played with
+// It returns the past game with the scores that were stored and the replayed leaderboard
+func ReplayPastGame(pgRepo pastgames.Repository, qRepo quiz.Repository, id int64) (*pastgames.PastGame, []game.Score, error) {
+	pastGame, err := pgRepo.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pastGame.EventLog) == 0 {
+		return nil, nil, errors.New("the past game has no event log")
+	}
+	if pastGame.QuizID == 0 {
+		return nil, nil, errors.New("the quiz of the past game isn't stored")
+	}
+
+	var log []LogEntry
+	if err := json.Unmarshal(pastGame.EventLog, &log); err != nil {
+		return nil, nil, fmt.Errorf("parsing event log: %w", err)
+	}
+
+	q, err := qRepo.GetRevision(pastGame.QuizID, pastGame.QuizRevision)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting quiz revision: %w", err)
+	}
+
+	scores, err := Replay(log, *q)
+	return pastGame, scores, err
+}
@@ -0,0 +1,126 @@
+package lobbies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+)
+
+// handleTestEvent handles the event like a client sending it would
+func handleTestEvent(s Service, l *Lobby, event lobbyEvent, initiator *User) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return s.handleEvent(l, event, initiator)
+}
+
+// waitForEvent waits until the event was logged count times, system events are handled in the background
+func waitForEvent(t *testing.T, l *Lobby, event lobbyEvent, count int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		l.mu.Lock()
+		logged := 0
+		for _, entry := range l.log {
+			if entry.Event == eventName(event) {
+				logged++
+			}
+		}
+		l.mu.Unlock()
+		if logged >= count {
+			return
+		}
+	}
+	t.Fatalf("Expected %s to be logged %d times", eventName(event), count)
+}
+
+func TestReplayPastGame(t *testing.T) {
+	s, pgRepo := newTestService(t)
+	if _, err := s.qRepo.Upsert(&quiz.ExampleQuizGeography); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	options := NewLobbyOptions()
+	options.Quiz = quiz.ExampleQuizGeography
+	options.ShuffleQuestions = true
+	options.AnswerShuffle = game.AnswerShufflePerPlayer
+	options.ReadingTime = 0
+	lobby := createLobby(options)
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	host := &User{ClientID: "host", Username: "HOST"}
+	lobby.Host = host
+	alice := &User{ClientID: "alice"}
+	bob := &User{ClientID: "bob"}
+
+	type step struct {
+		event     lobbyEvent
+		initiator *User
+		fails     bool
+	}
+	play := func(steps []step) {
+		t.Helper()
+		for _, step := range steps {
+			time.Sleep(20 * time.Millisecond)
+			err := handleTestEvent(s, lobby, step.event, step.initiator)
+			if (err != nil) != step.fails {
+				t.Fatalf("Expected %s to fail: %v, got %v", step.event, step.fails, err)
+			}
+		}
+	}
+
+	play([]step{
+		{leNewUsernameSubmitted{Username: "alice"}, alice, false},
+		{leNewUsernameSubmitted{Username: "bob"}, bob, false},
+		{leGameStartRequested{}, bob, true},
+		{leGameStartRequested{}, host, false},
+		{leAnswerSubmitted{QuestionIdx: 0, AnswerIdx: 0}, alice, false},
+		{leRoundPauseRequested{}, host, false},
+		{leAnswerSubmitted{QuestionIdx: 0, AnswerIdx: 1}, bob, true},
+		{leRoundResumeRequested{}, host, false},
+		{leAnswerSubmitted{QuestionIdx: 0, AnswerIdx: 1}, bob, false},
+	})
+	// Everyone answered, so the round finishes on its own
+	waitForEvent(t, lobby, leShowAnswerRequested{}, 1)
+
+	play([]step{
+		{leNextQuestionRequested{}, host, false},
+		{leAnswerSubmitted{QuestionIdx: 1, AnswerIdx: 2}, bob, false},
+		{leKickPlayerRequested{Username: "alice", KeepPoints: "on"}, host, false},
+	})
+	// The kicked player was the last one left to answer
+	waitForEvent(t, lobby, leShowAnswerRequested{}, 2)
+	play([]step{
+		{leEndGameRequested{}, host, false},
+	})
+
+	pastGames, err := pgRepo.GetAll()
+	if err != nil || len(pastGames) != 1 {
+		t.Fatalf("Expected the game to be stored, got %d games and error %v", len(pastGames), err)
+	}
+
+	pastGame, replayed, err := ReplayPastGame(pgRepo, s.qRepo, pastGames[0].ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(replayed) != len(pastGame.Scores) {
+		t.Fatalf("Expected %d replayed scores, got %d", len(pastGame.Scores), len(replayed))
+	}
+	stored := make(map[game.Username]int)
+	for _, score := range pastGame.Scores {
+		stored[game.Username(score.Username)] = score.Score
+	}
+	for _, score := range replayed {
+		if points, ok := stored[score.Username]; !ok || points != score.Points {
+			t.Errorf("Expected %s to have %d points, replayed %d", score.Username, points, score.Points)
+		}
+	}
+}
+
+func TestReplayWithoutStart(t *testing.T) {
+	log := []LogEntry{{At: time.Now(), Initiator: "HOST", Event: eventName(leGameStartRequested{}), Error: "Non-host tried to start the game"}}
+	if _, err := Replay(log, quiz.ExampleQuizGeography); err == nil {
+		t.Error("Expected error for a game that was never started")
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/erykksc/kwikquiz/internal/common"
 	"github.com/erykksc/kwikquiz/internal/game"
 	"github.com/erykksc/kwikquiz/internal/pastgames"
 	"github.com/erykksc/kwikquiz/internal/quiz"
-	"github.com/gorilla/websocket"
+	"github.com/erykksc/kwikquiz/internal/webhooks"
 )
 
 // Events are either user generated or system generated (for example when question timer expires)
@@ -47,6 +48,22 @@ func parseLobbyEvent(jsonData []byte) (lobbyEvent, error) {
 	case "skip-to-answer-btn":
 		var event leSkipToAnswerRequested
 		return event, nil
+	case "pause-round-btn":
+		var event leRoundPauseRequested
+		return event, nil
+	case "resume-round-btn":
+		var event leRoundResumeRequested
+		return event, nil
+	case "extend-round-btn":
+		var event leRoundExtendRequested
+		// Parse the extension from "HxTrigger" in format "extend-round-<seconds>s"
+		var seconds int
+		_, err := fmt.Sscanf(wsRequest.HEADERS.HxTrigger, "extend-round-%ds", &seconds)
+		if err != nil {
+			return nil, err
+		}
+		event.By = time.Duration(seconds) * time.Second
+		return event, nil
 	case "next-question-btn":
 		var event leNextQuestionRequested
 		return event, nil
@@ -65,6 +82,24 @@ func parseLobbyEvent(jsonData []byte) (lobbyEvent, error) {
 			return nil, err
 		}
 		return event, nil
+	case "co-host-form":
+		var event leCoHostRequested
+		if err := json.Unmarshal(jsonData, &event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	case "transfer-host-form":
+		var event leHostTransferRequested
+		if err := json.Unmarshal(jsonData, &event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	case "kick-player-form":
+		var event leKickPlayerRequested
+		if err := json.Unmarshal(jsonData, &event); err != nil {
+			return nil, err
+		}
+		return event, nil
 	default:
 		return nil, errors.New("unrecognized trigger name, cannot parse event: " + wsRequest.HEADERS.HxTriggerName)
 	}
@@ -72,12 +107,18 @@ func parseLobbyEvent(jsonData []byte) (lobbyEvent, error) {
 
 // handleNewWebsocketConn handles a new websocket connection to the lobby
 // This function bridges routes and events
-func handleNewWebsocketConn(l *Lobby, conn *websocket.Conn, clientID common.ClientID) (*User, error) {
+func handleNewWebsocketConn(l *Lobby, conn Conn, clientID common.ClientID) (*User, error) {
 	connectedUser := &User{
 		Conn:     conn,
 		ClientID: clientID,
 	}
 
+	if l.IsBanned(clientID) {
+		slog.Info("Banned client tried to join", "Lobby-Pin", l.Pin, "Client-ID", clientID)
+		_ = connectedUser.writeTemplate(ConnectionClosedView, kickedMessage)
+		return nil, errPlayerBanned{}
+	}
+
 	// view := l.State.View()
 	view := l.View()
 
@@ -94,6 +135,7 @@ func handleNewWebsocketConn(l *Lobby, conn *websocket.Conn, clientID common.Clie
 		slog.Info("Host reconnecting", "Lobby-Pin", l.Pin, "Client-ID", connectedUser.ClientID)
 		// Update the connection
 		l.Host.Conn = conn
+		l.Host.DisconnectedAt = time.Time{}
 		connectedUser = l.Host
 
 	// Check if player is trying to reconnect
@@ -102,6 +144,7 @@ func handleNewWebsocketConn(l *Lobby, conn *websocket.Conn, clientID common.Clie
 		// Update the connection
 		player.Conn = conn
 		connectedUser = player
+		l.handleReconnect(player)
 
 	// New User connecting
 	default:
@@ -114,6 +157,72 @@ func handleNewWebsocketConn(l *Lobby, conn *websocket.Conn, clientID common.Clie
 	return connectedUser, err
 }
 
+// connectClient adds the client with a new connection to the lobby, as a spectator if requested
+// Players and the host opening the spectator link still join as themselves.
+// A refused client's connection is closed. It is executed with the lobby's mutex locked
+func (s Service) connectClient(l *Lobby, conn Conn, clientID common.ClientID, spectate bool) (*User, error) {
+	var user *User
+	var err error
+	if spectate && !l.IsMember(clientID) {
+		user, err = handleNewSpectatorConn(l, conn, clientID)
+	} else {
+		user, err = handleNewWebsocketConn(l, conn, clientID)
+	}
+	l.touch()
+
+	switch err.(type) {
+	case nil:
+		// A returning player can take over from a host who left while nobody else was connected
+		l.promoteOverdueHost(s)
+	case errPlayerBanned, errTooManySpectators:
+		slog.Info("Rejected client", "clientID", clientID, "Lobby-Pin", l.Pin, "reason", err)
+		conn.Close()
+	default:
+		slog.Error("Error handling user connection", "err", err)
+	}
+	return user, err
+}
+
+// handleClientMessage parses an event sent by the user and handles it
+// It is executed with the lobby's mutex locked
+func (s Service) handleClientMessage(l *Lobby, user *User, message []byte) {
+	if user.Spectator {
+		slog.Warn("Spectators can't send events, skipping", "clientID", user.ClientID)
+		return
+	}
+
+	parse := parseLobbyEvent
+	if user.usesJSON() {
+		parse = parseJSONEvent
+	}
+	event, err := parse(message)
+	if err != nil {
+		slog.Warn("Error parsing lobby event, skipping", "err", err, "message", message)
+		user.reportError(err)
+		return
+	}
+
+	slog.Info("Handling lobby event", "event", event.String(), "initiator", user)
+	l.touch()
+	if err := s.handleEvent(l, event, user); err != nil {
+		slog.Error("Error handling lobby event", "event", event.String(), "err", err)
+		user.reportError(err)
+		return
+	}
+	// New players only join once they pick a username
+	l.promoteOverdueHost(s)
+}
+
+// disconnectClient handles the user's connection being closed
+// It is executed with the lobby's mutex locked
+func (s Service) disconnectClient(l *Lobby, user *User, conn Conn) {
+	if user.Spectator {
+		l.handleSpectatorDisconnect(user, conn)
+	} else {
+		l.handleDisconnect(s, user, conn)
+	}
+}
+
 // leNewUsernameSubmitted is an event that is triggered when a user submits a new username
 type leNewUsernameSubmitted struct {
 	Username game.Username
@@ -127,11 +236,22 @@ func (event leNewUsernameSubmitted) Handle(_ Service, l *Lobby, initiator *User)
 	if initiator.Username == "" {
 		err := l.AddPlayer(event.Username)
 		if err != nil {
+			if _, ok := err.(game.ErrGameAlreadyStarted); ok {
+				_ = initiator.writeTemplate(LobbyErrorAlertTmpl, "Game already started, the host doesn't allow late joins")
+			}
 			return err
 		}
 		initiator.Username = event.Username
 		l.Users[initiator.ClientID] = initiator
 
+		if l.HasStarted() {
+			// The player is in the game already, failing to show it doesn't undo that
+			if err := l.handleLateJoin(initiator); err != nil {
+				slog.Error("Error sending view to late player", "username", initiator.Username, "err", err)
+			}
+			return nil
+		}
+
 	} else {
 		err := l.ChangeUsername(initiator.Username, event.Username)
 		if err != nil {
@@ -145,6 +265,20 @@ func (event leNewUsernameSubmitted) Handle(_ Service, l *Lobby, initiator *User)
 	return nil
 }
 
+// handleLateJoin brings a player who joined a running game to its current state
+func (l *Lobby) handleLateJoin(player *User) error {
+	slog.Info("Player joined late", "Lobby-Pin", l.Pin, "username", player.Username, "round", l.RoundNum())
+	if err := l.sendViewToUser(l.View(), player); err != nil {
+		return err
+	}
+
+	// The late player has to answer as well, so everyone gets the new count
+	if l.InRound() {
+		l.sendPlayerCountToAll()
+	}
+	return nil
+}
+
 // leUsernameChangeRequested is an event that is triggered when a user requests to change his username
 type leUsernameChangeRequested struct{}
 
@@ -177,7 +311,8 @@ func (event leGameStartRequested) Handle(s Service, l *Lobby, initiator *User) e
 	}
 
 	// Re-fetch the quiz from the database so that any edits made since the
-	// quiz was last selected are picked up.
+	// quiz was last selected are picked up. The fetched revision stays pinned
+	// to the game, later edits create new revisions and don't affect it.
 	if q, ok := l.Quiz().(quiz.Quiz); ok && q.ID > 0 {
 		fresh, err := s.qRepo.Get(q.ID)
 		if err == nil && fresh != nil {
@@ -206,13 +341,14 @@ func (event leGameStartRequested) Handle(s Service, l *Lobby, initiator *User) e
 		slog.Debug("Round finished, requesting to show answer")
 
 		l.mu.Lock()
-		err := leShowAnswerRequested{}.Handle(s, l, lobbySystemUser)
+		err := s.handleEvent(l, leShowAnswerRequested{}, lobbySystemUser)
 		if err != nil {
 			slog.Error("Error handling ShowAnswerRequested", "error", err)
 		}
 		l.mu.Unlock()
 	}()
 
+	s.notify(l, webhooks.EventGameStarted)
 	l.sendViewToAll(QuestionView)
 	return nil
 }
@@ -224,7 +360,11 @@ func (e leSkipToAnswerRequested) String() string {
 	return "LESkipToAnswerRequest"
 }
 
-func (event leSkipToAnswerRequested) Handle(_ Service, l *Lobby, _ *User) error {
+func (event leSkipToAnswerRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can skip to the answer")
+	}
+
 	err := l.FinishRoundEarly()
 	if err != nil {
 		return err
@@ -234,6 +374,68 @@ func (event leSkipToAnswerRequested) Handle(_ Service, l *Lobby, _ *User) error
 	return nil
 }
 
+// leRoundPauseRequested is an event that is triggered when the host or the co-host pauses the round timer
+type leRoundPauseRequested struct{}
+
+func (e leRoundPauseRequested) String() string {
+	return "LERoundPauseRequested"
+}
+
+func (e leRoundPauseRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can pause the round")
+	}
+
+	if err := l.PauseRound(); err != nil {
+		return err
+	}
+
+	l.sendViewToAll(QuestionView)
+	return nil
+}
+
+// leRoundResumeRequested is an event that is triggered when the host or the co-host resumes a paused round
+type leRoundResumeRequested struct{}
+
+func (e leRoundResumeRequested) String() string {
+	return "LERoundResumeRequested"
+}
+
+func (e leRoundResumeRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can resume the round")
+	}
+
+	if err := l.ResumeRound(); err != nil {
+		return err
+	}
+
+	l.sendViewToAll(QuestionView)
+	return nil
+}
+
+// leRoundExtendRequested is an event that is triggered when the host or the co-host gives the players extra time
+type leRoundExtendRequested struct {
+	By time.Duration
+}
+
+func (e leRoundExtendRequested) String() string {
+	return "LERoundExtendRequested: " + e.By.String()
+}
+
+func (e leRoundExtendRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can extend the round")
+	}
+
+	if err := l.ExtendRound(e.By); err != nil {
+		return err
+	}
+
+	l.sendViewToAll(QuestionView)
+	return nil
+}
+
 // leNextQuestionRequested is an event that is triggered when a user requests to go to the next question
 type leNextQuestionRequested struct{}
 
@@ -242,6 +444,10 @@ func (e leNextQuestionRequested) String() string {
 }
 
 func (event leNextQuestionRequested) Handle(s Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can go to the next question")
+	}
+
 	err := l.StartNextRound()
 	if err != nil {
 		return err
@@ -257,7 +463,7 @@ func (event leNextQuestionRequested) Handle(s Service, l *Lobby, initiator *User
 		slog.Debug("Round finished, requesting to show answer")
 
 		l.mu.Lock()
-		err := leShowAnswerRequested{}.Handle(s, l, lobbySystemUser)
+		err := s.handleEvent(l, leShowAnswerRequested{}, lobbySystemUser)
 		if err != nil {
 			slog.Error("Error handling ShowAnswerRequested", "error", err)
 		}
@@ -299,18 +505,13 @@ func (e leAnswerSubmitted) Handle(_ Service, l *Lobby, initiator *User) error {
 		Lobby: l,
 		User:  initiator,
 	}
+	// The answer counts even if the player doesn't get to see it
 	if err := initiator.writeNamedTemplate(QuestionView, "answer-options", vData); err != nil {
-		return err
+		slog.Error("Error sending answer options", "username", initiator.Username, "err", err)
 	}
 
 	// Send template for how many people are left to answer
-	for _, player := range l.Users {
-		vData.User = player
-		if err := player.writeNamedTemplate(QuestionView, "player-count", vData); err != nil {
-			return err
-		}
-	}
-
+	l.sendPlayerCountToAll()
 	return nil
 }
 
@@ -320,32 +521,52 @@ func (e leShowAnswerRequested) String() string {
 	return "LEShowAnswerRequested"
 }
 
-func (e leShowAnswerRequested) Handle(_ Service, l *Lobby, _ *User) error {
+func (e leShowAnswerRequested) Handle(s Service, l *Lobby, _ *User) error {
 	err := l.FinishRoundEarly()
 	if err != nil && !errors.Is(err, game.ErrRoundAlreadyEnded) {
 		return err
 	}
 
+	// A round finished by closing the lobby is part of the game.ended webhook
+	if !l.HasEnded() {
+		s.notifyRoundFinished(l)
+	}
 	l.sendViewToAll(AnswerView)
+	l.scheduleAutoAdvance(s)
 	return nil
 }
 
-type leEndGameRequested struct{}
+// scheduleAutoAdvance starts the next round (or ends the game after the last one)
+// once the auto-advance pause passes, does nothing if auto-advance is disabled
+// If the host advances manually in the meantime, the scheduled advance is dropped
+func (l *Lobby) scheduleAutoAdvance(s Service) {
+	pause := l.Settings().AutoAdvance
+	if pause <= 0 || l.HasEnded() {
+		return
+	}
 
-func (e leEndGameRequested) String() string {
-	return "LEEndGameRequested"
-}
+	roundNum := l.RoundNum()
+	time.AfterFunc(pause, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
 
-func (e leEndGameRequested) Handle(s Service, l *Lobby, _ *User) error {
-	err := l.Finish()
-	if err != nil {
-		return err
-	}
+		if l.HasEnded() || l.InRound() || l.RoundNum() != roundNum {
+			return
+		}
 
-	if err := s.lRepo.DeleteLobby(l.Pin); err != nil {
-		return err
-	}
+		var event lobbyEvent = leNextQuestionRequested{}
+		if roundNum+1 >= l.QuestionsCount() {
+			event = leEndGameRequested{}
+		}
+		slog.Debug("Auto-advancing lobby", "Lobby-Pin", l.Pin, "event", event.String())
+		if err := s.handleEvent(l, event, lobbySystemUser); err != nil {
+			slog.Error("Error auto-advancing lobby", "Lobby-Pin", l.Pin, "error", err)
+		}
+	})
+}
 
+// saveGame stores the finished game of the lobby in past games and returns its ID
+func (s Service) saveGame(l *Lobby) (int64, error) {
 	scores := make([]pastgames.PlayerScore, 0, len(l.Users))
 	for _, player := range l.Leaderboard() {
 		scores = append(scores, pastgames.PlayerScore{
@@ -358,12 +579,57 @@ func (e leEndGameRequested) Handle(s Service, l *Lobby, _ *User) error {
 		StartedAt: l.StartedAt(),
 		EndedAt:   l.EndedAt(),
 		QuizTitle: l.Quiz().Title(),
+		Questions: l.QuestionsCount(),
 		Scores:    scores,
 	}
+	if q, ok := l.Quiz().(quiz.Quiz); ok {
+		pastGame.QuizID = q.ID
+		pastGame.QuizRevision = q.Revision
+	}
+	eventLog, err := json.Marshal(l.log)
+	if err != nil {
+		return 0, err
+	}
+	pastGame.EventLog = eventLog
 	id, err := s.pgRepo.Insert(&pastGame)
+	if err != nil {
+		return 0, err
+	}
+
+	// Play counts are only used for sorting quizzes, the game is stored regardless
+	if pastGame.QuizID != 0 {
+		if err := s.qRepo.RecordPlay(pastGame.QuizID); err != nil {
+			slog.Error("Error recording quiz play", "quizID", pastGame.QuizID, "err", err)
+		}
+	}
+	return id, nil
+}
+
+type leEndGameRequested struct{}
+
+func (e leEndGameRequested) String() string {
+	return "LEEndGameRequested"
+}
+
+func (e leEndGameRequested) Handle(s Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can finish the game")
+	}
+
+	err := l.Finish()
+	if err != nil {
+		return err
+	}
+
+	if err := s.removeLobby(l); err != nil {
+		return err
+	}
+
+	id, err := s.saveGame(l)
 	if err != nil {
 		return err
 	}
+	s.notifyGameEnded(l, id)
 
 	data := OnFinishData{
 		PastGameID: id,
@@ -391,5 +657,15 @@ func (e leEndGameRequested) Handle(s Service, l *Lobby, _ *User) error {
 		}
 		player.Conn.Close()
 	}
+	for _, spectator := range l.Spectators {
+		if !spectator.IsConnected() {
+			continue
+		}
+		data.User = spectator
+		if err := spectator.writeTemplate(onFinishView, data); err != nil {
+			slog.Error("Error sending OnFinishView to spectator", "error", err)
+		}
+		spectator.Conn.Close()
+	}
 	return nil
 }
@@ -0,0 +1,217 @@
+package lobbies
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+// leCoHostRequested is an event that is triggered when the host picks a player as the co-host
+// The co-host controls the game together with the host, see canControl, and takes over when the host
+// is disconnected for longer than the HostGracePeriod setting. Picking the current co-host again removes the role
+type leCoHostRequested struct {
+	Username game.Username
+}
+
+func (e leCoHostRequested) String() string {
+	return "LECoHostRequested: " + string(e.Username)
+}
+
+func (e leCoHostRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if initiator.ClientID != l.Host.ClientID {
+		return errors.New("Non-host tried to pick a co-host")
+	}
+
+	player := l.userByUsername(e.Username)
+	if player == nil {
+		return errors.New("Co-host not found in the lobby: " + string(e.Username))
+	}
+
+	if l.CoHost == player {
+		l.CoHost = nil
+	} else {
+		l.CoHost = player
+	}
+	slog.Info("Co-host changed", "Lobby-Pin", l.Pin, "username", player.Username, "is-co-host", l.CoHost == player)
+
+	if err := l.sendViewToUser(l.View(), l.Host); err != nil {
+		return err
+	}
+	if player.IsConnected() && !l.HasStarted() {
+		return l.sendViewToUser(WaitingRoomView, player)
+	}
+	return nil
+}
+
+// canControl reports whether the user may run the game: skip, pause, resume and extend rounds,
+// go to the next question, finish the game and kick players
+// Only the host picks the co-host and hands the lobby over. The system runs the game on auto-advance
+func (l *Lobby) canControl(user *User) bool {
+	if user == lobbySystemUser {
+		return true
+	}
+	isHost := l.Host != nil && l.Host.ClientID == user.ClientID
+	isCoHost := l.CoHost != nil && l.CoHost.ClientID == user.ClientID
+	return isHost || isCoHost
+}
+
+// leHostTransferRequested is an event that is triggered when the host hands the lobby over to a player
+type leHostTransferRequested struct {
+	Username game.Username
+}
+
+func (e leHostTransferRequested) String() string {
+	return "LEHostTransferRequested: " + string(e.Username)
+}
+
+func (e leHostTransferRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if initiator.ClientID != l.Host.ClientID {
+		return errors.New("Non-host tried to transfer the host role")
+	}
+
+	player := l.userByUsername(e.Username)
+	if player == nil {
+		return errors.New("New host not found in the lobby: " + string(e.Username))
+	}
+
+	return l.promoteToHost(player)
+}
+
+// promoteToHost makes the player the host of the lobby
+// The player stops playing, their points stay on the leaderboard once the game has started.
+// The previous host loses the role and can join the lobby as a player
+func (l *Lobby) promoteToHost(player *User) error {
+	if l.HasEnded() {
+		return errors.New("Game already ended")
+	}
+
+	var err error
+	if l.HasStarted() {
+		err = l.RetirePlayer(player.Username)
+	} else {
+		err = l.RemovePlayer(player.Username)
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Host changed", "Lobby-Pin", l.Pin, "username", player.Username, "Client-ID", player.ClientID)
+	delete(l.Users, player.ClientID)
+	if l.CoHost == player {
+		l.CoHost = nil
+	}
+
+	oldHost := l.Host
+	player.Username = "HOST"
+	player.DisconnectedAt = time.Time{}
+	l.Host = player
+	// The previous host has to pick a username to join as a player
+	oldHost.Username = ""
+
+	if oldHost.IsConnected() {
+		if err := l.sendViewToUser(ChooseUsernameView, oldHost); err != nil {
+			slog.Error("Error sending view to the previous host", "err", err)
+		}
+	}
+	if l.Host.IsConnected() {
+		if err := l.sendViewToUser(l.View(), l.Host); err != nil {
+			slog.Error("Error sending view to the new host", "err", err)
+		}
+	}
+	if l.InRound() {
+		l.sendPlayerCountToAll()
+	}
+	return nil
+}
+
+// scheduleHostPromotion replaces the host if they stay disconnected for longer than the HostGracePeriod setting
+// If nobody can take over by then, promoteOverdueHost tries again once a player connects
+func (l *Lobby) scheduleHostPromotion(s Service) {
+	after := l.Settings().HostGracePeriod
+	if after <= 0 {
+		return
+	}
+
+	host := l.Host
+	disconnectedAt := host.DisconnectedAt
+	time.AfterFunc(after, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.Host != host || !host.DisconnectedAt.Equal(disconnectedAt) {
+			return
+		}
+		if !l.promoteOverdueHost(s) {
+			slog.Warn("Host disconnected but there is no connected player to take over", "Lobby-Pin", l.Pin)
+		}
+	})
+}
+
+// promoteOverdueHost replaces the host if they are disconnected for longer than the HostGracePeriod setting
+// It reports whether a connected player took over. It is executed with the lobby's mutex locked
+func (l *Lobby) promoteOverdueHost(s Service) bool {
+	after := l.Settings().HostGracePeriod
+	host := l.Host
+	if after <= 0 || host == nil || host.IsConnected() || host.DisconnectedAt.IsZero() || l.HasEnded() {
+		return false
+	}
+	if time.Since(host.DisconnectedAt) < after {
+		return false
+	}
+
+	successor := l.successor()
+	if successor == nil {
+		return false
+	}
+	if err := s.handleEvent(l, leHostHandoverRequested{Username: successor.Username}, lobbySystemUser); err != nil {
+		slog.Error("Error promoting a new host", "username", successor.Username, "err", err)
+		return false
+	}
+	return true
+}
+
+// leHostHandoverRequested is a system event that is triggered when the host stays disconnected for too long
+// The player is the successor of the host, see successor
+type leHostHandoverRequested struct {
+	Username game.Username
+}
+
+func (e leHostHandoverRequested) String() string {
+	return "LEHostHandoverRequested: " + string(e.Username)
+}
+
+func (e leHostHandoverRequested) Handle(_ Service, l *Lobby, _ *User) error {
+	player := l.userByUsername(e.Username)
+	if player == nil {
+		return errors.New("New host not found in the lobby: " + string(e.Username))
+	}
+
+	return l.promoteToHost(player)
+}
+
+// successor returns the player who takes over from a disconnected host
+// It is the co-host if they are connected, otherwise the first connected player by username
+func (l *Lobby) successor() *User {
+	if l.CoHost != nil && l.CoHost.IsConnected() && l.Users[l.CoHost.ClientID] == l.CoHost {
+		return l.CoHost
+	}
+
+	var candidates []*User
+	for _, user := range l.Users {
+		if user.IsConnected() {
+			candidates = append(candidates, user)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(candidates, func(a, b *User) int {
+		return strings.Compare(string(a.Username), string(b.Username))
+	})
+	return candidates[0]
+}
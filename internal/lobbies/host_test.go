@@ -0,0 +1,263 @@
+package lobbies
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTransferHost(t *testing.T) {
+	repo := NewRepositoryInMemory()
+	lobby := Example1234Lobby()
+	if err := repo.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	oldHost := &User{ClientID: "host", Username: "HOST"}
+	lobby.Host = oldHost
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	if err := lobby.AddPlayer(player.Username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lobby.Users[player.ClientID] = player
+
+	transfer := leHostTransferRequested{Username: player.Username}
+	if err := transfer.Handle(Service{}, lobby, player); err == nil {
+		t.Error("Expected error when a non-host transfers the host role")
+	}
+
+	if err := transfer.Handle(Service{}, lobby, oldHost); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobby.Host != player {
+		t.Error("Expected the player to become the host")
+	}
+	if lobby.HasUser(ExampleUser.Username) {
+		t.Error("Expected the new host to stop playing")
+	}
+	if oldHost.Username != "" {
+		t.Errorf("Expected the previous host to have no username, got %q", oldHost.Username)
+	}
+
+	if l, err := repo.GetLobbyByHost(player.ClientID); err != nil || l != lobby {
+		t.Errorf("Expected the lobby to be found by the new host, got %v", err)
+	}
+	if _, err := repo.GetLobbyByHost(oldHost.ClientID); err == nil {
+		t.Error("Expected the lobby not to be found by the previous host")
+	}
+}
+
+func TestSettingsWhileHostChanges(t *testing.T) {
+	repo := NewRepositoryInMemory()
+	lobby := Example1234Lobby()
+	if err := repo.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := Service{lRepo: repo}
+
+	// The host changes while the settings are requested, e.g. when the host role is transferred
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			lobby.mu.Lock()
+			lobby.Host = &User{ClientID: "host", Username: "HOST"}
+			lobby.mu.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/lobbies/"+lobby.Pin+"/settings", nil)
+		r.SetPathValue("pin", lobby.Pin)
+		r.AddCookie(&http.Cookie{Name: "client-id", Value: "player"})
+		w := httptest.NewRecorder()
+		s.lobbySettingsHandler(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected %d for a player, got %d", http.StatusUnauthorized, w.Code)
+		}
+	}
+	<-done
+}
+
+// newTestConn returns the server side of a websocket connection, the client side discards every message
+func newTestConn(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return <-conns
+}
+
+func TestSuccessor(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	alice := &User{ClientID: "alice", Username: "Alice"}
+	bob := &User{ClientID: "bob", Username: "Bob"}
+	lobby.Users[alice.ClientID] = alice
+	lobby.Users[bob.ClientID] = bob
+	if lobby.successor() != nil {
+		t.Error("Expected disconnected players not to take over")
+	}
+
+	alice.Conn = newTestConn(t)
+	bob.Conn = newTestConn(t)
+	if lobby.successor() != alice {
+		t.Error("Expected the first connected player by username to take over")
+	}
+
+	lobby.CoHost = bob
+	if lobby.successor() != bob {
+		t.Error("Expected the co-host to take over")
+	}
+}
+
+func TestHostPromotion(t *testing.T) {
+	options := NewLobbyOptions()
+	options.HostGracePeriod = 10 * time.Millisecond
+	lobby := createLobby(options)
+	host := &User{ClientID: "host", Username: "HOST", Conn: newTestConn(t)}
+	lobby.Host = host
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username, Conn: newTestConn(t)}
+	if err := lobby.AddPlayer(player.Username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lobby.Users[player.ClientID] = player
+	lobby.CoHost = player
+
+	lobby.mu.Lock()
+	lobby.handleDisconnect(Service{}, host, host.Conn)
+	lobby.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	if lobby.Host != player {
+		t.Fatal("Expected the co-host to take over from the disconnected host")
+	}
+	if lobby.CoHost != nil || lobby.HasUser(ExampleUser.Username) {
+		t.Error("Expected the new host to stop being a co-host and a player")
+	}
+}
+
+func TestCoHostControls(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	lobby.Users[player.ClientID] = player
+	coHost := &User{ClientID: "bob", Username: "bob"}
+	lobby.Users[coHost.ClientID] = coHost
+
+	if err := (leRoundPauseRequested{}).Handle(Service{}, lobby, coHost); err == nil {
+		t.Error("Expected error when a player who isn't the co-host pauses the round")
+	}
+
+	lobby.CoHost = coHost
+	if err := (leRoundPauseRequested{}).Handle(Service{}, lobby, player); err == nil {
+		t.Error("Expected error when a player pauses the round")
+	}
+	if err := (leRoundPauseRequested{}).Handle(Service{}, lobby, coHost); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := (leRoundResumeRequested{}).Handle(Service{}, lobby, coHost); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	kick := leKickPlayerRequested{Username: player.Username, KeepPoints: "on"}
+	if err := kick.Handle(Service{}, lobby, player); err == nil {
+		t.Error("Expected error when a player kicks a player")
+	}
+	if err := kick.Handle(Service{}, lobby, coHost); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobby.HasUser(player.Username) {
+		t.Error("Expected the co-host to kick the player")
+	}
+
+	// Only the host picks the co-host and hands the lobby over
+	if err := (leHostTransferRequested{Username: coHost.Username}).Handle(Service{}, lobby, coHost); err == nil {
+		t.Error("Expected error when the co-host transfers the host role")
+	}
+}
+
+func TestHostPromotionOnConnect(t *testing.T) {
+	options := NewLobbyOptions()
+	options.HostGracePeriod = time.Second
+	lobby := createLobby(options)
+	lobby.Host = &User{ClientID: "host", Username: "HOST", DisconnectedAt: time.Now().Add(-time.Minute)}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username, DisconnectedAt: time.Now()}
+	if err := lobby.AddPlayer(player.Username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lobby.Users[player.ClientID] = player
+
+	// Nobody was connected to take over when the grace period ended
+	if _, err := (Service{}).connectClient(lobby, newTestConn(t), player.ClientID, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobby.Host != player {
+		t.Fatal("Expected the reconnected player to take over from the disconnected host")
+	}
+}
+
+func TestCoHostViews(t *testing.T) {
+	lobby := ExampleLobbyOnAnswerView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	lobby.Users[player.ClientID] = player
+	coHost := &User{ClientID: "bob", Username: "bob"}
+	lobby.Users[coHost.ClientID] = coHost
+	lobby.CoHost = coHost
+
+	var out strings.Builder
+	if err := AnswerView.Execute(&out, ViewData{Lobby: lobby, User: coHost}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "next-question-btn") || !strings.Contains(out.String(), "kick-player-form") {
+		t.Error("Expected the co-host to see the game controls")
+	}
+	if strings.Contains(out.String(), "transfer-host-form") {
+		t.Error("Expected only the host to hand the lobby over")
+	}
+
+	out.Reset()
+	if err := AnswerView.Execute(&out, ViewData{Lobby: lobby, User: player}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "next-question-btn") {
+		t.Error("Expected a player not to see the game controls")
+	}
+
+	waiting := Example1234Lobby()
+	waiting.Host = lobby.Host
+	waiting.Users = lobby.Users
+	waiting.CoHost = coHost
+	out.Reset()
+	if err := WaitingRoomView.Execute(&out, ViewData{Lobby: waiting, User: coHost}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "kick-player-form") {
+		t.Error("Expected the co-host to kick players in the waiting room")
+	}
+}
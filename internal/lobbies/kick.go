@@ -0,0 +1,93 @@
+package lobbies
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+const kickedMessage = "The host removed you from this game, you can't join it again"
+
+type errPlayerBanned struct{}
+
+func (e errPlayerBanned) Error() string {
+	return "player is banned from the lobby"
+}
+
+// leKickPlayerRequested is an event that is triggered when the host kicks a player out of the lobby
+// The player is banned, so they can't join the lobby again with the same client
+type leKickPlayerRequested struct {
+	Username   game.Username
+	KeepPoints string `json:"keep-points"` // Set when the player's points should stay on the leaderboard
+}
+
+func (e leKickPlayerRequested) String() string {
+	return "LEKickPlayerRequested: " + string(e.Username)
+}
+
+func (e leKickPlayerRequested) Handle(_ Service, l *Lobby, initiator *User) error {
+	if !l.canControl(initiator) {
+		return errors.New("Only the host and the co-host can kick a player")
+	}
+
+	player := l.userByUsername(e.Username)
+	if player == nil {
+		return errors.New("Kicked player not found in the lobby: " + string(e.Username))
+	}
+
+	var err error
+	if l.HasStarted() && e.KeepPoints != "" {
+		err = l.RetirePlayer(player.Username)
+	} else {
+		err = l.RemovePlayer(player.Username)
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Player kicked", "Lobby-Pin", l.Pin, "username", player.Username, "keep-points", e.KeepPoints != "")
+	delete(l.Users, player.ClientID)
+	if l.CoHost == player {
+		l.CoHost = nil
+	}
+	l.banned[player.ClientID] = true
+
+	if player.IsConnected() {
+		player.closeWithMessage(kickedMessage)
+	}
+
+	// The host's and the co-host's views list the players, the others only need the new player count
+	for _, user := range []*User{l.Host, l.CoHost} {
+		if user == nil || !user.IsConnected() {
+			continue
+		}
+		if err := l.sendViewToUser(l.View(), user); err != nil {
+			slog.Error("Error sending view after a kick", "username", user.Username, "err", err)
+		}
+	}
+	if l.InRound() {
+		l.sendPlayerCountToAll()
+	}
+	return nil
+}
+
+// IsBanned reports whether the client was kicked out of the lobby
+func (l *Lobby) IsBanned(clientID common.ClientID) bool {
+	return l.banned[clientID]
+}
+
+// HasUser reports whether a player with the username is still in the lobby
+func (l *Lobby) HasUser(username game.Username) bool {
+	return l.userByUsername(username) != nil
+}
+
+func (l *Lobby) userByUsername(username game.Username) *User {
+	for _, user := range l.Users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
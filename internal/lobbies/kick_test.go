@@ -0,0 +1,74 @@
+package lobbies
+
+import (
+	"testing"
+)
+
+func TestKickPlayer(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	lobby.Users[player.ClientID] = player
+
+	kick := leKickPlayerRequested{Username: player.Username, KeepPoints: "on"}
+	if err := kick.Handle(Service{}, lobby, player); err == nil {
+		t.Error("Expected error when a non-host kicks a player")
+	}
+
+	if err := kick.Handle(Service{}, lobby, lobby.Host); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobby.HasUser(player.Username) {
+		t.Error("Expected the kicked player to be removed from the lobby")
+	}
+	if !lobby.IsBanned(player.ClientID) {
+		t.Error("Expected the kicked player to be banned")
+	}
+	if answering := lobby.Round.PlayersAnswering(); answering != 0 {
+		t.Errorf("Expected no players answering, got %d", answering)
+	}
+
+	// Points are kept, so the player stays on the leaderboard
+	if leaderboard := lobby.Leaderboard(); len(leaderboard) != 1 || leaderboard[0].Username != player.Username {
+		t.Errorf("Expected the kicked player to stay on the leaderboard, got %v", leaderboard)
+	}
+
+	if _, err := handleNewWebsocketConn(lobby, nil, player.ClientID); err != (errPlayerBanned{}) {
+		t.Errorf("Expected errPlayerBanned, got %v", err)
+	}
+}
+
+func TestKickPlayerDiscardPoints(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	lobby.Users[player.ClientID] = player
+
+	if err := (leKickPlayerRequested{Username: player.Username}).Handle(Service{}, lobby, lobby.Host); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if leaderboard := lobby.Leaderboard(); len(leaderboard) != 0 {
+		t.Errorf("Expected an empty leaderboard, got %v", leaderboard)
+	}
+}
+
+func TestKickPlayerInWaitingRoom(t *testing.T) {
+	lobby := Example1234Lobby()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	if err := lobby.AddPlayer(player.Username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lobby.Users[player.ClientID] = player
+
+	if err := (leKickPlayerRequested{Username: player.Username}).Handle(Service{}, lobby, lobby.Host); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobby.HasUser(player.Username) || !lobby.IsBanned(player.ClientID) {
+		t.Error("Expected the kicked player to be removed and banned")
+	}
+	// The username is free again for other players
+	if err := lobby.AddPlayer(player.Username); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
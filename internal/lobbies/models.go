@@ -18,6 +18,24 @@ type Lobby struct {
 	Pin   string
 	Host  *User
 	Users map[common.ClientID]*User
+	// Player who takes over when the host is disconnected for too long, nil if the host didn't pick one
+	CoHost *User
+	// Clients watching the game without playing, they are not part of the game
+	Spectators map[common.ClientID]*User
+	CreatedAt  time.Time
+	// Last time a client connected or sent an event, idle lobbies are reclaimed by the reaper
+	lastActivity time.Time
+	// Clients kicked by the host, they can't join the lobby again
+	banned map[common.ClientID]bool
+	// Clients connected through other instances of the cluster, by their connection ID
+	remoteClients map[string]remoteClient
+	// Clients receiving views through Server-Sent Events, their events are posted
+	sseClients map[common.ClientID]*User
+	// Stops receiving the messages of remote clients, it is set once the lobby is added
+	unsubscribe func()
+	// Every event handled in the lobby, it is stored with the past game
+	log   []LogEntry
+	clock *eventClock
 	game.Game
 }
 
@@ -26,6 +44,13 @@ type lobbyOptions struct {
 	Pin string
 }
 
+// IsHost reports whether the client is the host of the lobby
+func (l *Lobby) IsHost(clientID common.ClientID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Host != nil && l.Host.ClientID == clientID
+}
+
 func (l *Lobby) View() *template.Template {
 	if !l.Game.HasStarted() {
 		return WaitingRoomView
@@ -56,10 +81,19 @@ func NewLobbyOptions() lobbyOptions {
 }
 
 func createLobby(options lobbyOptions) *Lobby {
+	now := time.Now()
+	clock := &eventClock{}
 	return &Lobby{
-		Pin:   options.Pin, // If it's empty, it will be generated by repository
-		Users: make(map[common.ClientID]*User),
-		Game:  game.CreateGame(options.GameSettings),
+		CreatedAt:     now,
+		lastActivity:  now,
+		Pin:           options.Pin, // If it's empty, it will be generated by repository
+		Users:         make(map[common.ClientID]*User),
+		banned:        make(map[common.ClientID]bool),
+		Spectators:    make(map[common.ClientID]*User),
+		remoteClients: make(map[string]remoteClient),
+		sseClients:    make(map[common.ClientID]*User),
+		clock:         clock,
+		Game:          game.CreateGame(options.GameSettings, clock),
 	}
 }
 
@@ -68,15 +102,61 @@ func (l *Lobby) sendViewToAll(tmpl *template.Template) {
 		Lobby: l,
 		User:  l.Host,
 	}
-	if err := l.Host.writeTemplate(tmpl, vData); err != nil {
-		slog.Error("Error sending view to host", "template", tmpl.Name(), "error", err)
+	if l.Host.IsConnected() {
+		if err := l.Host.writeTemplate(tmpl, vData); err != nil {
+			slog.Error("Error sending view to host", "template", tmpl.Name(), "error", err)
+		}
 	}
 	for _, user := range l.Users {
+		// Disconnected users get the current view once they reconnect
+		if !user.IsConnected() {
+			continue
+		}
 		vData.User = user
 		if err := user.writeTemplate(tmpl, vData); err != nil {
 			slog.Error("Error sending view to user", "template", tmpl.Name(), "error", err)
 		}
 	}
+	for _, spectator := range l.Spectators {
+		if !spectator.IsConnected() {
+			continue
+		}
+		vData.User = spectator
+		if err := spectator.writeTemplate(tmpl, vData); err != nil {
+			slog.Error("Error sending view to spectator", "template", tmpl.Name(), "error", err)
+		}
+	}
+}
+
+// sendPlayerCountToAll updates the number of players left to answer in the QuestionView
+func (l *Lobby) sendPlayerCountToAll() {
+	vData := ViewData{
+		Lobby: l,
+		User:  l.Host,
+	}
+	if l.Host.IsConnected() {
+		if err := l.Host.writeNamedTemplate(QuestionView, "player-count", vData); err != nil {
+			slog.Error("Error sending player count to host", "error", err)
+		}
+	}
+	for _, user := range l.Users {
+		if !user.IsConnected() {
+			continue
+		}
+		vData.User = user
+		if err := user.writeNamedTemplate(QuestionView, "player-count", vData); err != nil {
+			slog.Error("Error sending player count to user", "error", err)
+		}
+	}
+	for _, spectator := range l.Spectators {
+		if !spectator.IsConnected() {
+			continue
+		}
+		vData.User = spectator
+		if err := spectator.writeNamedTemplate(QuestionView, "player-count", vData); err != nil {
+			slog.Error("Error sending player count to spectator", "error", err)
+		}
+	}
 }
 
 func (l *Lobby) sendViewToUser(tmpl *template.Template, user *User) error {
@@ -90,3 +170,8 @@ func (l *Lobby) sendViewToUser(tmpl *template.Template, user *User) error {
 	}
 	return nil
 }
+
+// touch records activity in the lobby, it isn't reaped as idle for a while
+func (l *Lobby) touch() {
+	l.lastActivity = time.Now()
+}
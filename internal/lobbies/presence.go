@@ -0,0 +1,155 @@
+package lobbies
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+// IsConnected reports whether the user has an open websocket connection to the lobby
+func (u *User) IsConnected() bool {
+	return u.Conn != nil
+}
+
+// DisconnectedUsers returns the players that lost their connection, sorted by username
+func (l *Lobby) DisconnectedUsers() []*User {
+	var users []*User
+	for _, user := range l.Users {
+		if !user.IsConnected() {
+			users = append(users, user)
+		}
+	}
+
+	slices.SortFunc(users, func(a, b *User) int {
+		return strings.Compare(string(a.Username), string(b.Username))
+	})
+	return users
+}
+
+// handleDisconnect marks the user as disconnected once their websocket connection closes
+// Players are marked as away in the game, so rounds don't wait for their answers,
+// and are removed after the RemoveAwayAfter setting if they don't come back.
+// A disconnected host is replaced after the HostGracePeriod setting
+func (l *Lobby) handleDisconnect(s Service, user *User, conn Conn) {
+	// The user already reconnected with a new connection
+	if user.Conn != conn {
+		return
+	}
+
+	user.Conn = nil
+	user.DisconnectedAt = time.Now()
+
+	if user == l.Host {
+		if !l.HasEnded() {
+			slog.Info("Host disconnected", "Lobby-Pin", l.Pin)
+			l.scheduleHostPromotion(s)
+		}
+		return
+	}
+
+	if l.Users[user.ClientID] != user || l.HasEnded() {
+		return
+	}
+
+	slog.Info("Player disconnected", "Lobby-Pin", l.Pin, "username", user.Username)
+	if err := l.SetPlayerAway(user.Username, true); err != nil {
+		slog.Error("Error marking player as away", "username", user.Username, "err", err)
+	}
+	l.scheduleAwayRemoval(s, user)
+	l.sendPresenceUpdate()
+}
+
+// handleReconnect marks a player who got a new websocket connection as back
+func (l *Lobby) handleReconnect(player *User) {
+	player.DisconnectedAt = time.Time{}
+	if l.HasEnded() {
+		return
+	}
+
+	if err := l.SetPlayerAway(player.Username, false); err != nil {
+		slog.Error("Error marking player as back", "username", player.Username, "err", err)
+	}
+	l.sendPresenceUpdate()
+}
+
+// scheduleAwayRemoval removes the player from the lobby if they stay disconnected for too long
+// Before the game starts the player is removed from the game as well,
+// later they are retired from the game and keep their points
+func (l *Lobby) scheduleAwayRemoval(s Service, player *User) {
+	after := l.Settings().RemoveAwayAfter
+	if after <= 0 {
+		return
+	}
+
+	disconnectedAt := player.DisconnectedAt
+	time.AfterFunc(after, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if player.IsConnected() || !player.DisconnectedAt.Equal(disconnectedAt) || l.Users[player.ClientID] != player || l.HasEnded() {
+			return
+		}
+
+		slog.Info("Removing disconnected player", "Lobby-Pin", l.Pin, "username", player.Username)
+		if err := s.handleEvent(l, leAwayPlayerRemoved{Username: player.Username}, lobbySystemUser); err != nil {
+			slog.Error("Error removing player from game", "username", player.Username, "err", err)
+		}
+	})
+}
+
+// leAwayPlayerRemoved is a system event that is triggered when a player stays disconnected for too long
+type leAwayPlayerRemoved struct {
+	Username game.Username
+}
+
+func (e leAwayPlayerRemoved) String() string {
+	return "LEAwayPlayerRemoved: " + string(e.Username)
+}
+
+func (e leAwayPlayerRemoved) Handle(_ Service, l *Lobby, _ *User) error {
+	player := l.userByUsername(e.Username)
+	if player == nil {
+		return errors.New("Away player not found in the lobby: " + string(e.Username))
+	}
+
+	var err error
+	if !l.HasStarted() {
+		err = l.RemovePlayer(player.Username)
+	} else {
+		err = l.RetirePlayer(player.Username)
+	}
+	if err != nil {
+		return err
+	}
+
+	delete(l.Users, player.ClientID)
+	l.sendPresenceUpdate()
+	return nil
+}
+
+// sendPresenceUpdate refreshes the parts of the current view that depend on who is connected
+func (l *Lobby) sendPresenceUpdate() {
+	if l.Host == nil || !l.Host.IsConnected() {
+		return
+	}
+
+	switch {
+	case !l.HasStarted():
+		if err := l.sendViewToUser(WaitingRoomView, l.Host); err != nil {
+			slog.Error("Error sending waiting room to host", "err", err)
+		}
+	case l.InRound():
+		vData := ViewData{
+			Lobby: l,
+			User:  l.Host,
+		}
+		if err := l.Host.writeNamedTemplate(QuestionView, "presence", vData); err != nil {
+			slog.Error("Error sending presence to host", "err", err)
+		}
+		l.sendPlayerCountToAll()
+	}
+}
@@ -0,0 +1,50 @@
+package lobbies
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHandleDisconnect(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+	lobby.Users[player.ClientID] = player
+
+	lobby.handleDisconnect(Service{}, player, nil)
+	if player.DisconnectedAt.IsZero() {
+		t.Error("Expected the disconnection time to be set")
+	}
+	if !lobby.IsPlayerAway(player.Username) {
+		t.Error("Expected the disconnected player to be away")
+	}
+	if answering := lobby.Round.PlayersAnswering(); answering != 0 {
+		t.Errorf("Expected no players answering, got %d", answering)
+	}
+
+	lobby.handleReconnect(player)
+	if lobby.IsPlayerAway(player.Username) {
+		t.Error("Expected the reconnected player not to be away")
+	}
+}
+
+func TestPresenceInViews(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	lobby.Users[ExampleUser.ClientID] = &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username}
+
+	var out strings.Builder
+	if err := QuestionView.Execute(&out, ViewData{Lobby: lobby, User: lobby.Host}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Disconnected: "+string(ExampleUser.Username)) {
+		t.Error("Expected the host to see the disconnected player")
+	}
+
+	waiting := Example1234Lobby()
+	waiting.Host = lobby.Host
+	waiting.Users = lobby.Users
+	if err := WaitingRoomView.Execute(io.Discard, ViewData{Lobby: waiting, User: waiting.Host}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package lobbies
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/gorilla/websocket"
+)
+
+// JSONProtocol is the websocket subprotocol of clients exchanging JSON messages instead of HTMX forms and HTML views
+// It is meant for clients that aren't browsers, like mobile apps and bots.
+// Every message is a JSON object with a "type".
+//
+// The client sends the events of the lobby:
+//
+//	{"type": "set-username", "username": "alice"}
+//	{"type": "change-username"}
+//	{"type": "start-game"}                               host only
+//	{"type": "answer", "round": 0, "answer": 2}          answer is the index in round.answers of the state
+//	{"type": "skip-to-answer"}                           host only
+//	{"type": "pause-round"}                              host only
+//	{"type": "resume-round"}                             host only
+//	{"type": "extend-round", "seconds": 10}              host only
+//	{"type": "next-question"}                            host only
+//	{"type": "finish-game"}                              host only
+//	{"type": "co-host", "username": "bob"}               host only
+//	{"type": "transfer-host", "username": "bob"}         host only
+//	{"type": "kick-player", "username": "bob", "keepPoints": true}   host only
+//
+// The server sends:
+//
+//	{"type": "state", "state": {...}}      the whole state of the lobby as seen by the client, see jsonState
+//	{"type": "error", "message": "..."}    the last event was refused
+//	{"type": "closed", "message": "..."}   the lobby closed the connection, the client shouldn't reconnect
+//
+// The lobby settings are not part of the protocol, they are changed with PUT /lobbies/{pin}/settings
+const JSONProtocol = "kwikquiz.json.v1"
+
+// Subprotocols offered when upgrading to a websocket, clients not asking for any get HTMX views
+var wsSubprotocols = []string{JSONProtocol}
+
+// Views of the JSON protocol, by the template they replace
+var jsonViews = map[*template.Template]string{
+	ChooseUsernameView: "choose-username",
+	WaitingRoomView:    "waiting-room",
+	QuestionView:       "question",
+	AnswerView:         "answer",
+	onFinishView:       "finished",
+}
+
+const (
+	jsonMsgState  = "state"
+	jsonMsgError  = "error"
+	jsonMsgClosed = "closed"
+)
+
+type jsonMessage struct {
+	Type    string     `json:"type"`
+	State   *jsonState `json:"state,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// jsonState is the lobby as seen by a client of the JSON protocol
+type jsonState struct {
+	View        string        `json:"view"` // choose-username, waiting-room, question, answer or finished
+	Pin         string        `json:"pin"`
+	Role        string        `json:"role"` // host, co-host, player or spectator
+	Username    game.Username `json:"username,omitempty"`
+	Quiz        string        `json:"quiz"`
+	Questions   int           `json:"questions"`
+	Players     []jsonPlayer  `json:"players"`
+	Score       *int          `json:"score,omitempty"` // Points of the player, once the game has started
+	Round       *jsonRound    `json:"round,omitempty"` // The current or last round, once the game has started
+	Leaderboard []game.Score  `json:"leaderboard,omitempty"`
+	PastGameID  int64         `json:"pastGameId,omitempty"` // Set once the game is finished and stored
+}
+
+type jsonPlayer struct {
+	Username  game.Username `json:"username"`
+	Connected bool          `json:"connected"`
+	CoHost    bool          `json:"coHost,omitempty"`
+}
+
+type jsonRound struct {
+	Number           int       `json:"number"`
+	Question         string    `json:"question"`
+	Answers          []string  `json:"answers"`
+	SubmittedAnswer  int       `json:"submittedAnswer"` // -1 if the player hasn't answered
+	PlayersAnswering int       `json:"playersAnswering"`
+	Paused           bool      `json:"paused"`
+	ReadingTimeout   time.Time `json:"readingTimeout"`
+	Timeout          time.Time `json:"timeout"`
+	Finished         bool      `json:"finished"`
+	Points           *int      `json:"points,omitempty"` // Points of the player in the round, once it's finished
+}
+
+// jsonEvent is an event sent by a client of the JSON protocol
+type jsonEvent struct {
+	Type       string        `json:"type"`
+	Username   game.Username `json:"username"`
+	Round      int           `json:"round"`
+	Answer     int           `json:"answer"`
+	Seconds    int           `json:"seconds"`
+	KeepPoints bool          `json:"keepPoints"`
+}
+
+// parseJSONEvent parses an event sent by a client of the JSON protocol into the same events the HTMX views send
+func parseJSONEvent(data []byte) (lobbyEvent, error) {
+	var e jsonEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	switch e.Type {
+	case "set-username":
+		return leNewUsernameSubmitted{Username: e.Username}, nil
+	case "change-username":
+		return leUsernameChangeRequested{}, nil
+	case "start-game":
+		return leGameStartRequested{}, nil
+	case "answer":
+		return leAnswerSubmitted{QuestionIdx: e.Round, AnswerIdx: e.Answer}, nil
+	case "skip-to-answer":
+		return leSkipToAnswerRequested{}, nil
+	case "pause-round":
+		return leRoundPauseRequested{}, nil
+	case "resume-round":
+		return leRoundResumeRequested{}, nil
+	case "extend-round":
+		return leRoundExtendRequested{By: time.Duration(e.Seconds) * time.Second}, nil
+	case "next-question":
+		return leNextQuestionRequested{}, nil
+	case "finish-game":
+		return leEndGameRequested{}, nil
+	case "co-host":
+		return leCoHostRequested{Username: e.Username}, nil
+	case "transfer-host":
+		return leHostTransferRequested{Username: e.Username}, nil
+	case "kick-player":
+		event := leKickPlayerRequested{Username: e.Username}
+		if e.KeepPoints {
+			event.KeepPoints = "on"
+		}
+		return event, nil
+	default:
+		return nil, errors.New("unrecognized event type: " + e.Type)
+	}
+}
+
+// usesJSON reports whether the client speaks the JSON protocol
+func (client *User) usesJSON() bool {
+	return client.Conn != nil && client.Conn.Subprotocol() == JSONProtocol
+}
+
+// writeJSON writes the message on websocket connection to the user
+func (client *User) writeJSON(msg jsonMessage) error {
+	if client.Conn == nil {
+		return errors.New("client.Conn is nil")
+	}
+	w, err := client.Conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return json.NewEncoder(w).Encode(msg)
+}
+
+// reportError tells a client of the JSON protocol its event was refused
+// The HTMX views only show alerts for the errors users can act on themselves
+func (client *User) reportError(err error) {
+	if !client.usesJSON() {
+		return
+	}
+	if err := client.writeJSON(jsonMessage{Type: jsonMsgError, Message: err.Error()}); err != nil {
+		slog.Error("Error sending error message", "client-id", client.ClientID, "err", err)
+	}
+}
+
+// jsonMessageFor returns the message of the JSON protocol sent in place of the template
+// It accepts the same data as the template
+func jsonMessageFor(tmpl *template.Template, data any) (jsonMessage, error) {
+	switch tmpl {
+	case ConnectionClosedView:
+		message, _ := data.(string)
+		return jsonMessage{Type: jsonMsgClosed, Message: message}, nil
+	}
+
+	view, ok := jsonViews[tmpl]
+	if !ok {
+		return jsonMessage{}, errors.New("template has no JSON message: " + tmpl.Name())
+	}
+	switch data := data.(type) {
+	case ViewData:
+		return jsonMessage{Type: jsonMsgState, State: newJSONState(view, data, 0)}, nil
+	case OnFinishData:
+		return jsonMessage{Type: jsonMsgState, State: newJSONState(view, data.ViewData, data.PastGameID)}, nil
+	default:
+		return jsonMessage{}, errors.New("unexpected view data for template: " + tmpl.Name())
+	}
+}
+
+func newJSONState(view string, data ViewData, pastGameID int64) *jsonState {
+	l, user := data.Lobby, data.User
+	state := &jsonState{
+		View:       view,
+		Pin:        l.Pin,
+		Username:   user.Username,
+		Questions:  l.QuestionsCount(),
+		Players:    []jsonPlayer{},
+		PastGameID: pastGameID,
+	}
+	if quiz := l.Quiz(); quiz != nil {
+		state.Quiz = quiz.Title()
+	}
+
+	switch {
+	case l.Host == user:
+		state.Role = "host"
+	case user.Spectator:
+		state.Role = "spectator"
+	case l.CoHost == user:
+		state.Role = "co-host"
+	default:
+		state.Role = "player"
+	}
+
+	for _, u := range l.Users {
+		if u.Username == "" {
+			continue
+		}
+		state.Players = append(state.Players, jsonPlayer{
+			Username:  u.Username,
+			Connected: u.IsConnected(),
+			CoHost:    l.CoHost == u,
+		})
+	}
+
+	if !l.HasStarted() {
+		return state
+	}
+
+	isPlayer := state.Role == "player" || state.Role == "co-host"
+	if isPlayer {
+		score := l.Scores()[user.Username]
+		state.Score = &score
+	}
+	if view == "answer" || view == "finished" {
+		state.Leaderboard = l.Leaderboard()
+	}
+
+	if l.Round != nil {
+		round := &jsonRound{
+			Number:           l.RoundNum(),
+			SubmittedAnswer:  l.Round.SubmittedAnswerIdx(user.Username),
+			PlayersAnswering: l.Round.PlayersAnswering(),
+			Paused:           l.Round.IsPaused(),
+			ReadingTimeout:   l.Round.ReadingTimeout(),
+			Timeout:          l.Round.Timeout(),
+			Finished:         l.Round.HasFinished(),
+		}
+		round.Question = l.Round.Question().Prompt()
+		for _, answer := range l.Round.AnswersFor(user.Username) {
+			round.Answers = append(round.Answers, answer.Text())
+		}
+		if results, err := l.Round.Results(); err == nil && isPlayer {
+			points := results[user.Username]
+			round.Points = &points
+		}
+		state.Round = round
+	}
+	return state
+}
@@ -0,0 +1,191 @@
+package lobbies
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/gorilla/websocket"
+)
+
+// dialJSON connects a new client of the JSON protocol to the lobby's websocket on the server
+func dialJSON(t *testing.T, server *httptest.Server, pin string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/lobbies/" + pin + "/ws"
+	dialer := websocket.Dialer{Subprotocols: []string{JSONProtocol}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if conn.Subprotocol() != JSONProtocol {
+		t.Fatalf("Expected the %s subprotocol, got %q", JSONProtocol, conn.Subprotocol())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readJSON returns the next message sent to the client
+func readJSON(t *testing.T, conn *websocket.Conn) jsonMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg jsonMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return msg
+}
+
+// readState returns the state of the next message sent to the client, failing on other messages
+func readState(t *testing.T, conn *websocket.Conn, view string) *jsonState {
+	t.Helper()
+	msg := readJSON(t, conn)
+	if msg.Type != jsonMsgState || msg.State == nil {
+		t.Fatalf("Expected a state message, got %+v", msg)
+	}
+	if msg.State.View != view {
+		t.Fatalf("Expected the %s view, got %s", view, msg.State.View)
+	}
+	return msg.State
+}
+
+func sendJSON(t *testing.T, conn *websocket.Conn, event string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(event)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestJSONProtocol(t *testing.T) {
+	s, _ := newTestService(t)
+	lobby := Example1234Lobby()
+	// Answers are accepted right away
+	settings := lobby.Settings()
+	settings.ReadingTime = 0
+	if err := lobby.UpdateSettings(settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := httptest.NewServer(s.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	host := dialJSON(t, server, lobby.Pin)
+	if state := readState(t, host, "waiting-room"); state.Role != "host" || state.Pin != lobby.Pin {
+		t.Errorf("Expected the host of lobby %s, got %+v", lobby.Pin, state)
+	}
+
+	player := dialJSON(t, server, lobby.Pin)
+	readState(t, player, "choose-username")
+
+	sendJSON(t, player, `{"type": "start-game"}`)
+	if msg := readJSON(t, player); msg.Type != jsonMsgError {
+		t.Errorf("Expected an error when a player starts the game, got %+v", msg)
+	}
+	sendJSON(t, player, `{"type": "unknown"}`)
+	if msg := readJSON(t, player); msg.Type != jsonMsgError {
+		t.Errorf("Expected an error for an unknown event, got %+v", msg)
+	}
+
+	sendJSON(t, player, `{"type": "set-username", "username": "bot"}`)
+	state := readState(t, player, "waiting-room")
+	if state.Role != "player" || state.Username != "bot" {
+		t.Errorf("Expected the player bot, got %+v", state)
+	}
+	state = readState(t, host, "waiting-room")
+	if len(state.Players) != 1 || state.Players[0].Username != "bot" || !state.Players[0].Connected {
+		t.Errorf("Expected the host to see the connected player, got %+v", state.Players)
+	}
+
+	sendJSON(t, host, `{"type": "start-game"}`)
+	readState(t, host, "question")
+	state = readState(t, player, "question")
+	if state.Round == nil || len(state.Round.Answers) == 0 || state.Round.SubmittedAnswer != -1 {
+		t.Fatalf("Expected an unanswered round, got %+v", state.Round)
+	}
+	if state.Score == nil || *state.Score != 0 {
+		t.Errorf("Expected the player to have no points yet, got %v", state.Score)
+	}
+
+	sendJSON(t, player, `{"type": "answer", "round": 0, "answer": 1}`)
+	state = readState(t, player, "question")
+	if state.Round.SubmittedAnswer != 1 {
+		t.Errorf("Expected the submitted answer 1, got %d", state.Round.SubmittedAnswer)
+	}
+}
+
+func TestJSONProtocolQuestionText(t *testing.T) {
+	s, _ := newTestService(t)
+	options := NewLobbyOptions()
+	options.Pin = "4321"
+	options.ReadingTime = 0
+	options.Quiz = quiz.Quiz{
+		TitleField: "Math",
+		Questions: []quiz.Question{
+			quiz.NewQuestion("What is the area of a circle?",
+				quiz.Answer{LaTeX: `\pi r^2`, IsCorrect: true},
+				quiz.Answer{TextField: "2r"},
+				quiz.Answer{ImageName: "circle.png"},
+			),
+		},
+	}
+	lobby := createLobby(options)
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := httptest.NewServer(s.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	host := dialJSON(t, server, lobby.Pin)
+	readState(t, host, "waiting-room")
+	player := dialJSON(t, server, lobby.Pin)
+	readState(t, player, "choose-username")
+	sendJSON(t, player, `{"type": "set-username", "username": "bot"}`)
+	readState(t, player, "waiting-room")
+	readState(t, host, "waiting-room")
+
+	sendJSON(t, host, `{"type": "start-game"}`)
+	state := readState(t, player, "question")
+	if state.Round == nil || state.Round.Question != "What is the area of a circle?" {
+		t.Fatalf("Expected the text of the question, got %+v", state.Round)
+	}
+	answers := make(map[string]bool)
+	for _, answer := range state.Round.Answers {
+		answers[answer] = true
+	}
+	for _, text := range []string{`\pi r^2`, "2r", "circle.png"} {
+		if !answers[text] {
+			t.Errorf("Expected the answer %q, got %q", text, state.Round.Answers)
+		}
+	}
+}
+
+func TestParseJSONEvent(t *testing.T) {
+	tests := []struct {
+		data  string
+		event lobbyEvent
+	}{
+		{`{"type": "set-username", "username": "bot"}`, leNewUsernameSubmitted{Username: "bot"}},
+		{`{"type": "answer", "round": 2, "answer": 3}`, leAnswerSubmitted{QuestionIdx: 2, AnswerIdx: 3}},
+		{`{"type": "extend-round", "seconds": 10}`, leRoundExtendRequested{By: 10 * time.Second}},
+		{`{"type": "kick-player", "username": "bot", "keepPoints": true}`, leKickPlayerRequested{Username: "bot", KeepPoints: "on"}},
+		{`{"type": "kick-player", "username": "bot"}`, leKickPlayerRequested{Username: "bot"}},
+		{`{"type": "finish-game"}`, leEndGameRequested{}},
+	}
+	for _, test := range tests {
+		event, err := parseJSONEvent([]byte(test.data))
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", test.data, err)
+			continue
+		}
+		if event != test.event {
+			t.Errorf("Expected %v for %s, got %v", test.event, test.data, event)
+		}
+	}
+
+	if _, err := parseJSONEvent([]byte(`{"HEADERS": {"HX-Trigger-Name": "start-game-btn"}}`)); err == nil {
+		t.Error("Expected error for an HTMX event")
+	}
+}
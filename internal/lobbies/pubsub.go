@@ -0,0 +1,93 @@
+package lobbies
+
+import (
+	"sync"
+)
+
+// PubSub delivers messages between kwikquiz instances
+// Every subscriber of a topic receives the messages published to it after subscribing, in order
+type PubSub interface {
+	Publish(topic string, msg []byte) error
+	// Subscribe returns the channel the topic's messages are delivered to,
+	// it is closed after calling the returned unsubscribe function
+	Subscribe(topic string) (<-chan []byte, func())
+}
+
+// Number of messages buffered for a subscriber before publishing to its topic blocks
+const subscriptionBuffer = 256
+
+type localSubscription struct {
+	// Held while publishing, so the channel of the messages isn't closed during a send
+	mu     sync.RWMutex
+	closed bool
+	msgs   chan []byte
+	done   chan struct{}
+}
+
+// In-process PubSub, instances sharing it behave as if they used a message broker
+type localPubSub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*localSubscription]struct{}
+}
+
+func NewLocalPubSub() *localPubSub {
+	return &localPubSub{
+		topics: make(map[string]map[*localSubscription]struct{}),
+	}
+}
+
+// Publish blocks while the buffer of a subscriber is full, the other topics aren't affected
+func (ps *localPubSub) Publish(topic string, msg []byte) error {
+	ps.mu.RLock()
+	subs := make([]*localSubscription, 0, len(ps.topics[topic]))
+	for sub := range ps.topics[topic] {
+		subs = append(subs, sub)
+	}
+	ps.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.mu.RLock()
+		if !sub.closed {
+			select {
+			case sub.msgs <- msg:
+			case <-sub.done:
+			}
+		}
+		sub.mu.RUnlock()
+	}
+	return nil
+}
+
+func (ps *localPubSub) Subscribe(topic string) (<-chan []byte, func()) {
+	sub := &localSubscription{
+		msgs: make(chan []byte, subscriptionBuffer),
+		done: make(chan struct{}),
+	}
+
+	ps.mu.Lock()
+	if ps.topics[topic] == nil {
+		ps.topics[topic] = make(map[*localSubscription]struct{})
+	}
+	ps.topics[topic][sub] = struct{}{}
+	ps.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			ps.mu.Lock()
+			delete(ps.topics[topic], sub)
+			if len(ps.topics[topic]) == 0 {
+				delete(ps.topics, topic)
+			}
+			ps.mu.Unlock()
+
+			// Publishers blocked on a full buffer give up first, so the lock can be taken
+			close(sub.done)
+			sub.mu.Lock()
+			sub.closed = true
+			close(sub.msgs)
+			sub.mu.Unlock()
+		})
+	}
+	return sub.msgs, unsubscribe
+}
@@ -0,0 +1,162 @@
+package lobbies
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+)
+
+// reaperMetrics counts the lobbies reclaimed by the reaper, it is published with the other expvars
+var reaperMetrics = expvar.NewMap("lobbies_reaper")
+
+const (
+	reapIdle    = "idle"
+	reapExpired = "expired"
+)
+
+// reapMessages are shown to the clients still connected to a reclaimed lobby
+var reapMessages = map[string]string{
+	reapIdle:    "This lobby was closed after being inactive for too long",
+	reapExpired: "This lobby was closed after reaching its maximum lifetime",
+}
+
+// ReaperConfig controls when abandoned lobbies are reclaimed
+type ReaperConfig struct {
+	Interval    time.Duration // How often the lobbies are checked
+	IdleTimeout time.Duration // Lobbies without any activity for longer are reclaimed, 0 means never
+	MaxLifetime time.Duration // Lobbies existing for longer are reclaimed, 0 means never
+}
+
+// RunReaper reclaims abandoned lobbies every cfg.Interval until the context is done
+func (s Service) RunReaper(ctx context.Context, cfg ReaperConfig) {
+	if cfg.Interval <= 0 || (cfg.IdleTimeout <= 0 && cfg.MaxLifetime <= 0) {
+		slog.Info("Lobby reaper disabled")
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.reapLobbies(now, cfg)
+		}
+	}
+}
+
+// reapLobbies reclaims the lobbies that are idle or expired at the given time and returns how many were reclaimed
+func (s Service) reapLobbies(now time.Time, cfg ReaperConfig) int {
+	lobbies, err := s.lRepo.GetAllLobbies()
+	if err != nil {
+		slog.Error("Error getting lobbies to reap", "err", err)
+		return 0
+	}
+
+	reaped := 0
+	for _, l := range lobbies {
+		l.mu.Lock()
+		reason := l.reapReason(now, cfg)
+		if reason != "" {
+			s.reapLobby(l, reason)
+			reaped++
+		}
+		l.mu.Unlock()
+	}
+	return reaped
+}
+
+// reapReason returns why the lobby should be reclaimed, or an empty string if it should be kept
+func (l *Lobby) reapReason(now time.Time, cfg ReaperConfig) string {
+	switch {
+	case cfg.MaxLifetime > 0 && now.Sub(l.CreatedAt) >= cfg.MaxLifetime:
+		return reapExpired
+	case cfg.IdleTimeout > 0 && now.Sub(l.lastActivity) >= cfg.IdleTimeout:
+		return reapIdle
+	default:
+		return ""
+	}
+}
+
+// reapLobby removes the lobby, a game that was started is stored in past games first
+func (s Service) reapLobby(l *Lobby, reason string) {
+	slog.Info("Reaping lobby", "Lobby-Pin", l.Pin, "reason", reason, "created-at", l.CreatedAt, "last-activity", l.lastActivity)
+
+	closed, saved, err := s.closeLobby(l, reapMessages[reason])
+	reaperMetrics.Add("connections_closed", int64(closed))
+	if err != nil {
+		reaperMetrics.Add("save_errors", 1)
+	}
+	if saved {
+		reaperMetrics.Add("games_saved", 1)
+	}
+	reaperMetrics.Add("reaped_"+reason, 1)
+}
+
+// closeLobby removes the lobby and closes the connections of its clients with the message
+// A game that was started is finished and stored in past games first.
+// It returns the number of closed connections and whether a game was stored
+func (s Service) closeLobby(l *Lobby, message string) (closed int, saved bool, err error) {
+	if l.HasStarted() && !l.HasEnded() {
+		if err = s.handleEvent(l, leLobbyCloseRequested{}, lobbySystemUser); err != nil {
+			slog.Error("Error finishing game of closed lobby", "Lobby-Pin", l.Pin, "err", err)
+		} else if id, saveErr := s.saveGame(l); saveErr != nil {
+			err = saveErr
+			slog.Error("Error saving game of closed lobby", "Lobby-Pin", l.Pin, "err", err)
+		} else {
+			saved = true
+			slog.Info("Saved partially played game", "Lobby-Pin", l.Pin, "pastGameID", id)
+			s.notifyGameEnded(l, id)
+		}
+	}
+
+	closed = l.closeConnections(message)
+
+	if err := s.removeLobby(l); err != nil {
+		slog.Error("Error deleting closed lobby", "Lobby-Pin", l.Pin, "err", err)
+	}
+	return closed, saved, err
+}
+
+// leLobbyCloseRequested is a system event that is triggered when a lobby with a running game is closed
+// It finishes the game, so it can be stored in past games
+type leLobbyCloseRequested struct{}
+
+func (e leLobbyCloseRequested) String() string {
+	return "LELobbyCloseRequested"
+}
+
+func (e leLobbyCloseRequested) Handle(_ Service, l *Lobby, _ *User) error {
+	// The running round is finished, so its answers are scored
+	if l.InRound() {
+		_ = l.FinishRoundEarly()
+	}
+	return l.Finish()
+}
+
+// closeConnections shows the message to every connected client and closes their websocket connections
+// It returns the number of closed connections
+func (l *Lobby) closeConnections(message string) int {
+	clients := make([]*User, 0, len(l.Users)+len(l.Spectators)+1)
+	if l.Host != nil {
+		clients = append(clients, l.Host)
+	}
+	for _, user := range l.Users {
+		clients = append(clients, user)
+	}
+	for _, spectator := range l.Spectators {
+		clients = append(clients, spectator)
+	}
+
+	closed := 0
+	for _, client := range clients {
+		if !client.IsConnected() {
+			continue
+		}
+		client.closeWithMessage(message)
+		closed++
+	}
+	return closed
+}
@@ -0,0 +1,111 @@
+package lobbies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/pastgames"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestService(t *testing.T) (Service, pastgames.Repository) {
+	t.Helper()
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	pgRepo, err := pastgames.NewRepositorySQLite(db)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	qRepo, err := quiz.NewRepositorySQLite(db)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return NewService(NewRepositoryInMemory(), pgRepo, qRepo), pgRepo
+}
+
+func TestReapLobbies(t *testing.T) {
+	s, pgRepo := newTestService(t)
+	cfg := ReaperConfig{IdleTimeout: time.Minute, MaxLifetime: time.Hour}
+
+	waiting := Example1234Lobby()
+	playing := ExampleLobbyOnReadingView()
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username, Conn: newTestConn(t)}
+	playing.Users[player.ClientID] = player
+	for _, l := range []*Lobby{waiting, playing} {
+		if err := s.lRepo.AddLobby(l); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	now := time.Now()
+	if reaped := s.reapLobbies(now, cfg); reaped != 0 {
+		t.Fatalf("Expected active lobbies to be kept, %d reaped", reaped)
+	}
+
+	// The waiting lobby stays active, the game is abandoned
+	waiting.touch()
+	playing.lastActivity = now.Add(-2 * time.Minute)
+	if reaped := s.reapLobbies(now, cfg); reaped != 1 {
+		t.Fatalf("Expected 1 reaped lobby, got %d", reaped)
+	}
+	if _, err := s.lRepo.GetLobby(playing.Pin); err == nil {
+		t.Error("Expected the idle lobby to be deleted")
+	}
+	if player.IsConnected() {
+		t.Error("Expected the player's connection to be closed")
+	}
+
+	pastGames, err := pgRepo.GetAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pastGames) != 1 {
+		t.Fatalf("Expected the partially played game to be saved, got %v", pastGames)
+	}
+	pastGame, err := pgRepo.GetByID(pastGames[0].ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pastGame.Scores) != 1 || pastGame.Scores[0].Username != string(ExampleUser.Username) {
+		t.Errorf("Expected the player's score to be saved, got %v", pastGame.Scores)
+	}
+
+	// Lobbies are reaped after their lifetime regardless of activity
+	if reaped := s.reapLobbies(waiting.CreatedAt.Add(time.Hour), cfg); reaped != 1 {
+		t.Fatalf("Expected 1 reaped lobby, got %d", reaped)
+	}
+	if pastGames, _ := pgRepo.GetAll(); len(pastGames) != 1 {
+		t.Errorf("Expected a lobby that never started not to be saved, got %d past games", len(pastGames))
+	}
+}
+
+func TestSystemEventKeepsLobbyActive(t *testing.T) {
+	s, _ := newTestService(t)
+	cfg := ReaperConfig{IdleTimeout: time.Minute}
+	l := ExampleLobbyOnReadingView()
+	l.Host = &User{ClientID: "host", Conn: newTestConn(t)}
+	if err := s.lRepo.AddLobby(l); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// E.g. a round finished by auto-advance without any client sending events
+	now := time.Now()
+	l.lastActivity = now.Add(-2 * time.Minute)
+	l.mu.Lock()
+	err := s.handleEvent(l, leShowAnswerRequested{}, lobbySystemUser)
+	l.mu.Unlock()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reaped := s.reapLobbies(now, cfg); reaped != 0 {
+		t.Errorf("Expected the lobby to be kept, %d reaped", reaped)
+	}
+}
@@ -99,14 +99,74 @@ func (s *inMemoryLobbyRepository) GetAllLobbies() ([]*Lobby, error) {
 	return lobbies, nil
 }
 
+// GetLobbyByHost returns the lobby currently hosted by the client
+// The host can change while the lobby runs, so it is read under the lobby's lock.
+// The store's lock is released first, lobbies are deleted from the store while their lock is held
 func (s *inMemoryLobbyRepository) GetLobbyByHost(host common.ClientID) (*Lobby, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, l := range s.lobbies {
-		if l.Host != nil && l.Host.ClientID == host {
+	lobbies, err := s.GetAllLobbies()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lobbies {
+		if l.IsHost(host) {
 			return l, nil
 		}
 	}
 
 	return nil, errLobbyNotFound{}
 }
+
+// Directory records which kwikquiz instance runs each lobby
+// Instances sharing lobbies share a single Directory, so a pin is used by only one of them
+type Directory interface {
+	// Register fails with errLobbyAlreadyExists if another instance runs a lobby with the pin
+	Register(pin string, instanceID string) error
+	Owner(pin string) (instanceID string, err error)
+	Unregister(pin string) error
+}
+
+// In-memory directory, instances sharing it have to run in the same process
+type inMemoryDirectory struct {
+	owners map[string]string
+	mu     sync.RWMutex
+}
+
+func NewDirectoryInMemory() *inMemoryDirectory {
+	return &inMemoryDirectory{
+		owners: make(map[string]string),
+	}
+}
+
+func (d *inMemoryDirectory) Register(pin string, instanceID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if owner, ok := d.owners[pin]; ok && owner != instanceID {
+		return errLobbyAlreadyExists{}
+	}
+
+	d.owners[pin] = instanceID
+	return nil
+}
+
+func (d *inMemoryDirectory) Owner(pin string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	owner, ok := d.owners[pin]
+	if !ok {
+		return "", errLobbyNotFound{}
+	}
+
+	return owner, nil
+}
+
+func (d *inMemoryDirectory) Unregister(pin string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.owners[pin]; !ok {
+		return errLobbyNotFound{}
+	}
+
+	delete(d.owners, pin)
+	return nil
+}
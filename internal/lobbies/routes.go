@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/erykksc/kwikquiz/internal/common"
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
 	"github.com/gorilla/websocket"
 )
 
@@ -19,6 +21,8 @@ func (s Service) NewLobbiesRouter() http.Handler {
 	mux.HandleFunc("POST /lobbies/{$}", s.postLobbiesHandler)
 	mux.HandleFunc("GET /lobbies/{pin}", s.getLobbyByPinHandler)
 	mux.HandleFunc("/lobbies/{pin}/ws", s.getLobbyByPinWsHandler)
+	mux.HandleFunc("GET /lobbies/{pin}/sse", s.getLobbySSEHandler)
+	mux.HandleFunc("POST /lobbies/{pin}/events", s.postLobbyEventHandler)
 	mux.HandleFunc("/lobbies/{pin}/settings", s.lobbySettingsHandler)
 
 	mux.HandleFunc("GET /lobbies/join", s.getLobbyJoinHandler)
@@ -52,12 +56,17 @@ func (s Service) postLobbiesHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	// Otherwise, create a new lobby
+	// Otherwise, create a new lobby, unless the server is shutting down
+	if s.isDraining() {
+		slog.Info("Refusing to create a lobby while shutting down")
+		common.ErrorHandler(w, r, http.StatusServiceUnavailable)
+		return
+	}
 
 	// TODO: Parse possible arguments
 	options := NewLobbyOptions()
 	newLobby := createLobby(options)
-	err = s.lRepo.AddLobby(newLobby)
+	err = s.AddLobby(newLobby)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -74,18 +83,26 @@ func (s Service) getLobbyByPinHandler(w http.ResponseWriter, r *http.Request) {
 	pin := r.PathValue("pin")
 
 	lobby, err := s.lRepo.GetLobby(pin)
-	if err != nil {
-		switch err.(type) {
-		case errLobbyNotFound:
+	switch err.(type) {
+	case nil:
+		break
+	case errLobbyNotFound:
+		if !s.isRemoteLobby(pin) {
 			common.ErrorHandler(w, r, http.StatusNotFound)
 			return
-		default:
-			common.ErrorHandler(w, r, http.StatusInternalServerError)
-			return
 		}
+		// The page only needs the pin, the views come through the websocket
+		lobby = &Lobby{Pin: pin}
+	default:
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
 	}
 
-	if err := LobbyTmpl.Execute(w, &lobby); err != nil {
+	data := LobbyData{
+		Lobby:    lobby,
+		Spectate: r.URL.Query().Get("spectate") != "",
+	}
+	if err := LobbyTmpl.Execute(w, data); err != nil {
 		slog.Error("Error rendering template", "err", err)
 	}
 }
@@ -95,13 +112,16 @@ func (s Service) getLobbyByPinWsHandler(w http.ResponseWriter, r *http.Request)
 	pin := r.PathValue("pin")
 
 	lobby, err := s.lRepo.GetLobby(pin)
+	remote := false
 	switch err.(type) {
 	case nil:
 		break
 	case errLobbyNotFound:
-		slog.Error("Error trying to connect to not existing lobby", "err", err)
-		common.ErrorHandler(w, r, http.StatusNotFound)
-		return
+		if remote = s.isRemoteLobby(pin); !remote {
+			slog.Error("Error trying to connect to not existing lobby", "err", err)
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		}
 	default:
 		slog.Error("Error getting lobby", "err", err)
 		common.ErrorHandler(w, r, http.StatusInternalServerError)
@@ -118,6 +138,7 @@ func (s Service) getLobbyByPinWsHandler(w http.ResponseWriter, r *http.Request)
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 4 * 1024,
+		Subprotocols:    wsSubprotocols,
 	}
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -125,12 +146,18 @@ func (s Service) getLobbyByPinWsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	spectate := r.URL.Query().Get("spectate") != ""
+	if remote {
+		slog.Debug("Proxying new ws connection", "clientID", clientID, "Lobby-Pin", pin)
+		s.proxyWebsocket(ws, pin, clientID, spectate)
+		return
+	}
+
 	slog.Debug("Handling new ws connection", "clientID", clientID, "Lobby-Pin", lobby.Pin)
 	lobby.mu.Lock()
-	user, err := handleNewWebsocketConn(lobby, ws, clientID)
+	user, err := s.connectClient(lobby, ws, clientID, spectate)
 	lobby.mu.Unlock()
 	if err != nil {
-		slog.Error("Error handling user connection", "err", err)
 		return
 	}
 
@@ -146,6 +173,9 @@ func (s Service) getLobbyByPinWsHandler(w http.ResponseWriter, r *http.Request)
 			} else {
 				slog.Error("Unexpected error while reading ws message, disconnecting", "err", err)
 			}
+			lobby.mu.Lock()
+			s.disconnectClient(lobby, user, ws)
+			lobby.mu.Unlock()
 			break
 		}
 		if messageType != websocket.TextMessage {
@@ -153,18 +183,8 @@ func (s Service) getLobbyByPinWsHandler(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 
-		event, err := parseLobbyEvent(message)
-		if err != nil {
-			slog.Warn("Error parsing lobby event, skipping", "err", err, "message", message)
-			continue
-		}
-
-		slog.Info("Handling lobby event", "event", event.String(), "initiator", user)
-
 		lobby.mu.Lock()
-		if err := event.Handle(s, lobby, user); err != nil {
-			slog.Error("Error handling lobby event", "event", event.String(), "err", err)
-		}
+		s.handleClientMessage(lobby, user, message)
 		lobby.mu.Unlock()
 	}
 }
@@ -180,6 +200,9 @@ func (s Service) getLobbyJoinHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_, err := s.lRepo.GetLobby(pin)
+	if _, ok := err.(errLobbyNotFound); ok && s.isRemoteLobby(pin) {
+		err = nil
+	}
 	switch err.(type) {
 	case nil:
 		// Do nothing
@@ -200,7 +223,11 @@ func (s Service) getLobbyJoinHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Maximum number of quizzes offered in the quiz picker of the lobby settings
+const quizPickerSize = 50
+
 // Handler used for getting/updating the lobby settings from the waiting room
+// In a cluster the request is proxied to the instance running the lobby
 func (s Service) lobbySettingsHandler(w http.ResponseWriter, r *http.Request) {
 	pin := r.PathValue("pin")
 
@@ -208,7 +235,9 @@ func (s Service) lobbySettingsHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err.(type) {
 		case errLobbyNotFound:
-			common.ErrorHandler(w, r, http.StatusNotFound)
+			if !s.proxyRequest(w, r, pin) {
+				common.ErrorHandler(w, r, http.StatusNotFound)
+			}
 			return
 		default:
 			common.ErrorHandler(w, r, http.StatusInternalServerError)
@@ -223,7 +252,7 @@ func (s Service) lobbySettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if lobby.Host == nil || lobby.Host.ClientID != clientID {
+	if !lobby.IsHost(clientID) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -255,6 +284,70 @@ func (s Service) lobbySettingsHandler(w http.ResponseWriter, r *http.Request) {
 			slog.Debug("Updated time-for-reading", "lobby.Pin", lobby.Pin, "timeForReading", timeForReading.String())
 		}
 
+		questionPoolStr := r.FormValue("question-pool")
+		if questionPoolStr != "" {
+			questionPool, err := strconv.Atoi(questionPoolStr)
+			if err != nil {
+				slog.Error("Error parsing question-pool", "err", err)
+				common.ErrorHandler(w, r, http.StatusBadRequest)
+				return
+			}
+			settings.QuestionPoolSize = questionPool
+			slog.Debug("Updated question-pool", "lobby.Pin", lobby.Pin, "questionPool", questionPool)
+		}
+
+		autoAdvanceStr := r.FormValue("auto-advance")
+		if autoAdvanceStr != "" {
+			autoAdvance, err := time.ParseDuration(autoAdvanceStr + "s")
+			if err != nil {
+				slog.Error("Error parsing auto-advance", "err", err)
+				common.ErrorHandler(w, r, http.StatusBadRequest)
+				return
+			}
+			settings.AutoAdvance = autoAdvance
+			slog.Debug("Updated auto-advance", "lobby.Pin", lobby.Pin, "autoAdvance", autoAdvance.String())
+		}
+
+		removeAwayAfterStr := r.FormValue("remove-away-after")
+		if removeAwayAfterStr != "" {
+			removeAwayAfter, err := time.ParseDuration(removeAwayAfterStr + "s")
+			if err != nil {
+				slog.Error("Error parsing remove-away-after", "err", err)
+				common.ErrorHandler(w, r, http.StatusBadRequest)
+				return
+			}
+			settings.RemoveAwayAfter = removeAwayAfter
+			slog.Debug("Updated remove-away-after", "lobby.Pin", lobby.Pin, "removeAwayAfter", removeAwayAfter.String())
+		}
+
+		hostGracePeriodStr := r.FormValue("host-grace-period")
+		if hostGracePeriodStr != "" {
+			hostGracePeriod, err := time.ParseDuration(hostGracePeriodStr + "s")
+			if err != nil {
+				slog.Error("Error parsing host-grace-period", "err", err)
+				common.ErrorHandler(w, r, http.StatusBadRequest)
+				return
+			}
+			settings.HostGracePeriod = hostGracePeriod
+			slog.Debug("Updated host-grace-period", "lobby.Pin", lobby.Pin, "hostGracePeriod", hostGracePeriod.String())
+		}
+
+		// The settings form is always sent as a whole, an unchecked checkbox is not sent at all
+		settings.ShuffleQuestions = r.FormValue("shuffle-questions") != ""
+		settings.AllowLateJoin = r.FormValue("allow-late-join") != ""
+
+		answerShuffleStr := r.FormValue("answer-shuffle")
+		if answerShuffleStr != "" {
+			answerShuffle, err := strconv.Atoi(answerShuffleStr)
+			if err != nil {
+				slog.Error("Error parsing answer-shuffle", "err", err)
+				common.ErrorHandler(w, r, http.StatusBadRequest)
+				return
+			}
+			settings.AnswerShuffle = game.AnswerShuffle(answerShuffle)
+			slog.Debug("Updated answer-shuffle", "lobby.Pin", lobby.Pin, "answerShuffle", answerShuffle)
+		}
+
 		quizIDStr := r.FormValue("quiz")
 		if quizIDStr != "" {
 			quizID, err := strconv.Atoi(quizIDStr)
@@ -279,16 +372,37 @@ func (s Service) lobbySettingsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	quizzesMeta, err := s.qRepo.GetAllQuizzesMetadata()
+	query := quiz.QuizQuery{
+		Text:    r.FormValue("quiz-search"),
+		Sort:    quiz.QuizSort(r.FormValue("quiz-sort")),
+		PerPage: quizPickerSize,
+	}
+	quizzesPage, err := s.qRepo.SearchQuizzes(query)
 	if err != nil {
-		slog.Error("Error getting quizzes metadata", "err", err)
+		slog.Error("Error searching quizzes", "err", err)
 		common.ErrorHandler(w, r, http.StatusInternalServerError)
 		return
 	}
 
+	// The selected quiz always needs to be an option, otherwise the next change
+	// of the settings would silently select a different quiz
+	quizzesMeta := quizzesPage.Quizzes
+	if selected, ok := lobby.Quiz().(quiz.Quiz); ok && selected.ID != 0 {
+		found := false
+		for _, meta := range quizzesMeta {
+			found = found || int64(meta.ID) == selected.ID
+		}
+		if !found {
+			quizzesMeta = append([]quiz.QuizMetadata{{ID: uint(selected.ID), Title: selected.TitleField}}, quizzesMeta...)
+		}
+	}
+
 	err = LobbySettingsTmpl.Execute(w, LobbySettingsData{
-		Quizzes: quizzesMeta,
-		Lobby:   lobby,
+		Quizzes:     quizzesMeta,
+		QuizQuery:   quizzesPage.Query,
+		QuizzesLeft: quizzesPage.Total - len(quizzesPage.Quizzes),
+		Sorts:       quiz.QuizSorts,
+		Lobby:       lobby,
 	})
 	if err != nil {
 		slog.Error("Error rendering template", "err", err)
@@ -1,20 +1,41 @@
 package lobbies
 
 import (
+	"sync/atomic"
+
 	"github.com/erykksc/kwikquiz/internal/pastgames"
 	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/erykksc/kwikquiz/internal/webhooks"
 )
 
 type Service struct {
 	lRepo  Repository           // Lobby Repository
 	pgRepo pastgames.Repository // PastGames Repository
 	qRepo  quiz.Repository      // Quizzes Repository
+	// Set once the server is shutting down, no new lobbies are created then
+	// It is a pointer, so all copies of the service share it
+	draining *atomic.Bool
+	cluster  Cluster // Instances sharing the lobbies
+	// Notified about the lifecycle of the games, nil disables the webhooks
+	webhooks *webhooks.Dispatcher
 }
 
 func NewService(lobbyRepo Repository, pastGamesRepo pastgames.Repository, quizRepo quiz.Repository) Service {
+	return NewClusteredService(lobbyRepo, pastGamesRepo, quizRepo, NewLocalCluster())
+}
+
+// NewClusteredService returns a service sharing its lobbies with the other instances of the cluster
+func NewClusteredService(lobbyRepo Repository, pastGamesRepo pastgames.Repository, quizRepo quiz.Repository, cluster Cluster) Service {
 	return Service{
-		lRepo:  lobbyRepo,
-		pgRepo: pastGamesRepo,
-		qRepo:  quizRepo,
+		lRepo:    lobbyRepo,
+		pgRepo:   pastGamesRepo,
+		qRepo:    quizRepo,
+		draining: &atomic.Bool{},
+		cluster:  cluster,
 	}
 }
+
+// isDraining reports whether the server is shutting down
+func (s Service) isDraining() bool {
+	return s.draining != nil && s.draining.Load()
+}
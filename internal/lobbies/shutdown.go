@@ -0,0 +1,33 @@
+package lobbies
+
+import (
+	"context"
+	"log/slog"
+)
+
+const restartingMessage = "The server is restarting, games in progress were saved to past games"
+
+// Shutdown stops creating new lobbies and closes the running ones
+// Started games are saved to past games and connected clients are told the server is restarting.
+// It returns the context's error if the deadline passes before all lobbies are closed
+func (s Service) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+
+	lobbies, err := s.lRepo.GetAllLobbies()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Closing lobbies for shutdown", "count", len(lobbies))
+	for _, l := range lobbies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Errors are logged by closeLobby, the remaining lobbies are still closed
+		l.mu.Lock()
+		_, _, _ = s.closeLobby(l, restartingMessage)
+		l.mu.Unlock()
+	}
+	return nil
+}
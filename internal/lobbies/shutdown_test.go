@@ -0,0 +1,37 @@
+package lobbies
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShutdown(t *testing.T) {
+	s, pgRepo := newTestService(t)
+	playing := ExampleLobbyOnReadingView()
+	player := &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username, Conn: newTestConn(t)}
+	playing.Users[player.ClientID] = player
+	if err := s.lRepo.AddLobby(playing); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lobbies, _ := s.lRepo.GetAllLobbies(); len(lobbies) != 0 {
+		t.Errorf("Expected all lobbies to be closed, got %d", len(lobbies))
+	}
+	if player.IsConnected() {
+		t.Error("Expected the player's connection to be closed")
+	}
+	if pastGames, _ := pgRepo.GetAll(); len(pastGames) != 1 {
+		t.Errorf("Expected the running game to be saved, got %d past games", len(pastGames))
+	}
+
+	w := httptest.NewRecorder()
+	s.NewLobbiesRouter().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/lobbies/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected new lobbies to be refused with %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package lobbies
+
+import (
+	"log/slog"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+)
+
+// maxSpectators is the number of spectators a single lobby accepts
+const maxSpectators = 10
+
+const tooManySpectatorsMessage = "This lobby already has the maximum number of spectators"
+
+type errTooManySpectators struct{}
+
+func (e errTooManySpectators) Error() string {
+	return "lobby has the maximum number of spectators"
+}
+
+// IsMember reports whether the client is the host or a player of the lobby
+func (l *Lobby) IsMember(clientID common.ClientID) bool {
+	if l.Host != nil && l.Host.ClientID == clientID {
+		return true
+	}
+	_, ok := l.Users[clientID]
+	return ok
+}
+
+// handleNewSpectatorConn handles a new websocket connection of a spectator
+// Spectators only receive the views, for example on a projector, and never take part in the game
+func handleNewSpectatorConn(l *Lobby, conn Conn, clientID common.ClientID) (*User, error) {
+	spectator := &User{
+		Conn:      conn,
+		ClientID:  clientID,
+		Spectator: true,
+	}
+
+	if l.IsBanned(clientID) {
+		slog.Info("Banned client tried to spectate", "Lobby-Pin", l.Pin, "Client-ID", clientID)
+		_ = spectator.writeTemplate(ConnectionClosedView, kickedMessage)
+		return nil, errPlayerBanned{}
+	}
+
+	if existing, ok := l.Spectators[clientID]; ok {
+		slog.Info("Spectator reconnecting", "Lobby-Pin", l.Pin, "Client-ID", clientID)
+		existing.Conn = conn
+		spectator = existing
+	} else {
+		if len(l.Spectators) >= maxSpectators {
+			_ = spectator.writeTemplate(ConnectionClosedView, tooManySpectatorsMessage)
+			return nil, errTooManySpectators{}
+		}
+		slog.Info("New Spectator for Lobby", "Lobby-Pin", l.Pin, "Client-ID", clientID)
+		l.Spectators[clientID] = spectator
+	}
+
+	err := l.sendViewToUser(l.View(), spectator)
+	return spectator, err
+}
+
+// handleSpectatorDisconnect forgets the spectator once their websocket connection closes
+func (l *Lobby) handleSpectatorDisconnect(spectator *User, conn Conn) {
+	if spectator.Conn != conn {
+		return
+	}
+	spectator.Conn = nil
+	if l.Spectators[spectator.ClientID] == spectator {
+		slog.Info("Spectator disconnected", "Lobby-Pin", l.Pin, "Client-ID", spectator.ClientID)
+		delete(l.Spectators, spectator.ClientID)
+	}
+}
@@ -0,0 +1,85 @@
+package lobbies
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+)
+
+func TestSpectatorsAreNotPlayers(t *testing.T) {
+	lobby := ExampleLobbyOnReadingView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	lobby.Users[ExampleUser.ClientID] = &User{ClientID: ExampleUser.ClientID, Username: ExampleUser.Username, Conn: newTestConn(t)}
+
+	spectator, err := handleNewSpectatorConn(lobby, newTestConn(t), "projector")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !spectator.Spectator || lobby.Spectators["projector"] != spectator {
+		t.Error("Expected the client to join as a spectator")
+	}
+	if lobby.IsMember("projector") {
+		t.Error("Expected the spectator not to be a member of the lobby")
+	}
+	if players := lobby.Players(); len(players) != 1 {
+		t.Errorf("Expected 1 player, got %v", players)
+	}
+	if answering := lobby.Round.PlayersAnswering(); answering != 1 {
+		t.Errorf("Expected 1 player answering, got %d", answering)
+	}
+
+	// Reconnecting keeps the same spectator
+	again, err := handleNewSpectatorConn(lobby, newTestConn(t), "projector")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again != spectator || len(lobby.Spectators) != 1 {
+		t.Error("Expected the reconnecting spectator to be reused")
+	}
+
+	lobby.handleSpectatorDisconnect(spectator, spectator.Conn)
+	if len(lobby.Spectators) != 0 {
+		t.Error("Expected the disconnected spectator to be forgotten")
+	}
+}
+
+func TestSpectatorsCap(t *testing.T) {
+	lobby := Example1234Lobby()
+	for i := range maxSpectators {
+		clientID := common.ClientID(fmt.Sprintf("spectator-%d", i))
+		if _, err := handleNewSpectatorConn(lobby, newTestConn(t), clientID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if _, err := handleNewSpectatorConn(lobby, newTestConn(t), "one-too-many"); err != (errTooManySpectators{}) {
+		t.Errorf("Expected errTooManySpectators, got %v", err)
+	}
+}
+
+func TestSpectatorViews(t *testing.T) {
+	lobby := ExampleLobbyOnAnswerView()
+	lobby.Host = &User{ClientID: "host", Username: "HOST"}
+	spectator := &User{ClientID: "projector", Spectator: true}
+
+	var out strings.Builder
+	if err := AnswerView.Execute(&out, ViewData{Lobby: lobby, User: spectator}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Leaderboard") {
+		t.Error("Expected the spectator to see the leaderboard")
+	}
+	if strings.Contains(out.String(), "ws-send") {
+		t.Error("Expected the spectator not to get any controls")
+	}
+
+	out.Reset()
+	if err := QuestionView.Execute(&out, ViewData{Lobby: ExampleLobbyOnReadingView(), User: spectator}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "ws-send") || strings.Contains(out.String(), "Your Score") {
+		t.Error("Expected the spectator to see the question read-only")
+	}
+}
@@ -0,0 +1,245 @@
+package lobbies
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/common"
+)
+
+// Server-Sent Events are the fallback for networks blocking websockets
+// Views are streamed from /lobbies/{pin}/sse and the events the websocket would carry are posted to /lobbies/{pin}/events.
+// In a cluster both are proxied to the instance running the lobby, like the websocket.
+
+// Interval of the comments keeping idle streams open through proxies
+const sseKeepAlive = 15 * time.Second
+
+// Number of views buffered for a stream before the lobby waits for the client
+const sseBuffer = 64
+
+// Maximum size of a posted event, the largest are the forms with a username
+const maxEventSize = 4 * 1024
+
+// sseConn is the connection to a client receiving views through Server-Sent Events
+type sseConn struct {
+	views     chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newSSEConn() *sseConn {
+	return &sseConn{
+		views:  make(chan []byte, sseBuffer),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *sseConn) NextWriter(_ int) (io.WriteCloser, error) {
+	select {
+	case <-c.closed:
+		return nil, errors.New("stream closed")
+	default:
+		return &sseWriter{conn: c}, nil
+	}
+}
+
+func (c *sseConn) Subprotocol() string {
+	return ""
+}
+
+func (c *sseConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// sseWriter queues the written view for the stream once it is closed
+type sseWriter struct {
+	bytes.Buffer
+	conn *sseConn
+}
+
+func (w *sseWriter) Close() error {
+	select {
+	case w.conn.views <- w.Bytes():
+		return nil
+	case <-w.conn.closed:
+		return errors.New("stream closed")
+	}
+}
+
+// writeSSE writes a single event of the stream, every line of the data is sent as a data field
+func writeSSE(w io.Writer, event string, data []byte) error {
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// getLobbySSEHandler handles requests to /lobbies/{pin}/sse
+// It streams the views to the client until either side closes the stream
+func (s Service) getLobbySSEHandler(w http.ResponseWriter, r *http.Request) {
+	pin := r.PathValue("pin")
+
+	var remote bool
+	lobby, err := s.lRepo.GetLobby(pin)
+	switch err.(type) {
+	case nil:
+		break
+	case errLobbyNotFound:
+		if remote = s.isRemoteLobby(pin); !remote {
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		}
+	default:
+		slog.Error("Error getting lobby", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		slog.Error("Streaming is not supported by the response writer")
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	conn := newSSEConn()
+	// Unblocks views written to a stream nobody reads anymore
+	defer conn.Close()
+	spectate := r.URL.Query().Get("spectate") != ""
+	if remote {
+		slog.Debug("Proxying new sse stream", "clientID", clientID, "Lobby-Pin", pin)
+		disconnect := s.proxySSE(conn, pin, clientID, spectate)
+		defer disconnect()
+	} else {
+		slog.Debug("Handling new sse stream", "clientID", clientID, "Lobby-Pin", lobby.Pin)
+		lobby.mu.Lock()
+		user, err := s.connectClient(lobby, conn, clientID, spectate)
+		if err == nil {
+			lobby.sseClients[clientID] = user
+		}
+		lobby.mu.Unlock()
+
+		defer func() {
+			if user == nil {
+				return
+			}
+			lobby.mu.Lock()
+			if lobby.sseClients[clientID] == user {
+				delete(lobby.sseClients, clientID)
+			}
+			s.disconnectClient(lobby, user, conn)
+			lobby.mu.Unlock()
+		}()
+	}
+
+	streamSSE(w, r, flusher, conn, clientID)
+}
+
+// streamSSE writes the views queued on the connection to the stream until either side closes it
+func streamSSE(w http.ResponseWriter, r *http.Request, flusher http.Flusher, conn *sseConn, clientID common.ClientID) {
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case view := <-conn.views:
+			if err := writeSSE(w, "", view); err != nil {
+				slog.Info("Client disconnected from sse stream", "clientID", clientID, "err", err)
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-conn.closed:
+			// The views written before closing, like the reason for closing, are sent first
+		drain:
+			for {
+				select {
+				case view := <-conn.views:
+					_ = writeSSE(w, "", view)
+				default:
+					break drain
+				}
+			}
+			// Tells the client not to reconnect
+			_ = writeSSE(w, "close", []byte("closed"))
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			slog.Info("Client disconnected from sse stream", "clientID", clientID)
+			return
+		}
+	}
+}
+
+// postLobbyEventHandler handles requests to /lobbies/{pin}/events
+// The body is the event the websocket would carry, it is handled for the client's stream
+func (s Service) postLobbyEventHandler(w http.ResponseWriter, r *http.Request) {
+	pin := r.PathValue("pin")
+
+	lobby, err := s.lRepo.GetLobby(pin)
+	switch err.(type) {
+	case nil:
+		break
+	case errLobbyNotFound:
+		if !s.proxyRequest(w, r, pin) {
+			common.ErrorHandler(w, r, http.StatusNotFound)
+		}
+		return
+	default:
+		slog.Error("Error getting lobby", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	clientID, err := common.EnsureClientID(w, r)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	message, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+	if err != nil {
+		slog.Warn("Error reading posted event", "err", err)
+		common.ErrorHandler(w, r, http.StatusBadRequest)
+		return
+	}
+
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	user, ok := lobby.sseClients[clientID]
+	if !ok || !user.IsConnected() {
+		http.Error(w, "Open the lobby's event stream first", http.StatusConflict)
+		return
+	}
+
+	s.handleClientMessage(lobby, user, message)
+	w.WriteHeader(http.StatusNoContent)
+}
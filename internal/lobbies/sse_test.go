@@ -0,0 +1,146 @@
+package lobbies
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseClient is a client of the lobby without websockets
+type sseClient struct {
+	http   *http.Client
+	events *bufio.Reader
+}
+
+// connectSSE opens the lobby's event stream for a new client
+func connectSSE(t *testing.T, server *httptest.Server, pin string) *sseClient {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := &http.Client{Jar: jar, Timeout: 5 * time.Second}
+
+	resp, err := client.Get(server.URL + "/lobbies/" + pin + "/sse")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	return &sseClient{http: client, events: bufio.NewReader(resp.Body)}
+}
+
+// next returns the type and data of the next event of the stream, skipping comments
+func (c *sseClient) next(t *testing.T) (string, string) {
+	t.Helper()
+	var event string
+	var data []string
+	for {
+		line, err := c.events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != nil:
+			return event, strings.Join(data, "\n")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func (c *sseClient) post(t *testing.T, server *httptest.Server, pin string, event string) int {
+	t.Helper()
+	resp, err := c.http.Post(server.URL+"/lobbies/"+pin+"/events", "application/json", strings.NewReader(event))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSSELobby(t *testing.T) {
+	s := NewService(NewRepositoryInMemory(), nil, nil)
+	lobby := Example1234Lobby()
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := httptest.NewServer(s.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	host := connectSSE(t, server, lobby.Pin)
+	if _, view := host.next(t); !strings.Contains(view, `id="view"`) {
+		t.Errorf("Expected the host to get a view, got %q", view)
+	}
+
+	player := connectSSE(t, server, lobby.Pin)
+	if _, view := player.next(t); !strings.Contains(view, "new-username-form") {
+		t.Errorf("Expected the player to choose a username, got %q", view)
+	}
+
+	event := `{"Username": "sse", "HEADERS": {"HX-Trigger-Name": "new-username-form"}}`
+	if status := player.post(t, server, lobby.Pin, event); status != http.StatusNoContent {
+		t.Fatalf("Expected the event to be accepted, got status %d", status)
+	}
+	if _, view := player.next(t); !strings.Contains(view, "sse") {
+		t.Errorf("Expected the waiting room of the player, got %q", view)
+	}
+	if _, view := host.next(t); !strings.Contains(view, "sse") {
+		t.Errorf("Expected the host to see the new player, got %q", view)
+	}
+
+	lobby.mu.Lock()
+	joined := lobby.HasUser("sse")
+	closed := lobby.closeConnections("Closed for the test")
+	lobby.mu.Unlock()
+	if !joined {
+		t.Error("Expected the player to join through posted events")
+	}
+	if closed != 2 {
+		t.Errorf("Expected 2 closed connections, got %d", closed)
+	}
+
+	if _, view := player.next(t); !strings.Contains(view, "Closed for the test") {
+		t.Errorf("Expected the reason for closing, got %q", view)
+	}
+	if event, _ := player.next(t); event != "close" {
+		t.Errorf("Expected the close event, got %q", event)
+	}
+}
+
+func TestPostEventWithoutStream(t *testing.T) {
+	s := NewService(NewRepositoryInMemory(), nil, nil)
+	lobby := Example1234Lobby()
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := httptest.NewServer(s.NewLobbiesRouter())
+	t.Cleanup(server.Close)
+
+	resp, err := http.Post(server.URL+"/lobbies/"+lobby.Pin+"/events", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/lobbies/0000/events", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
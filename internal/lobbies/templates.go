@@ -12,6 +12,11 @@ var LobbiesTmpl = common.TmplParseWithBase("templates/lobbies/lobbies.html")
 var LobbyTmpl = common.TmplParseWithBase("templates/lobbies/lobby.html")
 var LobbyErrorAlertTmpl = LobbyTmpl.Lookup("error-alert")
 
+type LobbyData struct {
+	*Lobby
+	Spectate bool // Connect as a spectator instead of joining the game
+}
+
 type ViewData struct {
 	Lobby *Lobby
 	User  *User
@@ -25,6 +30,10 @@ var QuestionView = common.ParseTmplWithFuncs("templates/views/question-view.html
 var AnswerView = common.ParseTmplWithFuncs("templates/views/answer-view.html")
 var onFinishView = common.TmplParseWithBase("templates/views/on-finish-view.html")
 
+// ConnectionClosedView replaces the websocket connection of a client the lobby refused, so it stops reconnecting
+// It requires the message shown to the client as the data to render
+var ConnectionClosedView = common.ParseTmplWithFuncs("templates/views/connection-closed-view.html")
+
 type OnFinishData struct {
 	PastGameID int64
 	ViewData
@@ -34,8 +43,11 @@ type OnFinishData struct {
 var LobbySettingsTmpl = WaitingRoomView.Lookup("lobby-settings")
 
 type LobbySettingsData struct {
-	Quizzes []quiz.QuizMetadata
-	Lobby   *Lobby
+	Quizzes     []quiz.QuizMetadata
+	QuizQuery   quiz.QuizQuery // Search used for the quiz picker
+	QuizzesLeft int            // Number of matching quizzes not offered in the picker
+	Sorts       []quiz.QuizSort
+	Lobby       *Lobby
 }
 
 type LobbyState int
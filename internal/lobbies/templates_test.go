@@ -2,7 +2,12 @@ package lobbies
 
 import (
 	"io"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
 )
 
 func TestChooseUsernameView(t *testing.T) {
@@ -29,6 +34,22 @@ func TestWaitingRoomView(t *testing.T) {
 	}
 }
 
+func TestLobbySettingsTmpl(t *testing.T) {
+	lobby := Example1234Lobby()
+	settings := lobby.Settings()
+	settings.ShuffleQuestions = true
+	settings.QuestionPoolSize = 1
+	settings.AnswerShuffle = game.AnswerShufflePerPlayer
+	if err := lobby.UpdateSettings(settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := LobbySettingsTmpl.Execute(io.Discard, LobbySettingsData{Lobby: lobby})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
 func TestQuestionView(t *testing.T) {
 	data := ViewData{
 		Lobby: ExampleLobbyOnReadingView(),
@@ -67,6 +88,31 @@ func TestAnswerViewHost(t *testing.T) {
 	}
 }
 
+func TestAnswerViewAutoAdvance(t *testing.T) {
+	options := NewLobbyOptions()
+	options.Quiz = quiz.ExampleQuizGeography
+	options.AutoAdvance = 10 * time.Second
+	lobby := createLobby(options)
+	if err := lobby.AddPlayer(ExampleUser.Username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := lobby.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := lobby.FinishRoundEarly(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out strings.Builder
+	err := AnswerView.Execute(&out, ViewData{Lobby: lobby, User: &ExampleUser})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Next question starts") {
+		t.Errorf("Expected the auto advance countdown to be shown")
+	}
+}
+
 func TestOnFinishView(t *testing.T) {
 	lobby := ExampleLobbyOnAnswerView()
 	if err := lobby.Finish(); err != nil {
@@ -3,17 +3,30 @@ package lobbies
 import (
 	"errors"
 	"html/template"
+	"io"
 	"log/slog"
+	"time"
 
 	"github.com/erykksc/kwikquiz/internal/common"
 	"github.com/erykksc/kwikquiz/internal/game"
 	"github.com/gorilla/websocket"
 )
 
+// Conn is the connection views are written to
+// It is either a websocket or a connection proxied from the instance the client is connected to
+type Conn interface {
+	NextWriter(messageType int) (io.WriteCloser, error)
+	Close() error
+	// Subprotocol returns the negotiated protocol, see JSONProtocol
+	Subprotocol() string
+}
+
 type User struct {
-	Conn     *websocket.Conn
-	ClientID common.ClientID
-	Username game.Username
+	Conn           Conn // nil while the user is disconnected
+	ClientID       common.ClientID
+	Username       game.Username
+	DisconnectedAt time.Time // Zero while the user is connected
+	Spectator      bool      // Spectators only watch the game, they are neither the host nor players
 }
 
 // writeTemplate does tmpl.Execute(w, data) on websocket connection to the user
@@ -22,6 +35,17 @@ func (client *User) writeTemplate(tmpl *template.Template, data any) error {
 	if client.Conn == nil {
 		return errors.New("client.Conn is nil")
 	}
+	if client.usesJSON() {
+		// The error of the refused event is reported instead of the alert, see reportError
+		if tmpl == LobbyErrorAlertTmpl {
+			return nil
+		}
+		msg, err := jsonMessageFor(tmpl, data)
+		if err != nil {
+			return err
+		}
+		return client.writeJSON(msg)
+	}
 	w, err := client.Conn.NextWriter(websocket.TextMessage)
 	if err != nil {
 		return err
@@ -35,10 +59,14 @@ func (client *User) writeTemplate(tmpl *template.Template, data any) error {
 }
 
 // writeNamedTemplate does tmpl.ExecuteTemplate(w, name, data) on websocket connection to the user
+// Clients of the JSON protocol get the whole view instead of its part
 func (client *User) writeNamedTemplate(tmpl *template.Template, name string, data any) error {
 	if client.Conn == nil {
 		return errors.New("client.Conn is nil")
 	}
+	if client.usesJSON() {
+		return client.writeTemplate(tmpl, data)
+	}
 	w, err := client.Conn.NextWriter(websocket.TextMessage)
 	if err != nil {
 		return err
@@ -50,3 +78,14 @@ func (client *User) writeNamedTemplate(tmpl *template.Template, name string, dat
 	}
 	return nil
 }
+
+// closeWithMessage shows the message in place of the lobby and closes the websocket connection
+// The connection is cleared first, so closing it isn't handled as a disconnection
+func (client *User) closeWithMessage(message string) {
+	if err := client.writeTemplate(ConnectionClosedView, message); err != nil {
+		slog.Error("Error sending closed view", "client-id", client.ClientID, "err", err)
+	}
+	conn := client.Conn
+	client.Conn = nil
+	conn.Close()
+}
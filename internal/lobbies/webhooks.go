@@ -0,0 +1,72 @@
+package lobbies
+
+import (
+	"slices"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/erykksc/kwikquiz/internal/webhooks"
+)
+
+// WithWebhooks returns the service notifying the dispatcher about the lifecycle of its games
+func (s Service) WithWebhooks(d *webhooks.Dispatcher) Service {
+	s.webhooks = d
+	return s
+}
+
+// notify fires the webhooks of the lobby's event, it doesn't block
+// It is executed with the lobby's mutex locked
+func (s Service) notify(l *Lobby, event string) {
+	s.webhooks.Notify(newWebhookPayload(l, event))
+}
+
+// notifyRoundFinished fires the webhooks of a finished round, with the points of the round next to the scores
+func (s Service) notifyRoundFinished(l *Lobby) {
+	payload := newWebhookPayload(l, webhooks.EventRoundFinished)
+	round := l.RoundNum()
+	payload.Round = &round
+
+	if points, err := l.LastRoundPoints(); err == nil {
+		for i, score := range payload.Scores {
+			if roundPoints, ok := points[game.Username(score.Username)]; ok {
+				payload.Scores[i].RoundPoints = &roundPoints
+			}
+		}
+	}
+	s.webhooks.Notify(payload)
+}
+
+// notifyGameEnded fires the webhooks of a finished game stored in past games
+func (s Service) notifyGameEnded(l *Lobby, pastGameID int64) {
+	payload := newWebhookPayload(l, webhooks.EventGameEnded)
+	payload.PastGameID = pastGameID
+	s.webhooks.Notify(payload)
+}
+
+func newWebhookPayload(l *Lobby, event string) webhooks.Payload {
+	payload := webhooks.Payload{
+		Event:     event,
+		LobbyPin:  l.Pin,
+		Questions: l.QuestionsCount(),
+	}
+	if q := l.Quiz(); q != nil {
+		payload.QuizTitle = q.Title()
+	}
+	if q, ok := l.Quiz().(quiz.Quiz); ok {
+		payload.QuizID = q.ID
+		payload.QuizRevision = q.Revision
+	}
+
+	if l.HasStarted() {
+		for _, score := range l.Leaderboard() {
+			payload.Scores = append(payload.Scores, webhooks.Score{
+				Username: string(score.Username),
+				Points:   score.Points,
+			})
+		}
+		slices.SortStableFunc(payload.Scores, func(a, b webhooks.Score) int {
+			return b.Points - a.Points
+		})
+	}
+	return payload
+}
@@ -0,0 +1,78 @@
+package lobbies
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erykksc/kwikquiz/internal/webhooks"
+)
+
+func TestWebhooksOnGameLifecycle(t *testing.T) {
+	receiver := webhooks.NewTestReceiver("secret")
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	cfg := webhooks.DefaultConfig()
+	cfg.Endpoints = []string{server.URL}
+	cfg.Secret = "secret"
+	dispatcher := webhooks.NewDispatcher(cfg)
+	dispatcher.Start()
+
+	s, _ := newTestService(t)
+	s = s.WithWebhooks(dispatcher)
+	lobby := Example1234Lobby()
+	if err := s.AddLobby(lobby); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	host := &User{ClientID: "host", Username: "HOST"}
+	lobby.Host = host
+	player := &User{ClientID: ExampleUser.ClientID}
+
+	steps := []struct {
+		event     lobbyEvent
+		initiator *User
+	}{
+		{leNewUsernameSubmitted{Username: ExampleUser.Username}, player},
+		{leGameStartRequested{}, host},
+		{leSkipToAnswerRequested{}, host},
+	}
+	for _, step := range steps {
+		if err := handleTestEvent(s, lobby, step.event, step.initiator); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	waitForEvent(t, lobby, leShowAnswerRequested{}, 1)
+	if err := handleTestEvent(s, lobby, leEndGameRequested{}, host); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	received := make(map[string]webhooks.Payload)
+	for _, payload := range receiver.Received() {
+		received[payload.Event] = payload
+	}
+	for _, event := range []string{webhooks.EventLobbyCreated, webhooks.EventGameStarted, webhooks.EventRoundFinished, webhooks.EventGameEnded} {
+		payload, ok := received[event]
+		if !ok {
+			t.Errorf("Expected the %s webhook", event)
+			continue
+		}
+		if payload.LobbyPin != lobby.Pin {
+			t.Errorf("Expected the %s webhook of lobby %s, got %s", event, lobby.Pin, payload.LobbyPin)
+		}
+	}
+
+	if round := received[webhooks.EventRoundFinished]; round.Round == nil || *round.Round != 0 || len(round.Scores) != 1 || round.Scores[0].RoundPoints == nil {
+		t.Errorf("Expected the points of the first round, got %+v", round)
+	}
+	if ended := received[webhooks.EventGameEnded]; ended.PastGameID == 0 || len(ended.Scores) != 1 || ended.Scores[0].Username != string(ExampleUser.Username) {
+		t.Errorf("Expected the scores of the stored game, got %+v", ended)
+	}
+}
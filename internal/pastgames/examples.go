@@ -13,6 +13,7 @@ var ExamplePastGame1 = PastGame{
 	StartedAt: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC),
 	EndedAt:   time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC),
 	QuizTitle: "Geography",
+	Questions: 15,
 	Scores: []PlayerScore{
 		{
 			Username: "Alice",
@@ -0,0 +1,194 @@
+package pastgames
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of the past games, besides the HTML pages
+const (
+	formatHTML = "html"
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// negotiateFormat returns the format of the response, the format query parameter takes precedence over the Accept header
+// It returns false if the requested format isn't supported
+func negotiateFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case formatHTML, formatCSV, formatJSON:
+		return format, true
+	default:
+		return "", false
+	}
+
+	// The first supported media type wins, browsers list text/html first
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/html":
+			return formatHTML, true
+		case "text/csv":
+			return formatCSV, true
+		case "application/json":
+			return formatJSON, true
+		}
+	}
+	return formatHTML, true
+}
+
+// ExportedGame is a past game in the JSON exports
+type ExportedGame struct {
+	ID           int64           `json:"id"`
+	QuizTitle    string          `json:"quizTitle"`
+	QuizID       int64           `json:"quizId,omitempty"`
+	QuizRevision int64           `json:"quizRevision,omitempty"`
+	Questions    int             `json:"questions,omitempty"`
+	StartedAt    time.Time       `json:"startedAt"`
+	EndedAt      time.Time       `json:"endedAt"`
+	Scoreboard   []ExportedScore `json:"scoreboard"` // Sorted by rank
+}
+
+type ExportedScore struct {
+	Rank     int    `json:"rank"` // Players with the same score share the rank
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+func newExportedGame(pg PastGame) ExportedGame {
+	exported := ExportedGame{
+		ID:           pg.ID,
+		QuizTitle:    pg.QuizTitle,
+		QuizID:       pg.QuizID,
+		QuizRevision: pg.QuizRevision,
+		Questions:    pg.Questions,
+		StartedAt:    pg.StartedAt,
+		EndedAt:      pg.EndedAt,
+		Scoreboard:   make([]ExportedScore, 0, len(pg.Scores)),
+	}
+	for i, score := range pg.Scores {
+		rank := i + 1
+		if i > 0 && score.Score == pg.Scores[i-1].Score {
+			rank = exported.Scoreboard[i-1].Rank
+		}
+		exported.Scoreboard = append(exported.Scoreboard, ExportedScore{
+			Rank:     rank,
+			Username: score.Username,
+			Score:    score.Score,
+		})
+	}
+	return exported
+}
+
+var exportCSVHeader = []string{"game_id", "quiz_title", "started_at", "ended_at", "rank", "username", "score"}
+
+// exportWriter writes past games one at a time, so the exports of many games are never held in memory
+type exportWriter interface {
+	Write(pg PastGame) error
+	// Close ends the export, it must be called once all games are written
+	Close() error
+}
+
+// newExportWriter returns the writer of the format and sets the headers of the response
+// The list of a single game is written as the game itself in JSON, the CSV has one row per score either way
+func newExportWriter(w http.ResponseWriter, format, filename string, list bool) exportWriter {
+	w.Header().Add("Vary", "Accept")
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		return &csvExportWriter{writer: csv.NewWriter(w)}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		return &jsonExportWriter{w: w, list: list}
+	}
+}
+
+type csvExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvExportWriter) Write(pg PastGame) error {
+	if !e.headerWritten {
+		if err := e.writer.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	game := newExportedGame(pg)
+	record := []string{
+		strconv.FormatInt(game.ID, 10),
+		game.QuizTitle,
+		game.StartedAt.Format(time.RFC3339),
+		game.EndedAt.Format(time.RFC3339),
+		"", "", "",
+	}
+	// Games without players still get a row
+	if len(game.Scoreboard) == 0 {
+		return e.writer.Write(record)
+	}
+	for _, score := range game.Scoreboard {
+		record[4] = strconv.Itoa(score.Rank)
+		record[5] = score.Username
+		record[6] = strconv.Itoa(score.Score)
+		if err := e.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExportWriter) Close() error {
+	if !e.headerWritten {
+		if err := e.writer.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonExportWriter struct {
+	w       io.Writer
+	list    bool // Games are written as elements of an array
+	written int
+}
+
+func (e *jsonExportWriter) Write(pg PastGame) error {
+	separator := ","
+	if e.written == 0 {
+		separator = "["
+	}
+	if e.list {
+		if _, err := io.WriteString(e.w, separator); err != nil {
+			return err
+		}
+	}
+	e.written++
+	return json.NewEncoder(e.w).Encode(newExportedGame(pg))
+}
+
+func (e *jsonExportWriter) Close() error {
+	if !e.list {
+		return nil
+	}
+	end := "]\n"
+	if e.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
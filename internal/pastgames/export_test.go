@@ -0,0 +1,110 @@
+package pastgames
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		url    string
+		accept string
+		format string
+		ok     bool
+	}{
+		{"/past-games/", "", formatHTML, true},
+		{"/past-games/", "text/html,application/xhtml+xml,*/*;q=0.8", formatHTML, true},
+		{"/past-games/", "application/json", formatJSON, true},
+		{"/past-games/", "text/csv; charset=utf-8", formatCSV, true},
+		{"/past-games/?format=csv", "application/json", formatCSV, true},
+		{"/past-games/?format=xml", "", "", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		r.Header.Set("Accept", test.accept)
+		format, ok := negotiateFormat(r)
+		if format != test.format || ok != test.ok {
+			t.Errorf("%s with Accept %q: expected %q %v, got %q %v", test.url, test.accept, test.format, test.ok, format, ok)
+		}
+	}
+}
+
+func TestExportPastGames(t *testing.T) {
+	repo, teardown := setup()
+	defer teardown()
+
+	startedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	games := []PastGame{
+		{ID: 1, StartedAt: startedAt, EndedAt: startedAt.Add(time.Minute), QuizTitle: "Capitals", Scores: []PlayerScore{
+			{Username: "alice", Score: 900},
+			{Username: "bob", Score: 500},
+			{Username: "carol", Score: 500},
+		}},
+		{ID: 2, StartedAt: startedAt, EndedAt: startedAt.Add(time.Minute), QuizTitle: "Rivers, Lakes"},
+	}
+	for _, game := range games {
+		if _, err := repo.Upsert(&game); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	router := NewService(repo).NewPastGamesRouter()
+
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", url, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	t.Run("list as csv", func(t *testing.T) {
+		w := get("/past-games/?format=csv&sort=oldest", "")
+		expected := "game_id,quiz_title,started_at,ended_at,rank,username,score\n" +
+			"1,Capitals,2024-06-01T12:00:00Z,2024-06-01T12:01:00Z,1,alice,900\n" +
+			"1,Capitals,2024-06-01T12:00:00Z,2024-06-01T12:01:00Z,2,bob,500\n" +
+			"1,Capitals,2024-06-01T12:00:00Z,2024-06-01T12:01:00Z,2,carol,500\n" +
+			"2,\"Rivers, Lakes\",2024-06-01T12:00:00Z,2024-06-01T12:01:00Z,,,\n"
+		if w.Body.String() != expected {
+			t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, w.Body.String())
+		}
+	})
+
+	t.Run("filtered list as json", func(t *testing.T) {
+		w := get("/past-games/?q=2", "application/json")
+		var exported []ExportedGame
+		if err := json.Unmarshal(w.Body.Bytes(), &exported); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", w.Body.String(), err)
+		}
+		if len(exported) != 1 || exported[0].ID != 2 || len(exported[0].Scoreboard) != 0 {
+			t.Errorf("Expected only game 2, got %+v", exported)
+		}
+	})
+
+	t.Run("empty list as json", func(t *testing.T) {
+		w := get("/past-games/?q=3&format=json", "")
+		if strings.TrimSpace(w.Body.String()) != "[]" {
+			t.Errorf("Expected an empty array, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("game as json", func(t *testing.T) {
+		w := get("/past-games/1", "application/json")
+		var exported ExportedGame
+		if err := json.Unmarshal(w.Body.Bytes(), &exported); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", w.Body.String(), err)
+		}
+		if !exported.StartedAt.Equal(startedAt) || len(exported.Scoreboard) != 3 {
+			t.Fatalf("Unexpected export %+v", exported)
+		}
+		if first := exported.Scoreboard[0]; first.Username != "alice" || first.Rank != 1 {
+			t.Errorf("Expected alice to rank first, got %+v", first)
+		}
+	})
+}
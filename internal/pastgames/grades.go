@@ -0,0 +1,135 @@
+package pastgames
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+// Grade is the score of a player normalised to a percentage of the maximum score of the game
+type Grade struct {
+	Username string
+	RosterID string // Identifier of the player in the gradebook, empty if the player isn't on the roster
+	Score    int
+	MaxScore int
+	Percent  float64 // Rounded to two decimals
+}
+
+// Roster maps usernames to the identifiers of the students in a gradebook, usernames are case-insensitive
+type Roster map[string]string
+
+// ID returns the roster identifier of the username
+func (r Roster) ID(username string) (string, bool) {
+	id, ok := r[normaliseUsername(username)]
+	return id, ok
+}
+
+func normaliseUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+type ErrInvalidRoster struct {
+	Line   int
+	Reason string
+}
+
+func (e ErrInvalidRoster) Error() string {
+	return fmt.Sprintf("invalid roster on line %d: %s", e.Line, e.Reason)
+}
+
+// ParseRoster reads a roster in CSV format, every line is a username and its roster identifier
+// A first line of "username,..." is treated as a header and skipped
+func ParseRoster(r io.Reader) (Roster, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	roster := make(Roster)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return roster, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidRoster{Line: line, Reason: err.Error()}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, ErrInvalidRoster{Line: line, Reason: "expected a username and a roster id"}
+		}
+
+		username, id := normaliseUsername(record[0]), strings.TrimSpace(record[1])
+		if line == 1 && username == "username" {
+			continue
+		}
+		if username == "" || id == "" {
+			return nil, ErrInvalidRoster{Line: line, Reason: "username and roster id can't be empty"}
+		}
+		if _, exists := roster[username]; exists {
+			return nil, ErrInvalidRoster{Line: line, Reason: "duplicate username " + record[0]}
+		}
+		roster[username] = id
+	}
+}
+
+// MaxScore returns the highest score a player could have reached in the game
+// Games stored without the number of questions fall back to the best score of the game
+func (pg PastGame) MaxScore() int {
+	if pg.Questions > 0 {
+		return pg.Questions * game.MaxPointsPerQuestion
+	}
+	best := 0
+	for _, score := range pg.Scores {
+		best = max(best, score.Score)
+	}
+	return best
+}
+
+// Grades returns the grades of all players in the order of the scores, the roster can be nil
+func (pg PastGame) Grades(roster Roster) []Grade {
+	maxScore := pg.MaxScore()
+	grades := make([]Grade, 0, len(pg.Scores))
+	for _, score := range pg.Scores {
+		grade := Grade{
+			Username: score.Username,
+			Score:    score.Score,
+			MaxScore: maxScore,
+		}
+		if maxScore > 0 {
+			grade.Percent = math.Round(float64(score.Score)/float64(maxScore)*10000) / 100
+		}
+		grade.RosterID, _ = roster.ID(score.Username)
+		grades = append(grades, grade)
+	}
+	return grades
+}
+
+// WriteGradebookCSV writes the grades as a CSV gradebook
+func WriteGradebookCSV(w io.Writer, grades []Grade) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"roster_id", "username", "score", "max_score", "percent"}); err != nil {
+		return err
+	}
+	for _, grade := range grades {
+		record := []string{
+			grade.RosterID,
+			grade.Username,
+			strconv.Itoa(grade.Score),
+			strconv.Itoa(grade.MaxScore),
+			strconv.FormatFloat(grade.Percent, 'f', 2, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
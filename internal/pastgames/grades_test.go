@@ -0,0 +1,185 @@
+package pastgames
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var gradedGame = PastGame{
+	ID:        7,
+	EndedAt:   time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	QuizTitle: "Capitals",
+	Questions: 4,
+	Scores: []PlayerScore{
+		{Username: "Alice", Score: 3000},
+		{Username: "bob", Score: 1234},
+		{Username: "guest", Score: 0},
+	},
+}
+
+func TestParseRoster(t *testing.T) {
+	roster, err := ParseRoster(strings.NewReader("username,student id\nalice, s-1\n\nBOB,s-2\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(roster) != 2 {
+		t.Fatalf("Expected 2 students, got %v", roster)
+	}
+	if id, ok := roster.ID(" Bob"); !ok || id != "s-2" {
+		t.Errorf("Expected bob to be s-2, got %q", id)
+	}
+
+	invalid := []string{
+		"alice",
+		"alice,s-1,extra",
+		"alice,",
+		"alice,s-1\nALICE,s-2",
+	}
+	for _, input := range invalid {
+		if _, err := ParseRoster(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for roster %q", input)
+		}
+	}
+}
+
+func TestGrades(t *testing.T) {
+	roster := Roster{"alice": "s-1", "bob": "s-2"}
+	grades := gradedGame.Grades(roster)
+
+	expected := []Grade{
+		{Username: "Alice", RosterID: "s-1", Score: 3000, MaxScore: 4000, Percent: 75},
+		{Username: "bob", RosterID: "s-2", Score: 1234, MaxScore: 4000, Percent: 30.85},
+		{Username: "guest", Score: 0, MaxScore: 4000, Percent: 0},
+	}
+	if len(grades) != len(expected) {
+		t.Fatalf("Expected %d grades, got %d", len(expected), len(grades))
+	}
+	for i := range expected {
+		if grades[i] != expected[i] {
+			t.Errorf("Expected grade %+v, got %+v", expected[i], grades[i])
+		}
+	}
+
+	t.Run("game stored without the number of questions", func(t *testing.T) {
+		game := gradedGame
+		game.Questions = 0
+		if grades := game.Grades(nil); grades[0].Percent != 100 || grades[0].RosterID != "" {
+			t.Errorf("Expected the best score to be 100%%, got %+v", grades[0])
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteGradebookCSV(&buf, grades); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := "roster_id,username,score,max_score,percent\n" +
+			"s-1,Alice,3000,4000,75.00\n" +
+			"s-2,bob,1234,4000,30.85\n" +
+			",guest,0,4000,0.00\n"
+		if buf.String() != expected {
+			t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, buf.String())
+		}
+	})
+}
+
+func TestPassbackGrades(t *testing.T) {
+	stub := NewLTIStub()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	scores, unmapped := gradedGame.LTIScores(Roster{"alice": "s-1", "bob": "s-2"})
+	if len(unmapped) != 1 || unmapped[0] != "guest" {
+		t.Errorf("Expected guest to be unmapped, got %v", unmapped)
+	}
+
+	err := PassbackGrades(context.Background(), http.DefaultClient, server.URL+"/course-1/quiz/", "token", scores)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	received := stub.Scores("/course-1/quiz")
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 scores, got %v", received)
+	}
+	alice := received["s-1"]
+	if alice.ScoreGiven != 75 || alice.ScoreMaximum != 100 || !alice.Timestamp.Equal(gradedGame.EndedAt) || alice.GradingProgress != "FullyGraded" {
+		t.Errorf("Unexpected score of alice: %+v", alice)
+	}
+
+	t.Run("without token", func(t *testing.T) {
+		err := PassbackGrades(context.Background(), http.DefaultClient, server.URL+"/course-1/quiz", "", scores)
+		if err == nil {
+			t.Error("Expected the stub to reject the scores")
+		}
+	})
+}
+
+func TestPassbackHandler(t *testing.T) {
+	repo, teardown := setup()
+	defer teardown()
+	game := gradedGame
+	if _, err := repo.Upsert(&game); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stub := NewLTIStub()
+	platform := httptest.NewServer(stub)
+	t.Cleanup(platform.Close)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL)
+	}))
+	t.Cleanup(other.Close)
+	// The platform redirects this line item to a server that isn't a platform
+	redirecting := httptest.NewServer(http.RedirectHandler(other.URL+"/course-1/quiz/scores", http.StatusTemporaryRedirect))
+	t.Cleanup(redirecting.Close)
+
+	s, err := NewService(repo).WithLTIPlatforms(platform.URL, redirecting.URL+"/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := NewService(repo).WithLTIPlatforms("lms.example.com"); err == nil {
+		t.Error("Expected error for a platform without scheme")
+	}
+	router := s.NewPastGamesRouter()
+
+	passback := func(lineItem string) string {
+		form := url.Values{"roster": {"alice,s-1"}, "line-item": {lineItem}, "token": {"token"}}
+		r := httptest.NewRequest(http.MethodPost, "/past-games/7/passback", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	tests := []struct {
+		lineItem string
+		expected string
+	}{
+		{platform.URL + "/course-1/quiz", "Sent the grades of 1 players"},
+		{other.URL + "/course-1/quiz", "isn&#39;t on a configured LTI platform"},
+		{"http://169.254.169.254/latest/meta-data", "isn&#39;t on a configured LTI platform"},
+		{"file:///etc/passwd", "must be an HTTP URL"},
+		{redirecting.URL + "/course-1/quiz", "rejected the grades with status 307"},
+	}
+	for _, test := range tests {
+		if body := passback(test.lineItem); !strings.Contains(body, test.expected) {
+			t.Errorf("Expected %q for %s, got %s", test.expected, test.lineItem, body)
+		}
+	}
+	if scores := stub.Scores("/course-1/quiz"); len(scores) != 1 {
+		t.Errorf("Expected the score of alice, got %v", scores)
+	}
+
+	// Errors of the connection aren't shown to the client
+	platform.Close()
+	if body := passback(platform.URL + "/course-1/quiz"); !strings.Contains(body, "Passing back the grades failed") || strings.Contains(body, "refused") {
+		t.Errorf("Expected a generic error, got %s", body)
+	}
+}
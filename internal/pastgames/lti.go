@@ -0,0 +1,178 @@
+package pastgames
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LTIScoreContentType is the media type of the scores posted to a line item, as defined by LTI Assignment and Grade Services
+const LTIScoreContentType = "application/vnd.ims.lis.v1.score+json"
+
+// LTIScore is the grade of a student posted to the scores endpoint of an LTI line item
+type LTIScore struct {
+	UserID           string    `json:"userId"`
+	ScoreGiven       float64   `json:"scoreGiven"`
+	ScoreMaximum     float64   `json:"scoreMaximum"`
+	Comment          string    `json:"comment,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+	ActivityProgress string    `json:"activityProgress"`
+	GradingProgress  string    `json:"gradingProgress"`
+}
+
+// LTIScores returns the grades of the players on the roster as LTI scores out of 100
+// The usernames of the players missing from the roster are returned separately
+func (pg PastGame) LTIScores(roster Roster) (scores []LTIScore, unmapped []string) {
+	for _, grade := range pg.Grades(roster) {
+		if grade.RosterID == "" {
+			unmapped = append(unmapped, grade.Username)
+			continue
+		}
+		scores = append(scores, LTIScore{
+			UserID:           grade.RosterID,
+			ScoreGiven:       grade.Percent,
+			ScoreMaximum:     100,
+			Comment:          fmt.Sprintf("%s: %d of %d points as %s", pg.QuizTitle, grade.Score, grade.MaxScore, grade.Username),
+			Timestamp:        pg.EndedAt,
+			ActivityProgress: "Completed",
+			GradingProgress:  "FullyGraded",
+		})
+	}
+	return scores, unmapped
+}
+
+// errInvalidLTIURL is returned for URLs that aren't absolute HTTP URLs
+type errInvalidLTIURL struct{}
+
+func (errInvalidLTIURL) Error() string {
+	return "the URL must be an absolute HTTP URL"
+}
+
+// ScoreRejectedError is returned when the platform doesn't accept a score
+type ScoreRejectedError struct {
+	UserID string
+	Status int
+}
+
+func (e ScoreRejectedError) Error() string {
+	return fmt.Sprintf("platform rejected the score of %s with status %d", e.UserID, e.Status)
+}
+
+// ltiOrigin returns the lowercase scheme://host[:port] of the URL
+func ltiOrigin(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errInvalidLTIURL{}
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// PassbackGrades posts every score to the scores endpoint of the line item, authorised with the bearer token
+// It stops at the first score the platform doesn't accept
+func PassbackGrades(ctx context.Context, client *http.Client, lineItemURL, token string, scores []LTIScore) error {
+	endpoint := strings.TrimSuffix(lineItemURL, "/") + "/scores"
+	for _, score := range scores {
+		body, err := json.Marshal(score)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", LTIScoreContentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return ScoreRejectedError{UserID: score.UserID, Status: resp.StatusCode}
+		}
+	}
+	return nil
+}
+
+// LTIStub is a stand-in for the line items of an LMS, for trying out the grade passback locally
+// Scores are posted to {prefix}{lineItem}/scores and the last score of every user is listed on GET
+type LTIStub struct {
+	mu     sync.Mutex
+	scores map[string]map[string]LTIScore // Line item -> user ID -> score
+}
+
+func NewLTIStub() *LTIStub {
+	return &LTIStub{scores: make(map[string]map[string]LTIScore)}
+}
+
+func (stub *LTIStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lineItem, ok := strings.CutSuffix(r.URL.Path, "/scores")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		stub.receive(w, r, lineItem)
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stub.Scores(lineItem)); err != nil {
+			slog.Error("Error encoding stub scores", "err", err)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (stub *LTIStub) receive(w http.ResponseWriter, r *http.Request, lineItem string) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !ok || token == "" {
+		http.Error(w, "Missing access token", http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("Content-Type") != LTIScoreContentType {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var score LTIScore
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&score); err != nil {
+		http.Error(w, "Invalid score", http.StatusBadRequest)
+		return
+	}
+	if score.UserID == "" || score.ScoreGiven < 0 || score.ScoreGiven > score.ScoreMaximum {
+		http.Error(w, "Invalid score", http.StatusBadRequest)
+		return
+	}
+	slog.Info("LTI stub got a score", "line-item", lineItem, "user-id", score.UserID, "score", score.ScoreGiven)
+
+	stub.mu.Lock()
+	if stub.scores[lineItem] == nil {
+		stub.scores[lineItem] = make(map[string]LTIScore)
+	}
+	stub.scores[lineItem][score.UserID] = score
+	stub.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Scores returns the last score of every user posted to the line item
+func (stub *LTIStub) Scores(lineItem string) map[string]LTIScore {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	scores := maps.Clone(stub.scores[lineItem])
+	if scores == nil {
+		scores = make(map[string]LTIScore)
+	}
+	return scores
+}
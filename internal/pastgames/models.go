@@ -5,11 +5,16 @@ import (
 )
 
 type PastGame struct {
-	ID        int64
-	StartedAt time.Time     `db:"started_at"`
-	EndedAt   time.Time     `db:"ended_at"`
-	QuizTitle string        `db:"quiz_title"`
-	Scores    []PlayerScore // sorted by score, descending
+	ID           int64
+	StartedAt    time.Time     `db:"started_at"`
+	EndedAt      time.Time     `db:"ended_at"`
+	QuizTitle    string        `db:"quiz_title"`
+	QuizID       int64         `db:"quiz_id"`       // 0 if the quiz wasn't stored in the database
+	QuizRevision int64         `db:"quiz_revision"` // Revision of the quiz the game was played with
+	Questions    int           `db:"questions"`     // Number of questions asked, 0 for games stored without it
+	Scores       []PlayerScore // sorted by score, descending
+	EventLog     []byte        `db:"event_log"`  // JSON of the events handled in the lobby, nil for games stored without it
+	Anonymised   bool          `db:"anonymised"` // Usernames were replaced and the event log removed by the retention policy
 }
 
 type PlayerScore struct {
@@ -1,5 +1,7 @@
 package pastgames
 
+import "time"
+
 type ErrPastGameNotFound struct{}
 
 func (ErrPastGameNotFound) Error() string {
@@ -11,5 +13,16 @@ type Repository interface {
 	Upsert(game *PastGame) (int64, error)
 	GetByID(id int64) (*PastGame, error)
 	GetAll() ([]PastGame, error)
-	BrowsePastGamesByID(query string) ([]PastGame, error)
+	// Search returns a page of the games matching the filter
+	Search(filter SearchFilter) (SearchPage, error)
+	// StreamPastGames calls fn with every game matching the filter, in the order of the filter, ignoring the pagination
+	// The games are hydrated with their scores, without the event log, and are never held in memory all at once
+	StreamPastGames(filter SearchFilter, fn func(PastGame) error) error
+	// Delete deletes the game and its scores
+	Delete(id int64) error
+	// DeleteEndedBefore deletes the games that ended before the time and returns how many were deleted
+	DeleteEndedBefore(t time.Time) (int64, error)
+	// AnonymiseEndedBefore replaces the usernames of the games that ended before the time with their placement,
+	// like "Player 1", and removes their event logs. It returns how many games were anonymised
+	AnonymiseEndedBefore(t time.Time) (int64, error)
 }
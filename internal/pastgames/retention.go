@@ -0,0 +1,66 @@
+package pastgames
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+)
+
+// retentionMetrics counts the purged past games, it is published with the other expvars
+var retentionMetrics = expvar.NewMap("pastgames_retention")
+
+// RetentionConfig controls how long past games are kept
+type RetentionConfig struct {
+	Interval       time.Duration // How often the policy is enforced
+	AnonymiseAfter time.Duration // Usernames of games that ended longer ago are anonymised, 0 means never
+	DeleteAfter    time.Duration // Games that ended longer ago are deleted, 0 means never
+}
+
+// RunRetention enforces the retention policy every cfg.Interval until the context is done
+func (s Service) RunRetention(ctx context.Context, cfg RetentionConfig) {
+	if cfg.Interval <= 0 || (cfg.AnonymiseAfter <= 0 && cfg.DeleteAfter <= 0) {
+		slog.Info("Past games retention disabled")
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.enforceRetention(now, cfg)
+		}
+	}
+}
+
+// enforceRetention deletes and anonymises the games that are too old at the given time
+// It returns how many games were deleted and anonymised
+func (s Service) enforceRetention(now time.Time, cfg RetentionConfig) (deleted, anonymised int64) {
+	// Deleting first spares anonymising games that are deleted anyway
+	if cfg.DeleteAfter > 0 {
+		var err error
+		deleted, err = s.repo.DeleteEndedBefore(now.Add(-cfg.DeleteAfter))
+		if err != nil {
+			retentionMetrics.Add("errors", 1)
+			slog.Error("Error deleting expired past games", "err", err)
+		}
+		retentionMetrics.Add("deleted_expired", deleted)
+	}
+	if cfg.AnonymiseAfter > 0 {
+		var err error
+		anonymised, err = s.repo.AnonymiseEndedBefore(now.Add(-cfg.AnonymiseAfter))
+		if err != nil {
+			retentionMetrics.Add("errors", 1)
+			slog.Error("Error anonymising past games", "err", err)
+		}
+		retentionMetrics.Add("anonymised", anonymised)
+	}
+
+	if deleted > 0 || anonymised > 0 {
+		slog.Info("Enforced past games retention", "deleted", deleted, "anonymised", anonymised)
+	}
+	return deleted, anonymised
+}
@@ -0,0 +1,81 @@
+package pastgames
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositorySQLite_Delete(t *testing.T) {
+	repo, teardown := setup()
+	defer teardown()
+
+	game := &PastGame{ID: 1, EndedAt: time.Now(), QuizTitle: "Test Quiz", Scores: []PlayerScore{{Username: "player1", Score: 10}}}
+	if _, err := repo.Upsert(game); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := repo.Delete(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := repo.GetByID(1); err == nil {
+		t.Error("Expected the game to be deleted")
+	}
+	var scores int
+	if err := repo.db.Get(&scores, "SELECT COUNT(*) FROM player_score"); err != nil || scores != 0 {
+		t.Errorf("Expected the scores to be deleted, %d left (err: %v)", scores, err)
+	}
+
+	if _, ok := repo.Delete(1).(ErrPastGameNotFound); !ok {
+		t.Error("Expected ErrPastGameNotFound deleting a missing game")
+	}
+}
+
+func TestEnforceRetention(t *testing.T) {
+	repo, teardown := setup()
+	defer teardown()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	games := []PastGame{
+		{ID: 1, EndedAt: now.AddDate(0, 0, -400), Scores: []PlayerScore{{Username: "alice", Score: 10}}},
+		{ID: 2, EndedAt: now.AddDate(0, 0, -100), EventLog: []byte(`[]`), Scores: []PlayerScore{
+			{Username: "alice", Score: 900},
+			{Username: "bob", Score: 500},
+		}},
+		{ID: 3, EndedAt: now.AddDate(0, 0, -1), Scores: []PlayerScore{{Username: "carol", Score: 300}}},
+	}
+	for _, game := range games {
+		game.QuizTitle = "Test Quiz"
+		if _, err := repo.Upsert(&game); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	s := NewService(repo)
+	cfg := RetentionConfig{Interval: time.Hour, AnonymiseAfter: 90 * 24 * time.Hour, DeleteAfter: 365 * 24 * time.Hour}
+	if deleted, anonymised := s.enforceRetention(now, cfg); deleted != 1 || anonymised != 1 {
+		t.Fatalf("Expected 1 deleted and 1 anonymised game, got %d and %d", deleted, anonymised)
+	}
+
+	if _, err := repo.GetByID(1); err == nil {
+		t.Error("Expected game 1 to be deleted")
+	}
+	anonymised, err := repo.GetByID(2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !anonymised.Anonymised || anonymised.EventLog != nil {
+		t.Errorf("Expected game 2 to be anonymised without event log, got %+v", anonymised)
+	}
+	expected := []PlayerScore{{Username: "Player 1", Score: 900}, {Username: "Player 2", Score: 500}}
+	if len(anonymised.Scores) != 2 || anonymised.Scores[0] != expected[0] || anonymised.Scores[1] != expected[1] {
+		t.Errorf("Expected scores %v, got %v", expected, anonymised.Scores)
+	}
+	if kept, err := repo.GetByID(3); err != nil || kept.Anonymised || kept.Scores[0].Username != "carol" {
+		t.Errorf("Expected game 3 to be kept as is, got %+v (err: %v)", kept, err)
+	}
+
+	// Games are anonymised only once
+	if deleted, anonymised := s.enforceRetention(now, cfg); deleted != 0 || anonymised != 0 {
+		t.Errorf("Expected nothing to change, got %d deleted and %d anonymised", deleted, anonymised)
+	}
+}
@@ -1,70 +1,204 @@
 package pastgames
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // NewPastGamesRouter sets up the routes for the pastgames package.
 func (s Service) NewPastGamesRouter() http.Handler {
 	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /past-games/{gameID}", s.deletePastGameHandler)
 	mux.HandleFunc("/past-games/{gameID}", s.getPastGameHandler)
+	mux.HandleFunc("POST /past-games/purge", s.purgePastGamesHandler)
+	mux.HandleFunc("GET /past-games/{gameID}/gradebook", s.gradebookHandler)
+	mux.HandleFunc("POST /past-games/{gameID}/gradebook", s.gradebookHandler)
+	mux.HandleFunc("POST /past-games/{gameID}/passback", s.passbackHandler)
 	mux.HandleFunc("/past-games/{$}", s.browsePastGamesHandler)
 
 	return mux
 }
 
 func (s Service) getPastGameHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiateFormat(r)
+	if !ok {
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+	pastGame, ok := s.pastGameFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if format != formatHTML {
+		export := newExportWriter(w, format, fmt.Sprintf("past-game-%d", pastGame.ID), false)
+		if err := export.Write(*pastGame); err != nil {
+			slog.Error("Error exporting past game", "err", err)
+			return
+		}
+		if err := export.Close(); err != nil {
+			slog.Error("Error exporting past game", "err", err)
+		}
+		return
+	}
+
+	if err := pastGameTmpl.Execute(w, pastGame); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// pastGameFromPath returns the past game of the gameID path value, on failure it writes the error response
+func (s Service) pastGameFromPath(w http.ResponseWriter, r *http.Request) (*PastGame, bool) {
 	// Extract the game ID from the URL
 	gameID := r.PathValue("gameID")
 
 	id, err := strconv.Atoi(gameID)
 	if err != nil {
 		http.Error(w, "Invalid game ID", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
 	pastGame, err := s.repo.GetByID(int64(id))
 	if err != nil {
 		if _, ok := err.(ErrPastGameNotFound); ok {
 			http.Error(w, "Past game not found", http.StatusNotFound)
-			return
+			return nil, false
 		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		slog.Error("Error getting past game", "err", err)
+		return nil, false
+	}
+	return pastGame, true
+}
+
+// gradebookHandler downloads the grades of the game as CSV
+// On POST the usernames are mapped to the identifiers of the roster form value
+func (s Service) gradebookHandler(w http.ResponseWriter, r *http.Request) {
+	pastGame, ok := s.pastGameFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	if err := pastGameTmpl.Execute(w, pastGame); err != nil {
+	roster, err := ParseRoster(strings.NewReader(r.FormValue("roster")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gradebook-%d.csv"`, pastGame.ID))
+	if err := WriteGradebookCSV(w, pastGame.Grades(roster)); err != nil {
+		slog.Error("Error writing gradebook", "err", err)
+	}
+}
+
+// passbackHandler posts the grades of the players on the roster to the scores endpoint of an LTI line item
+func (s Service) passbackHandler(w http.ResponseWriter, r *http.Request) {
+	pastGame, ok := s.pastGameFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	data := struct {
+		Error    string
+		Sent     int
+		Unmapped []string
+	}{}
+
+	roster, err := ParseRoster(strings.NewReader(r.FormValue("roster")))
+	lineItemURL := strings.TrimSpace(r.FormValue("line-item"))
+	// Only configured platforms are allowed, otherwise anyone could make the server post to any address
+	origin, originErr := ltiOrigin(lineItemURL)
+	switch {
+	case err != nil:
+		data.Error = err.Error()
+	case lineItemURL == "":
+		data.Error = "The line item URL is required"
+	case originErr != nil:
+		data.Error = "The line item URL must be an HTTP URL"
+	case !s.platforms[origin]:
+		data.Error = "The line item isn't on a configured LTI platform"
+	}
+
+	if data.Error == "" {
+		var scores []LTIScore
+		scores, data.Unmapped = pastGame.LTIScores(roster)
+		if len(scores) == 0 {
+			data.Error = "None of the players is on the roster"
+		} else if err := PassbackGrades(r.Context(), s.client, lineItemURL, r.FormValue("token"), scores); err != nil {
+			slog.Warn("Error passing back grades", "game-id", pastGame.ID, "line-item", lineItemURL, "err", err)
+			// Transport errors stay in the log, they tell about the network of the server
+			data.Error = "Passing back the grades failed"
+			var rejected ScoreRejectedError
+			if errors.As(err, &rejected) {
+				data.Error = "The platform rejected the grades with status " + strconv.Itoa(rejected.Status)
+			}
+		} else {
+			data.Sent = len(scores)
+		}
+	}
+
+	if err := pastGameTmpl.ExecuteTemplate(w, "passback-result", data); err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
 		slog.Error("Error rendering template", "err", err)
 	}
 }
 
 func (s Service) browsePastGamesHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-
-	var pastGames []PastGame
-	var err error
-	if query != "" {
-		pastGames, err = s.repo.BrowsePastGamesByID(query)
-	} else {
-		pastGames, err = s.repo.GetAll()
+	format, ok := negotiateFormat(r)
+	if !ok {
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+	filter, err := parseSearchFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != formatHTML {
+		s.exportPastGames(w, format, filter)
+		return
 	}
 
+	page, err := s.repo.Search(filter)
 	if err != nil {
+		if _, ok := err.(ErrInvalidSearch); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		slog.Error("Error searching past games", "err", err)
 		return
 	}
 
+	// The links keep the search, without the page
+	search := r.URL.Query()
+	search.Del("cursor")
+	search.Del("format")
 	data := struct {
-		Query string
-		Games []PastGame
+		Query, Player, From, To, Sort string // Values of the search form
+		Games                         []PastGame
+		Search                        string // Query string of the search
+		NextURL                       string // Empty on the last page
 	}{
-		Query: query,
-		Games: pastGames,
+		Query:  search.Get("q"),
+		Player: search.Get("player"),
+		From:   search.Get("from"),
+		To:     search.Get("to"),
+		Sort:   filter.Sort,
+		Games:  page.Games,
+		Search: search.Encode(),
+	}
+	if page.NextCursor != "" {
+		search.Set("cursor", page.NextCursor)
+		data.NextURL = "/past-games/?" + search.Encode()
 	}
 
 	if err := pastGamesListTmpl.Execute(w, data); err != nil {
@@ -72,3 +206,109 @@ func (s Service) browsePastGamesHandler(w http.ResponseWriter, r *http.Request)
 		slog.Error("Error rendering template", "err", err)
 	}
 }
+
+// deletePastGameHandler deletes the game and redirects HTMX requests to the list of past games
+func (s Service) deletePastGameHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("gameID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid game ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		if _, ok := err.(ErrPastGameNotFound); ok {
+			http.Error(w, "Past game not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.Error("Error deleting past game", "err", err)
+		return
+	}
+	retentionMetrics.Add("deleted_by_id", 1)
+	slog.Info("Deleted past game", "id", id)
+
+	w.Header().Set("HX-Redirect", "/past-games/")
+	w.WriteHeader(http.StatusOK)
+}
+
+// purgePastGamesHandler deletes the games that ended before the day of the before form value, in UTC
+func (s Service) purgePastGamesHandler(w http.ResponseWriter, r *http.Request) {
+	before, err := time.Parse(time.DateOnly, r.FormValue("before"))
+	if err != nil {
+		http.Error(w, "before must be a date like 2006-01-02", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := s.repo.DeleteEndedBefore(before)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.Error("Error purging past games", "err", err)
+		return
+	}
+	retentionMetrics.Add("deleted_by_age", deleted)
+	slog.Info("Purged past games", "before", before, "deleted", deleted)
+
+	if err := pastGamesListTmpl.ExecuteTemplate(w, "purge-result", deleted); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// parseSearchFilter reads the filter from the query parameters q, player, from, to, sort, cursor and limit
+// The dates are days in UTC, both ends of the range are inclusive
+func parseSearchFilter(values url.Values) (SearchFilter, error) {
+	filter := SearchFilter{
+		Query:  strings.TrimSpace(values.Get("q")),
+		Player: strings.TrimSpace(values.Get("player")),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+	if from := values.Get("from"); from != "" {
+		day, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return filter, ErrInvalidSearch{Reason: "from must be a date like 2006-01-02"}
+		}
+		filter.From = day
+	}
+	if to := values.Get("to"); to != "" {
+		day, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return filter, ErrInvalidSearch{Reason: "to must be a date like 2006-01-02"}
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return filter, ErrInvalidSearch{Reason: "limit must be a positive number"}
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// exportPastGames streams all games matching the filter, every game is written as soon as it is read
+func (s Service) exportPastGames(w http.ResponseWriter, format string, filter SearchFilter) {
+	export := newExportWriter(w, format, "past-games", true)
+	written := 0
+	err := s.repo.StreamPastGames(filter, func(pg PastGame) error {
+		written++
+		return export.Write(pg)
+	})
+	if err != nil {
+		// Once a game is written the status is sent, the export just ends early
+		if written == 0 {
+			w.Header().Del("Content-Disposition")
+			if _, ok := err.(ErrInvalidSearch); ok {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		slog.Error("Error exporting past games", "err", err, "written", written)
+		return
+	}
+	if err := export.Close(); err != nil {
+		slog.Error("Error exporting past games", "err", err)
+	}
+}
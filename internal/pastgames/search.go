@@ -0,0 +1,104 @@
+package pastgames
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Orders of the search results
+const (
+	SortNewest = "newest" // By end time, descending
+	SortOldest = "oldest" // By end time, ascending
+	SortTitle  = "title"  // By quiz title, alphabetically
+)
+
+// Number of games on a page of the search results by default and at most
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// SearchFilter selects the past games of a search, empty fields don't filter
+type SearchFilter struct {
+	Query  string    // Part of the quiz title or of the game ID
+	Player string    // Part of the username of a player
+	From   time.Time // Games that ended at or after
+	To     time.Time // Games that ended before
+	Sort   string    // One of the Sort constants, SortNewest if empty
+	Cursor string    // NextCursor of the previous page, empty for the first page
+	Limit  int       // Games on the page, DefaultPageSize if 0
+}
+
+// SearchPage is a page of the search results, the games are hydrated with their scores
+type SearchPage struct {
+	Games      []PastGame
+	NextCursor string // Empty on the last page
+}
+
+type ErrInvalidSearch struct {
+	Reason string
+}
+
+func (e ErrInvalidSearch) Error() string {
+	return "invalid search: " + e.Reason
+}
+
+// normalise validates the filter and fills in the defaults
+func (f SearchFilter) normalise() (SearchFilter, error) {
+	switch f.Sort {
+	case "":
+		f.Sort = SortNewest
+	case SortNewest, SortOldest, SortTitle:
+	default:
+		return f, ErrInvalidSearch{Reason: "unknown sort " + f.Sort}
+	}
+	if f.Limit == 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > MaxPageSize {
+		return f, ErrInvalidSearch{Reason: "page size must be between 1 and 100"}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, ErrInvalidSearch{Reason: "the date range is empty"}
+	}
+	return f, nil
+}
+
+// searchCursor is the position after the last game of a page, in the order of the search
+type searchCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"` // Value of the sort column of the last game
+	ID   int64  `json:"i"` // Ties of the sort column are ordered by ID
+}
+
+func (c searchCursor) encode() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchCursor(s, sort string) (searchCursor, error) {
+	var c searchCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidSearch{Reason: "malformed cursor"}
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidSearch{Reason: "malformed cursor"}
+	}
+	if c.Sort != sort {
+		return c, ErrInvalidSearch{Reason: "the cursor belongs to a different sort"}
+	}
+	return c, nil
+}
+
+// Winner returns the score of the first player, nil if nobody played
+func (pg PastGame) Winner() *PlayerScore {
+	if len(pg.Scores) == 0 {
+		return nil
+	}
+	return &pg.Scores[0]
+}
@@ -0,0 +1,135 @@
+package pastgames
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositorySQLite_Search(t *testing.T) {
+	repo, teardown := setup()
+	defer teardown()
+
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	games := []PastGame{
+		{ID: 1, EndedAt: day, QuizTitle: "Capitals", Scores: []PlayerScore{{Username: "alice", Score: 900}, {Username: "bob", Score: 100}}},
+		{ID: 2, EndedAt: day.AddDate(0, 0, 1), QuizTitle: "rivers", Scores: []PlayerScore{{Username: "bob", Score: 700}}},
+		{ID: 3, EndedAt: day.AddDate(0, 0, 2), QuizTitle: "100% Capitals"},
+		// Same end time as game 2 in another time zone, ties are ordered by ID
+		{ID: 4, EndedAt: day.AddDate(0, 0, 1).In(time.FixedZone("CEST", 2*60*60)), QuizTitle: "Mountains", Scores: []PlayerScore{{Username: "carol_1", Score: 300}}},
+	}
+	for _, game := range games {
+		game.StartedAt = game.EndedAt.Add(-time.Hour)
+		if _, err := repo.Upsert(&game); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	ids := func(page SearchPage) []int64 {
+		ids := make([]int64, 0, len(page.Games))
+		for _, game := range page.Games {
+			ids = append(ids, game.ID)
+		}
+		return ids
+	}
+	equal := func(a, b []int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	tests := []struct {
+		name     string
+		filter   SearchFilter
+		expected []int64
+	}{
+		{"all newest first", SearchFilter{}, []int64{3, 4, 2, 1}},
+		{"oldest first", SearchFilter{Sort: SortOldest}, []int64{1, 2, 4, 3}},
+		{"by title", SearchFilter{Sort: SortTitle}, []int64{3, 1, 4, 2}},
+		{"title", SearchFilter{Query: "capitals"}, []int64{3, 1}},
+		{"title with wildcard", SearchFilter{Query: "100%"}, []int64{3}},
+		{"id", SearchFilter{Query: "2"}, []int64{2}},
+		{"player", SearchFilter{Player: "BOB"}, []int64{2, 1}},
+		{"player with wildcard", SearchFilter{Player: "l_1"}, []int64{4}},
+		{"date range", SearchFilter{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 2)}, []int64{4, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := repo.Search(test.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !equal(ids(page), test.expected) {
+				t.Errorf("Expected games %v, got %v", test.expected, ids(page))
+			}
+			if page.NextCursor != "" {
+				t.Errorf("Expected a single page, got cursor %q", page.NextCursor)
+			}
+		})
+	}
+
+	t.Run("hydrated scores", func(t *testing.T) {
+		page, err := repo.Search(SearchFilter{Query: "Capitals"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(page.Games[0].Scores) != 0 || page.Games[0].Winner() != nil {
+			t.Errorf("Expected no scores for game 3, got %v", page.Games[0].Scores)
+		}
+		if winner := page.Games[1].Winner(); len(page.Games[1].Scores) != 2 || winner == nil || winner.Username != "alice" {
+			t.Errorf("Expected alice to win game 1, got %v", page.Games[1].Scores)
+		}
+	})
+
+	for _, sort := range []string{SortNewest, SortOldest, SortTitle} {
+		t.Run("pages by "+sort, func(t *testing.T) {
+			all, err := repo.Search(SearchFilter{Sort: sort})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var paged []int64
+			filter := SearchFilter{Sort: sort, Limit: 3}
+			for pages := 1; ; pages++ {
+				page, err := repo.Search(filter)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				paged = append(paged, ids(page)...)
+				if page.NextCursor == "" {
+					if pages != 2 {
+						t.Errorf("Expected 2 pages, got %d", pages)
+					}
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if !equal(paged, ids(all)) {
+				t.Errorf("Expected the pages to contain %v, got %v", ids(all), paged)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		first, _ := repo.Search(SearchFilter{Limit: 1})
+		invalid := []SearchFilter{
+			{Sort: "players"},
+			{Limit: MaxPageSize + 1},
+			{From: day, To: day},
+			{Cursor: "not a cursor"},
+			{Sort: SortTitle, Cursor: first.NextCursor},
+		}
+		for _, filter := range invalid {
+			if _, err := repo.Search(filter); err == nil {
+				t.Errorf("Expected an error for %+v", filter)
+			} else if _, ok := err.(ErrInvalidSearch); !ok {
+				t.Errorf("Expected ErrInvalidSearch for %+v, got %v", filter, err)
+			}
+		}
+	})
+}
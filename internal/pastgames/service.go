@@ -1,11 +1,42 @@
 package pastgames
 
+import (
+	"maps"
+	"net/http"
+	"time"
+)
+
 type Service struct {
-	repo Repository
+	repo      Repository
+	client    *http.Client    // Client of the grade passback
+	platforms map[string]bool // Origins of the LTI platforms grades may be passed back to
 }
 
 func NewService(repo Repository) Service {
 	return Service{
 		repo: repo,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// A platform must not send the grades, and the server, somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		platforms: make(map[string]bool),
+	}
+}
+
+// WithLTIPlatforms returns the service allowing the grade passback to line items of the platforms
+// The platforms are given by their origin, e.g. https://lms.example.com
+func (s Service) WithLTIPlatforms(origins ...string) (Service, error) {
+	platforms := maps.Clone(s.platforms)
+	for _, origin := range origins {
+		o, err := ltiOrigin(origin)
+		if err != nil {
+			return s, err
+		}
+		platforms[o] = true
 	}
+	s.platforms = platforms
+	return s, nil
 }
@@ -1,9 +1,13 @@
 package pastgames
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/erykksc/kwikquiz/internal/common"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -30,7 +34,12 @@ func (repo *repositorySQLite) createTables() error {
 			id INTEGER PRIMARY KEY,
 			started_at DATETIME,
 			ended_at DATETIME,
-			quiz_title TEXT
+			quiz_title TEXT,
+			quiz_id INTEGER NOT NULL DEFAULT 0,
+			quiz_revision INTEGER NOT NULL DEFAULT 0,
+			questions INTEGER NOT NULL DEFAULT 0,
+			event_log BLOB,
+			anonymised INTEGER NOT NULL DEFAULT 0
 		);
 
 		CREATE TABLE IF NOT EXISTS player_score (
@@ -43,7 +52,24 @@ func (repo *repositorySQLite) createTables() error {
 		CREATE INDEX IF NOT EXISTS idx_player_score_past_game_id ON player_score(past_game_id);
 	`
 	_, err := repo.db.Exec(schema)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Databases created before quiz revisions existed lack the quiz columns
+	if err := common.AddColumnIfMissing(repo.db, "past_game", "quiz_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "past_game", "quiz_revision", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "past_game", "questions", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "past_game", "event_log", "BLOB"); err != nil {
+		return err
+	}
+	return common.AddColumnIfMissing(repo.db, "past_game", "anonymised", "INTEGER NOT NULL DEFAULT 0")
 }
 
 func (repo *repositorySQLite) Insert(game *PastGame) (int64, error) {
@@ -59,8 +85,8 @@ func (repo *repositorySQLite) Insert(game *PastGame) (int64, error) {
 
 	// Insert the game
 	res, err := tx.NamedExec(`
-        INSERT INTO past_game (started_at, ended_at, quiz_title)
-		VALUES (:started_at, :ended_at, :quiz_title)
+        INSERT INTO past_game (started_at, ended_at, quiz_title, quiz_id, quiz_revision, questions, event_log, anonymised)
+		VALUES (:started_at, :ended_at, :quiz_title, :quiz_id, :quiz_revision, :questions, :event_log, :anonymised)
     `, &game)
 	if err != nil {
 		return 0, err
@@ -100,12 +126,17 @@ func (repo *repositorySQLite) Upsert(game *PastGame) (int64, error) {
 	defer tx.Rollback() //nolint
 
 	_, err = tx.NamedExec(`
-        INSERT INTO past_game (id, started_at, ended_at, quiz_title)
-		VALUES (:id, :started_at, :ended_at, :quiz_title)
+        INSERT INTO past_game (id, started_at, ended_at, quiz_title, quiz_id, quiz_revision, questions, event_log, anonymised)
+		VALUES (:id, :started_at, :ended_at, :quiz_title, :quiz_id, :quiz_revision, :questions, :event_log, :anonymised)
         ON CONFLICT(id) DO UPDATE SET
         started_at = EXCLUDED.started_at,
         ended_at = EXCLUDED.ended_at,
-        quiz_title = EXCLUDED.quiz_title
+        quiz_title = EXCLUDED.quiz_title,
+        quiz_id = EXCLUDED.quiz_id,
+        quiz_revision = EXCLUDED.quiz_revision,
+        questions = EXCLUDED.questions,
+        event_log = EXCLUDED.event_log,
+        anonymised = EXCLUDED.anonymised
     `, &game)
 	if err != nil {
 		return 0, err
@@ -156,9 +187,246 @@ func (repo *repositorySQLite) GetAll() ([]PastGame, error) {
 	return games, err
 }
 
-func (repo *repositorySQLite) BrowsePastGamesByID(query string) ([]PastGame, error) {
-	sQuery := "SELECT * FROM past_game WHERE CAST(id AS TEXT) LIKE ?"
-	var games []PastGame
-	err := repo.db.Select(&games, sQuery, fmt.Sprintf("%%%s%%", query))
-	return games, err
+// endedAtKey is the end time of the past_game pg normalised to UTC in searchTimeFormat, so it compares as text
+const endedAtKey = "strftime('%Y-%m-%d %H:%M:%f', pg.ended_at)"
+
+// Sort columns of the searches, normalised so they compare the same in SQL and in the cursors
+var searchSortKeys = map[string]string{
+	SortNewest: "COALESCE(" + endedAtKey + ", '')",
+	SortOldest: "COALESCE(" + endedAtKey + ", '')",
+	SortTitle:  "COALESCE(LOWER(pg.quiz_title), '')",
+}
+
+// searchTimeFormat is the format of the normalised end times
+const searchTimeFormat = "2006-01-02 15:04:05.000"
+
+// searchQuery returns the key, the direction and the WHERE conditions of the normalised filter, ignoring the cursor
+func searchQuery(f SearchFilter) (key, dir string, conditions []string, args []any) {
+	key = searchSortKeys[f.Sort]
+	dir = "ASC"
+	if f.Sort == SortNewest {
+		dir = "DESC"
+	}
+
+	conditions = []string{"1"}
+	if f.Query != "" {
+		conditions = append(conditions, `(pg.quiz_title LIKE ? ESCAPE '\' OR CAST(pg.id AS TEXT) LIKE ? ESCAPE '\')`)
+		args = append(args, likePattern(f.Query), likePattern(f.Query))
+	}
+	if f.Player != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM player_score player WHERE player.past_game_id = pg.id AND player.username LIKE ? ESCAPE '\'
+		)`)
+		args = append(args, likePattern(f.Player))
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, searchSortKeys[SortNewest]+" >= ?")
+		args = append(args, f.From.UTC().Format(searchTimeFormat))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, searchSortKeys[SortNewest]+" < ?")
+		args = append(args, f.To.UTC().Format(searchTimeFormat))
+	}
+	return key, dir, conditions, args
+}
+
+// likePattern returns the LIKE pattern matching the strings containing s
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// selectGamesWithScores is the start of the queries hydrating the games with their scores in one query,
+// one row per score in the stored order, the games without scores have a row of NULL scores
+const selectGamesWithScores = `
+	SELECT pg.id, pg.started_at, pg.ended_at, pg.quiz_title, pg.quiz_id, pg.quiz_revision, pg.questions, pg.anonymised,
+		%s AS sort_key, ps.username, ps.score
+	FROM past_game pg
+	LEFT JOIN player_score ps ON ps.past_game_id = pg.id
+`
+
+func (repo *repositorySQLite) Search(filter SearchFilter) (SearchPage, error) {
+	f, err := filter.normalise()
+	if err != nil {
+		return SearchPage{}, err
+	}
+	key, dir, conditions, args := searchQuery(f)
+	if f.Cursor != "" {
+		cursor, err := decodeSearchCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return SearchPage{}, err
+		}
+		comparison := ">"
+		if dir == "DESC" {
+			comparison = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, pg.id) %s (?, ?)", key, comparison))
+		args = append(args, cursor.Key, cursor.ID)
+	}
+	// One more game than the page tells whether there is a next page
+	args = append(args, f.Limit+1)
+
+	query := fmt.Sprintf(selectGamesWithScores+`
+		WHERE pg.id IN (
+			SELECT pg.id FROM past_game pg WHERE %[2]s ORDER BY %[1]s %[3]s, pg.id %[3]s LIMIT ?
+		)
+		ORDER BY sort_key %[3]s, pg.id %[3]s, ps.id
+	`, key, strings.Join(conditions, " AND "), dir)
+
+	var page SearchPage
+	var lastKey string
+	err = repo.scanGamesWithScores(query, args, func(pg PastGame, sortKey string) error {
+		if len(page.Games) == f.Limit {
+			last := page.Games[len(page.Games)-1]
+			page.NextCursor = searchCursor{Sort: f.Sort, Key: lastKey, ID: last.ID}.encode()
+			return nil
+		}
+		page.Games = append(page.Games, pg)
+		lastKey = sortKey
+		return nil
+	})
+	return page, err
+}
+
+func (repo *repositorySQLite) StreamPastGames(filter SearchFilter, fn func(PastGame) error) error {
+	filter.Cursor, filter.Limit = "", 0
+	f, err := filter.normalise()
+	if err != nil {
+		return err
+	}
+	key, dir, conditions, args := searchQuery(f)
+	query := fmt.Sprintf(selectGamesWithScores+`
+		WHERE %[2]s
+		ORDER BY sort_key %[3]s, pg.id %[3]s, ps.id
+	`, key, strings.Join(conditions, " AND "), dir)
+
+	return repo.scanGamesWithScores(query, args, func(pg PastGame, _ string) error {
+		return fn(pg)
+	})
+}
+
+// scanGamesWithScores runs a query starting with selectGamesWithScores and calls fn with every game and its sort key
+// The rows of a game are consecutive, so every game is passed on as soon as its last score is read
+func (repo *repositorySQLite) scanGamesWithScores(query string, args []any, fn func(pg PastGame, sortKey string) error) error {
+	rows, err := repo.db.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var game *PastGame
+	var gameSortKey string
+	for rows.Next() {
+		var row PastGame
+		var sortKey string
+		var username sql.NullString
+		var score sql.NullInt64
+		err := rows.Scan(&row.ID, &row.StartedAt, &row.EndedAt, &row.QuizTitle, &row.QuizID, &row.QuizRevision, &row.Questions, &row.Anonymised,
+			&sortKey, &username, &score)
+		if err != nil {
+			return err
+		}
+
+		if game != nil && game.ID != row.ID {
+			if err := fn(*game, gameSortKey); err != nil {
+				return err
+			}
+			game = nil
+		}
+		if game == nil {
+			game, gameSortKey = &row, sortKey
+		}
+		if username.Valid {
+			game.Scores = append(game.Scores, PlayerScore{Username: username.String, Score: int(score.Int64)})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if game != nil {
+		return fn(*game, gameSortKey)
+	}
+	return nil
+}
+
+func (repo *repositorySQLite) Delete(id int64) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	// The scores are deleted explicitly, as foreign keys are only enforced on connections that turned them on
+	if _, err := tx.Exec("DELETE FROM player_score WHERE past_game_id = ?", id); err != nil {
+		return err
+	}
+	res, err := tx.Exec("DELETE FROM past_game WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrPastGameNotFound{}
+	}
+	return tx.Commit()
+}
+
+func (repo *repositorySQLite) DeleteEndedBefore(t time.Time) (int64, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	before := t.UTC().Format(searchTimeFormat)
+	_, err = tx.Exec(`
+		DELETE FROM player_score WHERE past_game_id IN (SELECT pg.id FROM past_game pg WHERE `+endedAtKey+` < ?)
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM past_game AS pg WHERE "+endedAtKey+" < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+func (repo *repositorySQLite) AnonymiseEndedBefore(t time.Time) (int64, error) {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	before := t.UTC().Format(searchTimeFormat)
+	// The scores are stored sorted, so the position of a score within its game is the placement of the player
+	_, err = tx.Exec(`
+		UPDATE player_score SET username = 'Player ' || (
+			SELECT COUNT(*) FROM player_score other
+			WHERE other.past_game_id = player_score.past_game_id AND other.id <= player_score.id
+		)
+		WHERE past_game_id IN (SELECT pg.id FROM past_game pg WHERE NOT pg.anonymised AND `+endedAtKey+` < ?)
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		UPDATE past_game AS pg SET anonymised = 1, event_log = NULL WHERE NOT pg.anonymised AND `+endedAtKey+` < ?
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	anonymised, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return anonymised, tx.Commit()
 }
@@ -103,6 +103,32 @@ func TestRepositorySQLite_Insert(t *testing.T) {
 			prevScore = score.Score
 		}
 	})
+
+	t.Run("insert game with event log", func(t *testing.T) {
+		game := &PastGame{
+			StartedAt: time.Now().Add(-time.Hour),
+			EndedAt:   time.Now(),
+			QuizTitle: "Test Quiz",
+			Questions: 3,
+			EventLog:  []byte(`[{"event":"leGameStartRequested"}]`),
+		}
+
+		id, err := repo.Insert(game)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+
+		insertedGame, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if string(insertedGame.EventLog) != string(game.EventLog) {
+			t.Errorf("Expected event_log '%s', got '%s'", game.EventLog, insertedGame.EventLog)
+		}
+		if insertedGame.Questions != game.Questions {
+			t.Errorf("Expected %d questions, got %d", game.Questions, insertedGame.Questions)
+		}
+	})
 }
 
 func TestRepositorySQLite_Upsert(t *testing.T) {
@@ -0,0 +1,90 @@
+package quiz
+
+import (
+	"strings"
+
+	"github.com/erykksc/kwikquiz/internal/game"
+)
+
+type ErrBankQuestionNotFound struct{}
+
+func (ErrBankQuestionNotFound) Error() string { return "Bank question not found" }
+
+type Difficulty string
+
+const (
+	DifficultyUnset  Difficulty = ""
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard}
+
+func (d Difficulty) IsValid() bool {
+	switch d {
+	case DifficultyUnset, DifficultyEasy, DifficultyMedium, DifficultyHard:
+		return true
+	default:
+		return false
+	}
+}
+
+// BankQuestion is a question stored in the shared question bank
+// Every quiz revision stores a copy of the bank questions it uses,
+// a change to a bank question creates a new revision of every quiz currently using it
+type BankQuestion struct {
+	ID         int64      `db:"bank_question_id"`
+	Text       string     `db:"question_text"`
+	Difficulty Difficulty `db:"difficulty"`
+	Tags       []string   // Topics of the question, normalized with NormalizeTags
+	UsedBy     int        `db:"used_by"` // Number of quizzes currently using the question
+	answers    []Answer
+}
+
+func (q BankQuestion) Answers() []game.Answer {
+	return Question{answers: q.answers}.Answers()
+}
+
+// Question returns the bank question as a quiz question referencing the bank
+func (q BankQuestion) Question() Question {
+	question := Question{
+		BankQuestionID: q.ID,
+		Text:           q.Text,
+		answers:        make([]Answer, len(q.answers)),
+	}
+	copy(question.answers, q.answers)
+	return question
+}
+
+// HasTag reports whether the question is tagged with the given tag
+func (q BankQuestion) HasTag(tag string) bool {
+	for _, t := range q.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags lowercases and trims the tags, removing empty and duplicate ones
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// BankQuery is used for searching the question bank, empty fields are ignored
+type BankQuery struct {
+	Text       string     // Matches question and answer texts
+	Tags       []string   // Questions need to have all of the tags
+	Difficulty Difficulty // Questions need to have the exact difficulty
+}
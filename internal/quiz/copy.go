@@ -0,0 +1,87 @@
+package quiz
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Duplicate returns a deep copy of the quiz that can be inserted as a new quiz
+// All questions and answers (including LaTeX and images) are copied,
+// database identifiers and links to the question bank are cleared so that nothing is shared with the original
+func (q Quiz) Duplicate() Quiz {
+	duplicate := Quiz{
+		TitleField:  "Copy of " + q.TitleField,
+		Password:    q.Password,
+		Description: q.Description,
+		Tags:        slices.Clone(q.Tags),
+		Questions:   make([]Question, len(q.Questions)),
+	}
+
+	for i, question := range q.Questions {
+		duplicate.Questions[i] = question.copy()
+	}
+
+	return duplicate
+}
+
+// copy returns a deep copy of the question without database identifiers
+// Questions from the bank are unlinked, so editing the bank question doesn't change the copy
+func (q Question) copy() Question {
+	question := Question{
+		Text:    q.Text,
+		answers: make([]Answer, len(q.answers)),
+	}
+
+	for i, answer := range q.answers {
+		question.answers[i] = Answer{
+			IsCorrect: answer.IsCorrect,
+			TextField: answer.TextField,
+			LaTeX:     answer.LaTeX,
+			ImageName: answer.ImageName,
+			Image:     slices.Clone(answer.Image),
+		}
+	}
+
+	return question
+}
+
+// QuestionPick identifies a question of an existing quiz, used for remixing quizzes
+type QuestionPick struct {
+	QuizID        int64
+	QuestionIndex int // First question is of index 0
+}
+
+// ParseQuestionPick parses a pick in the format "<quiz-id>-<question-index>"
+func ParseQuestionPick(s string) (QuestionPick, error) {
+	var pick QuestionPick
+	_, err := fmt.Sscanf(s, "%d-%d", &pick.QuizID, &pick.QuestionIndex)
+	if err != nil {
+		return QuestionPick{}, fmt.Errorf("invalid question pick %q: %w", s, err)
+	}
+	return pick, nil
+}
+
+// Remix creates a new quiz out of questions picked from existing quizzes
+// The questions are deep copied in the order they were picked
+func Remix(title, description string, picks []QuestionPick, quizzes map[int64]*Quiz) (Quiz, error) {
+	remix := Quiz{
+		TitleField:  title,
+		Description: description,
+		Questions:   make([]Question, 0, len(picks)),
+	}
+
+	for _, pick := range picks {
+		source, ok := quizzes[pick.QuizID]
+		if !ok || source == nil {
+			return Quiz{}, fmt.Errorf("quiz %d not found", pick.QuizID)
+		}
+
+		if pick.QuestionIndex < 0 || pick.QuestionIndex >= len(source.Questions) {
+			return Quiz{}, fmt.Errorf("quiz %d has no question with index %d", pick.QuizID, pick.QuestionIndex)
+		}
+
+		remix.Questions = append(remix.Questions, source.Questions[pick.QuestionIndex].copy())
+	}
+
+	return remix, nil
+}
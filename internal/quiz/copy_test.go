@@ -0,0 +1,110 @@
+package quiz
+
+import "testing"
+
+func TestDuplicate(t *testing.T) {
+	original := Quiz{
+		ID:          7,
+		TitleField:  "Formulas",
+		Description: "Quiz with LaTeX and images",
+		Revision:    3,
+		Questions: []Question{
+			{
+				ID:             12,
+				BankQuestionID: 5,
+				Text:           "Which one is the area of a circle?",
+				answers: []Answer{
+					{ID: 1, QuestionID: 12, LaTeX: `\pi r^2`, IsCorrect: true},
+					{ID: 2, QuestionID: 12, ImageName: "circle.png", Image: []byte{1, 2, 3}},
+				},
+			},
+		},
+	}
+
+	duplicate := original.Duplicate()
+
+	if duplicate.ID != 0 || duplicate.Revision != 0 {
+		t.Errorf("Expected cleared ID and revision, got: %d %d", duplicate.ID, duplicate.Revision)
+	}
+	if duplicate.TitleField != "Copy of Formulas" {
+		t.Errorf("Expected title 'Copy of Formulas', got: %s", duplicate.TitleField)
+	}
+	if len(duplicate.Questions) != 1 || len(duplicate.Questions[0].answers) != 2 {
+		t.Fatalf("Expected 1 question with 2 answers, got: %+v", duplicate.Questions)
+	}
+
+	answers := duplicate.Questions[0].answers
+	if answers[0].LaTeX != `\pi r^2` || !answers[0].IsCorrect {
+		t.Errorf("Expected LaTeX answer to be copied, got: %+v", answers[0])
+	}
+	if answers[1].ImageName != "circle.png" || len(answers[1].Image) != 3 {
+		t.Errorf("Expected image answer to be copied, got: %+v", answers[1])
+	}
+	if answers[0].ID != 0 || answers[0].QuestionID != 0 || duplicate.Questions[0].ID != 0 {
+		t.Errorf("Expected database identifiers to be cleared")
+	}
+	if duplicate.Questions[0].BankQuestionID != 0 {
+		t.Errorf("Expected the question to be unlinked from the question bank")
+	}
+
+	// Modifying the duplicate must not modify the original
+	answers[1].Image[0] = 42
+	duplicate.Questions[0].answers[0].LaTeX = "changed"
+	if original.Questions[0].answers[1].Image[0] != 1 {
+		t.Errorf("Expected original image to be unchanged")
+	}
+	if original.Questions[0].answers[0].LaTeX != `\pi r^2` {
+		t.Errorf("Expected original LaTeX to be unchanged")
+	}
+}
+
+func TestRemix(t *testing.T) {
+	quizzes := map[int64]*Quiz{
+		ExampleQuizGeography.ID: &ExampleQuizGeography,
+		ExampleQuizMath.ID:      &ExampleQuizMath,
+	}
+
+	picks := []QuestionPick{
+		{QuizID: ExampleQuizMath.ID, QuestionIndex: 1},
+		{QuizID: ExampleQuizGeography.ID, QuestionIndex: 0},
+	}
+
+	remix, err := Remix("Mixed", "Math and geography", picks, quizzes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(remix.Questions) != 2 {
+		t.Fatalf("Expected 2 questions, got %d", len(remix.Questions))
+	}
+	if remix.Questions[0].Text != ExampleQuizMath.Questions[1].Text {
+		t.Errorf("Expected first question %s, got %s", ExampleQuizMath.Questions[1].Text, remix.Questions[0].Text)
+	}
+	if remix.Questions[1].Text != ExampleQuizGeography.Questions[0].Text {
+		t.Errorf("Expected second question %s, got %s", ExampleQuizGeography.Questions[0].Text, remix.Questions[1].Text)
+	}
+	if len(remix.Questions[1].answers) != len(ExampleQuizGeography.Questions[0].answers) {
+		t.Errorf("Expected answers to be copied")
+	}
+
+	if _, err := Remix("Broken", "", []QuestionPick{{QuizID: 1}}, quizzes); err == nil {
+		t.Errorf("Expected error for picking from unknown quiz, got nil")
+	}
+	if _, err := Remix("Broken", "", []QuestionPick{{QuizID: ExampleQuizMath.ID, QuestionIndex: 9}}, quizzes); err == nil {
+		t.Errorf("Expected error for picking unknown question, got nil")
+	}
+}
+
+func TestParseQuestionPick(t *testing.T) {
+	pick, err := ParseQuestionPick("998-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pick.QuizID != 998 || pick.QuestionIndex != 1 {
+		t.Errorf("Expected pick 998-1, got: %+v", pick)
+	}
+
+	if _, err := ParseQuestionPick("abc"); err == nil {
+		t.Errorf("Expected error for invalid pick, got nil")
+	}
+}
@@ -3,6 +3,7 @@ package quiz
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	"github.com/erykksc/kwikquiz/internal/game"
 )
@@ -12,6 +13,8 @@ type Quiz struct {
 	TitleField  string `db:"title"`
 	Password    string
 	Description string
+	Revision    int64    `db:"revision"` // Revision the questions were loaded from
+	Tags        []string // Topics of the quiz, normalized with NormalizeTags
 	Questions   []Question
 }
 
@@ -32,10 +35,21 @@ func (q Quiz) QuestionsCount() int {
 }
 
 type Question struct {
-	ID      int64  `db:"question_id"`
-	QuizID  int64  `db:"quiz_id"`
-	Text    string `db:"question_text"`
-	answers []Answer
+	ID             int64  `db:"question_id"`
+	QuizID         int64  `db:"quiz_id"`
+	Revision       int64  `db:"revision"`
+	BankQuestionID int64  `db:"bank_question_id"` // 0 if the question isn't from the question bank
+	Text           string `db:"question_text"`
+	answers        []Answer
+}
+
+// NewQuestion returns a question with the text and the answers, e.g. to build a quiz in code
+func NewQuestion(text string, answers ...Answer) Question {
+	return Question{Text: text, answers: answers}
+}
+
+func (q Question) Prompt() string {
+	return q.Text
 }
 
 func (q Question) IsAnswerCorrect(answerIndex int) bool {
@@ -75,16 +89,37 @@ type Answer struct {
 	IsCorrect  bool   `db:"is_correct"`
 	TextField  string `db:"answer_text"`
 	LaTeX      string `db:"latex"`
-	ImageName  string
-	Image      []byte
+	ImageName  string `db:"image_name"`
+	Image      []byte `db:"image"`
 }
 
+// Text returns the text shown for the answer, the LaTeX source or the image name if it has no plain text
 func (a Answer) Text() string {
-	return a.TextField
+	switch {
+	case a.TextField != "":
+		return a.TextField
+	case a.LaTeX != "":
+		return a.LaTeX
+	default:
+		return a.ImageName
+	}
 }
 
 // It is used for faster lookups if only limited data is needed
 type QuizMetadata struct {
-	ID    uint `db:"quiz_id"`
-	Title string
+	ID          uint `db:"quiz_id"`
+	Title       string
+	Description string
+	Tags        []string
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+	TimesPlayed int       `db:"times_played"`
+}
+
+// QuizRevision describes a single immutable revision of a quiz
+type QuizRevision struct {
+	QuizID    int64     `db:"quiz_id"`
+	Revision  int64     `db:"revision"`
+	Title     string    `db:"title"`
+	CreatedAt time.Time `db:"created_at"`
 }
@@ -13,7 +13,19 @@ type Repository interface {
 	Upsert(*Quiz) (int64, error)
 	Update(*Quiz) (int64, error)
 	Get(id int64) (*Quiz, error)
+	GetRevision(id int64, revision int64) (*Quiz, error)
+	GetRevisions(id int64) ([]QuizRevision, error)
+	Rollback(id int64, revision int64) (int64, error)
 	Delete(id int64) error
 	GetAll() ([]Quiz, error)
 	GetAllQuizzesMetadata() ([]QuizMetadata, error)
+	SearchQuizzes(QuizQuery) (QuizPage, error)
+	RecordPlay(id int64) error
+
+	// Question bank
+	InsertBankQuestion(*BankQuestion) (int64, error)
+	UpdateBankQuestion(*BankQuestion) error
+	GetBankQuestion(id int64) (*BankQuestion, error)
+	DeleteBankQuestion(id int64) error
+	SearchBankQuestions(BankQuery) ([]BankQuestion, error)
 }
@@ -0,0 +1,106 @@
+package quiz
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a single difference between two revisions of a quiz
+type Change struct {
+	Kind  ChangeKind
+	Field string // Human readable location of the change, e.g. "Question 2, Answer 1"
+	Old   string
+	New   string
+}
+
+// Diff returns the changes needed to get from the old quiz to the new one
+// Questions and answers are compared by their position
+func Diff(old, new Quiz) []Change {
+	var changes []Change
+
+	if old.TitleField != new.TitleField {
+		changes = append(changes, Change{ChangeModified, "Title", old.TitleField, new.TitleField})
+	}
+	if old.Description != new.Description {
+		changes = append(changes, Change{ChangeModified, "Description", old.Description, new.Description})
+	}
+
+	for i := 0; i < max(len(old.Questions), len(new.Questions)); i++ {
+		field := fmt.Sprintf("Question %d", i+1)
+		switch {
+		case i >= len(old.Questions):
+			changes = append(changes, Change{ChangeAdded, field, "", new.Questions[i].Text})
+			continue
+		case i >= len(new.Questions):
+			changes = append(changes, Change{ChangeRemoved, field, old.Questions[i].Text, ""})
+			continue
+		}
+
+		oldQst, newQst := old.Questions[i], new.Questions[i]
+		if oldQst.Text != newQst.Text {
+			changes = append(changes, Change{ChangeModified, field, oldQst.Text, newQst.Text})
+		}
+
+		for j := 0; j < max(len(oldQst.answers), len(newQst.answers)); j++ {
+			field := fmt.Sprintf("Question %d, Answer %d", i+1, j+1)
+			switch {
+			case j >= len(oldQst.answers):
+				changes = append(changes, Change{ChangeAdded, field, "", answerSummary(newQst.answers[j])})
+			case j >= len(newQst.answers):
+				changes = append(changes, Change{ChangeRemoved, field, answerSummary(oldQst.answers[j]), ""})
+			default:
+				oldAns, newAns := answerSummary(oldQst.answers[j]), answerSummary(newQst.answers[j])
+				if oldAns != newAns {
+					changes = append(changes, Change{ChangeModified, field, oldAns, newAns})
+				}
+			}
+		}
+	}
+
+	return changes
+}
+
+// sameContent reports whether storing the new quiz would leave the old revision as it is
+// Unlike Diff it also compares the password, the links to the question bank and the images
+func sameContent(old, new Quiz) bool {
+	if len(Diff(old, new)) > 0 || old.Password != new.Password {
+		return false
+	}
+	for i, oldQst := range old.Questions {
+		newQst := new.Questions[i]
+		if oldQst.BankQuestionID != newQst.BankQuestionID {
+			return false
+		}
+		for j, oldAns := range oldQst.answers {
+			newAns := newQst.answers[j]
+			if !bytes.Equal(oldAns.Image, newAns.Image) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// answerSummary returns a short description of the answer used when showing diffs
+func answerSummary(a Answer) string {
+	summary := a.TextField
+	switch {
+	case a.LaTeX != "":
+		summary = a.LaTeX
+	case a.ImageName != "":
+		summary = "image: " + a.ImageName
+	}
+
+	if a.IsCorrect {
+		summary += " (correct)"
+	}
+	return summary
+}
@@ -0,0 +1,58 @@
+package quiz
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	old := Quiz{
+		TitleField: "Capitals",
+		Questions: []Question{
+			{
+				Text: "What is the capital of France?",
+				answers: []Answer{
+					{TextField: "Paris", IsCorrect: true},
+					{TextField: "London"},
+				},
+			},
+		},
+	}
+
+	t.Run("identical quizzes", func(t *testing.T) {
+		if changes := Diff(old, old); len(changes) != 0 {
+			t.Errorf("Expected no changes, got: %+v", changes)
+		}
+	})
+
+	t.Run("modified, added and removed", func(t *testing.T) {
+		new := Quiz{
+			TitleField: "European Capitals",
+			Questions: []Question{
+				{
+					Text: "What is the capital of France?",
+					answers: []Answer{
+						{TextField: "Paris", IsCorrect: true},
+					},
+				},
+				{
+					Text:    "What is the capital of Spain?",
+					answers: []Answer{{TextField: "Madrid", IsCorrect: true}},
+				},
+			},
+		}
+
+		expected := []Change{
+			{ChangeModified, "Title", "Capitals", "European Capitals"},
+			{ChangeRemoved, "Question 1, Answer 2", "London", ""},
+			{ChangeAdded, "Question 2", "", "What is the capital of Spain?"},
+		}
+
+		changes := Diff(old, new)
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+		}
+		for i := range expected {
+			if changes[i] != expected[i] {
+				t.Errorf("Expected change %+v, got %+v", expected[i], changes[i])
+			}
+		}
+	})
+}
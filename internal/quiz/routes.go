@@ -18,11 +18,17 @@ func (s Service) NewQuizzesRouter() http.Handler {
 
 	mux.HandleFunc("GET /quizzes/{$}", s.getAllQuizzesHandler)
 	mux.HandleFunc("GET /quizzes/{qid}", s.getQuizHandler)
+	mux.HandleFunc("GET /quizzes/revisions/{qid}/{rev}", s.getQuizRevisionHandler)
+	mux.HandleFunc("POST /quizzes/rollback/{qid}/{rev}", s.rollbackQuizHandler)
 	mux.HandleFunc("POST /quizzes/create/{$}", s.postQuizHandler)
 	mux.HandleFunc("GET /quizzes/create/{$}", s.getQuizCreateHandler)
 	mux.HandleFunc("GET /quizzes/update/{qid}", s.getQuizUpdateHandler)
 	mux.HandleFunc("PUT /quizzes/update/{qid}", s.updateQuizHandler)
 	mux.HandleFunc("DELETE /quizzes/delete/{qid}", s.deleteQuizHandler)
+	mux.HandleFunc("POST /quizzes/duplicate/{qid}", s.duplicateQuizHandler)
+	mux.HandleFunc("GET /quizzes/remix/{$}", s.getQuizRemixHandler)
+	mux.HandleFunc("POST /quizzes/remix/{$}", s.postQuizRemixHandler)
+	mux.HandleFunc("GET /quizzes/remix/questions/{qid}", s.getRemixQuestionsHandler)
 
 	// HTMX question/answer CRUD endpoints for create form
 	mux.HandleFunc("POST /quizzes/create/add-question", s.addQuestionCreateHandler)
@@ -30,23 +36,64 @@ func (s Service) NewQuizzesRouter() http.Handler {
 	mux.HandleFunc("POST /quizzes/create/add-answer/{qidx}", s.addAnswerCreateHandler)
 	mux.HandleFunc("POST /quizzes/create/delete-answer/{qidx}/{aidx}", s.deleteAnswerCreateHandler)
 
+	mux.HandleFunc("POST /quizzes/create/add-bank-question/{bid}", s.addBankQuestionCreateHandler)
+
 	// HTMX question/answer CRUD endpoints for update form
 	mux.HandleFunc("POST /quizzes/update/{qid}/add-question", s.addQuestionUpdateHandler)
 	mux.HandleFunc("POST /quizzes/update/{qid}/delete-question/{qidx}", s.deleteQuestionUpdateHandler)
 	mux.HandleFunc("POST /quizzes/update/{qid}/add-answer/{qidx}", s.addAnswerUpdateHandler)
 	mux.HandleFunc("POST /quizzes/update/{qid}/delete-answer/{qidx}/{aidx}", s.deleteAnswerUpdateHandler)
+	mux.HandleFunc("POST /quizzes/update/{qid}/add-bank-question/{bid}", s.addBankQuestionUpdateHandler)
+
+	// Shared question bank
+	mux.HandleFunc("GET /quizzes/bank/{$}", s.getQuestionBankHandler)
+	mux.HandleFunc("POST /quizzes/bank/{$}", s.postBankQuestionHandler)
+	mux.HandleFunc("GET /quizzes/bank/picker", s.getBankPickerHandler)
+	mux.HandleFunc("GET /quizzes/bank/{bid}", s.getBankQuestionHandler)
+	mux.HandleFunc("PUT /quizzes/bank/{bid}", s.updateBankQuestionHandler)
+	mux.HandleFunc("DELETE /quizzes/bank/{bid}", s.deleteBankQuestionHandler)
 
 	return mux
 }
 
+// ParseQuizQuery parses the search parameters "q", "tag" (comma separated), "sort" and "page"
+func ParseQuizQuery(r *http.Request) QuizQuery {
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	return QuizQuery{
+		Text: r.FormValue("q"),
+		Tags: NormalizeTags(strings.Split(r.FormValue("tag"), ",")),
+		Sort: QuizSort(r.FormValue("sort")),
+		Page: page,
+	}
+}
+
+type quizzesData struct {
+	QuizPage
+	Tag   string // Tags of the query as entered in the search form
+	Sorts []QuizSort
+}
+
 func (s Service) getAllQuizzesHandler(w http.ResponseWriter, r *http.Request) {
 	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
-	quizzes, err := s.repo.GetAll()
+	page, err := s.repo.SearchQuizzes(ParseQuizQuery(r))
 	if err != nil {
+		slog.Error("Error searching quizzes", "err", err)
 		common.ErrorHandler(w, r, http.StatusInternalServerError)
 		return
 	}
-	err = QuizzesTemplate.Execute(w, quizzes)
+
+	data := quizzesData{
+		QuizPage: page,
+		Tag:      strings.Join(page.Query.Tags, ", "),
+		Sorts:    QuizSorts,
+	}
+
+	// Searching from the page only replaces the results
+	if r.Header.Get("HX-Request") == "true" {
+		err = QuizzesTemplate.ExecuteTemplate(w, "quiz-list", data)
+	} else {
+		err = QuizzesTemplate.Execute(w, data)
+	}
 	if err != nil {
 		slog.Error("Error rendering template", "err", err)
 	}
@@ -75,7 +122,137 @@ func (s Service) getQuizHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	err = QuizPreviewTemplate.Execute(w, quiz)
+
+	s.renderQuizPreview(w, r, quiz)
+}
+
+// getQuizRevisionHandler handles requests to /quizzes/revisions/{qid}/{rev}
+func (s Service) getQuizRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	qid, err := strconv.Atoi(r.PathValue("qid"))
+	if err != nil {
+		http.Error(w, "Invalid qid value", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		http.Error(w, "Invalid revision value", http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := s.repo.GetRevision(int64(qid), int64(rev))
+	if err != nil {
+		var errQuizNotFound ErrQuizNotFound
+		switch {
+		case errors.As(err, &errQuizNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.renderQuizPreview(w, r, quiz)
+}
+
+// rollbackQuizHandler handles requests to /quizzes/rollback/{qid}/{rev}
+// It stores the given revision as the newest revision of the quiz
+func (s Service) rollbackQuizHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	qidStr := r.PathValue("qid")
+	qid, err := strconv.Atoi(qidStr)
+	if err != nil {
+		http.Error(w, "Invalid qid value", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		http.Error(w, "Invalid revision value", http.StatusBadRequest)
+		return
+	}
+
+	newRev, err := s.repo.Rollback(int64(qid), int64(rev))
+	if err != nil {
+		var errQuizNotFound ErrQuizNotFound
+		switch {
+		case errors.As(err, &errQuizNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			slog.Error("Error rolling back quiz", "qid", qid, "revision", rev, "err", err)
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+	slog.Info("Quiz rolled back", "qid", qid, "revision", rev, "newRevision", newRev)
+
+	w.Header().Add("HX-Redirect", "/quizzes/"+qidStr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type quizPreviewData struct {
+	*Quiz
+	CurrentRevision int64
+	History         []QuizRevision
+	ComparedWith    int64    // Revision the changes are relative to, 0 for the first revision
+	Changes         []Change // Changes of the shown revision compared to ComparedWith
+}
+
+// renderQuizPreview renders the quiz together with the history of its revisions
+// The changes of the shown revision are relative to the revision of the compare query parameter,
+// by default the one before it. Only those two revisions are loaded
+func (s Service) renderQuizPreview(w http.ResponseWriter, r *http.Request, quiz *Quiz) {
+	revisions, err := s.repo.GetRevisions(quiz.ID)
+	if err != nil {
+		slog.Error("Error getting quiz revisions", "qid", quiz.ID, "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	data := quizPreviewData{
+		Quiz:    quiz,
+		History: revisions,
+	}
+	if len(revisions) > 0 {
+		data.CurrentRevision = revisions[0].Revision
+	}
+
+	if compare := r.URL.Query().Get("compare"); compare != "" {
+		data.ComparedWith, err = strconv.ParseInt(compare, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid compare value", http.StatusBadRequest)
+			return
+		}
+	} else {
+		// Revisions are ordered newest first
+		for i, revision := range revisions {
+			if revision.Revision == quiz.Revision && i+1 < len(revisions) {
+				data.ComparedWith = revisions[i+1].Revision
+			}
+		}
+	}
+
+	if data.ComparedWith != 0 {
+		compared, err := s.repo.GetRevision(quiz.ID, data.ComparedWith)
+		if err != nil {
+			var errQuizNotFound ErrQuizNotFound
+			if errors.As(err, &errQuizNotFound) {
+				common.ErrorHandler(w, r, http.StatusNotFound)
+				return
+			}
+			slog.Error("Error getting quiz revision", "qid", quiz.ID, "revision", data.ComparedWith, "err", err)
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+		data.Changes = Diff(*compared, *quiz)
+	}
+
+	err = QuizPreviewTemplate.Execute(w, data)
 	if err != nil {
 		slog.Error("Error getting quiz..", "err", err)
 	}
@@ -87,6 +264,7 @@ type createFormData struct {
 	Title        string
 	Password     string
 	Description  string
+	Tags         string // Comma separated
 	FormError    string
 	Questions    []Question
 }
@@ -126,6 +304,7 @@ func (s Service) parseQuizForm(r *http.Request) (Quiz, error) {
 	title := r.FormValue("title")
 	password := r.FormValue("password")
 	description := r.FormValue("description")
+	tags := NormalizeTags(strings.Split(r.FormValue("tags"), ","))
 
 	questions, err := s.parseQuestions(r)
 	if err != nil {
@@ -137,6 +316,7 @@ func (s Service) parseQuizForm(r *http.Request) (Quiz, error) {
 		TitleField:  title,
 		Password:    password,
 		Description: description,
+		Tags:        tags,
 		Questions:   questions,
 	}, nil
 }
@@ -151,6 +331,20 @@ func (s Service) parseQuestions(r *http.Request) ([]Question, error) {
 			break
 		}
 
+		// Questions from the bank only reference the bank, their answers are stored there
+		if bankIDStr := r.FormValue("bank-" + strconv.Itoa(questionIndex)); bankIDStr != "" {
+			bankID, err := strconv.ParseInt(bankIDStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bank question id: %v", err)
+			}
+			questions = append(questions, Question{
+				BankQuestionID: bankID,
+				Text:           questionText,
+			})
+			questionIndex++
+			continue
+		}
+
 		var answers []Answer
 		answerIndex := 1
 		for {
@@ -221,6 +415,7 @@ func (s Service) renderQuizCreateForm(w http.ResponseWriter, quiz Quiz, err erro
 		Title:       quiz.TitleField,
 		Password:    quiz.Password,
 		Description: quiz.Description,
+		Tags:        strings.Join(quiz.Tags, ", "),
 		Questions:   quiz.Questions,
 		FormError:   err.Error(),
 		ActionPrefix: "/quizzes/create",
@@ -296,6 +491,7 @@ func (s Service) getQuizUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		"Title":        quiz.TitleField,
 		"Password":     quiz.Password,
 		"Description":  quiz.Description,
+		"Tags":         strings.Join(quiz.Tags, ", "),
 	})
 	if err != nil {
 		slog.Error("Error rendering template", "err", err)
@@ -351,6 +547,420 @@ func (s Service) deleteQuizHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// duplicateQuizHandler handles requests to /quizzes/duplicate/{qid}
+// It stores a deep copy of the current revision of the quiz as a new quiz
+func (s Service) duplicateQuizHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	qid, err := strconv.Atoi(r.PathValue("qid"))
+	if err != nil {
+		http.Error(w, "Invalid qid value", http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := s.repo.Get(int64(qid))
+	if err != nil {
+		var errQuizNotFound ErrQuizNotFound
+		switch {
+		case errors.As(err, &errQuizNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	duplicate := quiz.Duplicate()
+	newID, err := s.repo.Insert(&duplicate)
+	if err != nil {
+		slog.Error("Error duplicating quiz", "qid", qid, "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Quiz duplicated", "qid", qid, "newQid", newID)
+
+	w.Header().Add("HX-Redirect", fmt.Sprintf("/quizzes/%d", newID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+type remixFormData struct {
+	Quizzes     []QuizMetadata // Questions are loaded when a quiz is opened, see getRemixQuestionsHandler
+	Title       string
+	Description string
+	FormError   string
+}
+
+// getQuizRemixHandler handles requests to /quizzes/remix/
+// It renders a builder for picking questions out of the existing quizzes
+func (s Service) getQuizRemixHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+	s.renderQuizRemixForm(w, r, remixFormData{})
+}
+
+// postQuizRemixHandler handles requests to /quizzes/remix/
+// Picked questions are sent as "pick" values in the format "<quiz-id>-<question-index>"
+func (s Service) postQuizRemixHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := remixFormData{
+		Title:       r.FormValue("title"),
+		Description: r.FormValue("description"),
+	}
+
+	var picks []QuestionPick
+	quizzes := make(map[int64]*Quiz)
+	for _, value := range r.Form["pick"] {
+		pick, err := ParseQuestionPick(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		picks = append(picks, pick)
+
+		if _, ok := quizzes[pick.QuizID]; ok {
+			continue
+		}
+		quiz, err := s.repo.Get(pick.QuizID)
+		if err != nil {
+			var errQuizNotFound ErrQuizNotFound
+			if !errors.As(err, &errQuizNotFound) {
+				common.ErrorHandler(w, r, http.StatusInternalServerError)
+				return
+			}
+		}
+		quizzes[pick.QuizID] = quiz
+	}
+
+	switch {
+	case data.Title == "":
+		data.FormError = "Title is required"
+	case len(picks) == 0:
+		data.FormError = "Pick at least one question"
+	}
+	if data.FormError != "" {
+		s.renderQuizRemixForm(w, r, data)
+		return
+	}
+
+	remix, err := Remix(data.Title, data.Description, picks, quizzes)
+	if err != nil {
+		data.FormError = err.Error()
+		s.renderQuizRemixForm(w, r, data)
+		return
+	}
+
+	newID, err := s.repo.Insert(&remix)
+	if err != nil {
+		slog.Error("Error inserting remixed quiz", "err", err)
+		data.FormError = err.Error()
+		s.renderQuizRemixForm(w, r, data)
+		return
+	}
+	slog.Info("Quiz remixed", "newQid", newID, "questions", len(picks))
+
+	w.Header().Add("HX-Redirect", fmt.Sprintf("/quizzes/%d", newID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s Service) renderQuizRemixForm(w http.ResponseWriter, r *http.Request, data remixFormData) {
+	var err error
+	data.Quizzes, err = s.repo.GetAllQuizzesMetadata()
+	if err != nil {
+		slog.Error("Error getting quizzes metadata", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	// Failed submissions only replace the form
+	if r.Method == http.MethodPost {
+		err = QuizRemixTemplate.ExecuteTemplate(w, "remix-form", data)
+	} else {
+		err = QuizRemixTemplate.Execute(w, data)
+	}
+	if err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// getRemixQuestionsHandler handles requests to /quizzes/remix/questions/{qid}
+// It renders the questions of a quiz opened in the remix builder
+func (s Service) getRemixQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	qid, err := strconv.Atoi(r.PathValue("qid"))
+	if err != nil {
+		http.Error(w, "Invalid qid value", http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := s.repo.Get(int64(qid))
+	if err != nil {
+		var errQuizNotFound ErrQuizNotFound
+		switch {
+		case errors.As(err, &errQuizNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := QuizRemixTemplate.ExecuteTemplate(w, "remix-questions", quiz); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Question bank handlers
+// ---------------------------------------------------------------------------
+// The bank question form uses the same field names as the first question of
+// the quiz form ("question-1", "answer-1-<aidx>", "correct-1"), so it is
+// parsed with parseQuestions.
+// ---------------------------------------------------------------------------
+
+// Number of answer inputs shown in the bank question form
+const bankFormAnswerSlots = 4
+
+type bankQuestionFormData struct {
+	Question     BankQuestion
+	Answers      []Answer // Answers padded to bankFormAnswerSlots
+	Difficulties []Difficulty
+	FormError    string
+}
+
+func newBankQuestionFormData(question BankQuestion, formErr error) bankQuestionFormData {
+	data := bankQuestionFormData{
+		Question:     question,
+		Answers:      append([]Answer{}, question.answers...),
+		Difficulties: Difficulties,
+	}
+	for len(data.Answers) < bankFormAnswerSlots {
+		data.Answers = append(data.Answers, Answer{})
+	}
+	if formErr != nil {
+		data.FormError = formErr.Error()
+	}
+	return data
+}
+
+type questionBankData struct {
+	Query        string
+	Tag          string
+	Difficulty   Difficulty
+	Difficulties []Difficulty
+	Questions    []BankQuestion
+	Form         bankQuestionFormData
+}
+
+// parseBankQuery parses the search parameters "q", "tag" (comma separated) and "difficulty"
+func parseBankQuery(r *http.Request) BankQuery {
+	return BankQuery{
+		Text:       r.FormValue("q"),
+		Tags:       NormalizeTags(strings.Split(r.FormValue("tag"), ",")),
+		Difficulty: Difficulty(r.FormValue("difficulty")),
+	}
+}
+
+// getQuestionBankHandler handles requests to /quizzes/bank/
+func (s Service) getQuestionBankHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	query := parseBankQuery(r)
+	questions, err := s.repo.SearchBankQuestions(query)
+	if err != nil {
+		slog.Error("Error searching question bank", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	err = QuestionBankTemplate.Execute(w, questionBankData{
+		Query:        query.Text,
+		Tag:          r.FormValue("tag"),
+		Difficulty:   query.Difficulty,
+		Difficulties: Difficulties,
+		Questions:    questions,
+		Form:         newBankQuestionFormData(BankQuestion{}, nil),
+	})
+	if err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// getBankPickerHandler handles requests to /quizzes/bank/picker
+// It renders bank questions matching the search, which can be added to the quiz form
+func (s Service) getBankPickerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	questions, err := s.repo.SearchBankQuestions(parseBankQuery(r))
+	if err != nil {
+		slog.Error("Error searching question bank", "err", err)
+		common.ErrorHandler(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	err = QuestionListTmpl.ExecuteTemplate(w, "bank-picker-results", BankPickerData{
+		Questions:    questions,
+		ActionPrefix: r.FormValue("prefix"),
+	})
+	if err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// parseBankQuestionForm parses the bank question form, the ID is taken from the path if present
+func (s Service) parseBankQuestionForm(r *http.Request) (BankQuestion, error) {
+	var question BankQuestion
+
+	if bidStr := r.PathValue("bid"); bidStr != "" {
+		bid, err := strconv.ParseInt(bidStr, 10, 64)
+		if err != nil {
+			return question, fmt.Errorf("invalid bank question ID")
+		}
+		question.ID = bid
+	}
+
+	question.Difficulty = Difficulty(r.FormValue("difficulty"))
+	question.Tags = NormalizeTags(strings.Split(r.FormValue("tags"), ","))
+
+	questions, err := s.parseQuestions(r)
+	if err != nil {
+		return question, err
+	}
+	if len(questions) > 0 {
+		question.Text = questions[0].Text
+		question.answers = questions[0].answers
+	}
+
+	switch {
+	case question.Text == "":
+		return question, errors.New("question text is required")
+	case len(question.answers) == 0:
+		return question, errors.New("at least one answer is required")
+	case !question.Difficulty.IsValid():
+		return question, fmt.Errorf("invalid difficulty: %s", question.Difficulty)
+	}
+
+	return question, nil
+}
+
+// renderBankQuestionForm renders only the bank question form, used after failed submissions
+func (s Service) renderBankQuestionForm(w http.ResponseWriter, question BankQuestion, formErr error) {
+	err := BankQuestionTemplate.ExecuteTemplate(w, "bank-question-form", newBankQuestionFormData(question, formErr))
+	if err != nil {
+		slog.Error("Error rendering template", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// postBankQuestionHandler handles requests to /quizzes/bank/
+func (s Service) postBankQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	question, err := s.parseBankQuestionForm(r)
+	if err != nil {
+		s.renderBankQuestionForm(w, question, err)
+		return
+	}
+
+	if _, err := s.repo.InsertBankQuestion(&question); err != nil {
+		slog.Error("Error adding bank question", "err", err)
+		s.renderBankQuestionForm(w, question, err)
+		return
+	}
+	slog.Info("Bank question created", "bid", question.ID)
+
+	w.Header().Add("HX-Redirect", "/quizzes/bank/")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// getBankQuestionHandler handles requests to /quizzes/bank/{bid}
+func (s Service) getBankQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	bid, err := strconv.Atoi(r.PathValue("bid"))
+	if err != nil {
+		http.Error(w, "Invalid bid value", http.StatusBadRequest)
+		return
+	}
+
+	question, err := s.repo.GetBankQuestion(int64(bid))
+	if err != nil {
+		var errNotFound ErrBankQuestionNotFound
+		switch {
+		case errors.As(err, &errNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := BankQuestionTemplate.Execute(w, newBankQuestionFormData(*question, nil)); err != nil {
+		slog.Error("Error rendering template", "err", err)
+	}
+}
+
+// updateBankQuestionHandler handles requests to /quizzes/bank/{bid}
+// The update is visible in every quiz using the question
+func (s Service) updateBankQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	question, err := s.parseBankQuestionForm(r)
+	if err != nil {
+		s.renderBankQuestionForm(w, question, err)
+		return
+	}
+
+	if err := s.repo.UpdateBankQuestion(&question); err != nil {
+		var errNotFound ErrBankQuestionNotFound
+		if errors.As(err, &errNotFound) {
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		}
+		slog.Error("Error updating bank question", "bid", question.ID, "err", err)
+		s.renderBankQuestionForm(w, question, err)
+		return
+	}
+	slog.Info("Bank question updated", "bid", question.ID)
+
+	w.Header().Add("HX-Redirect", "/quizzes/bank/")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteBankQuestionHandler handles requests to /quizzes/bank/{bid}
+func (s Service) deleteBankQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path)
+
+	bid, err := strconv.Atoi(r.PathValue("bid"))
+	if err != nil {
+		http.Error(w, "Invalid bid value", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.repo.DeleteBankQuestion(int64(bid)); err != nil {
+		var errNotFound ErrBankQuestionNotFound
+		switch {
+		case errors.As(err, &errNotFound):
+			common.ErrorHandler(w, r, http.StatusNotFound)
+			return
+		default:
+			slog.Error("Error deleting bank question", "bid", bid, "err", err)
+			common.ErrorHandler(w, r, http.StatusInternalServerError)
+			return
+		}
+	}
+	slog.Info("Bank question deleted", "bid", bid)
+
+	w.Header().Add("HX-Redirect", "/quizzes/bank/")
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ---------------------------------------------------------------------------
 // HTMX question/answer CRUD handlers
 // ---------------------------------------------------------------------------
@@ -454,6 +1064,30 @@ func (s Service) deleteAnswerUpdateHandler(w http.ResponseWriter, r *http.Reques
 	s.renderQuestionList(w, questions, "/quizzes/update/"+r.PathValue("qid"))
 }
 
+func (s Service) addBankQuestionCreateHandler(w http.ResponseWriter, r *http.Request) {
+	s.addBankQuestion(w, r, "/quizzes/create")
+}
+
+func (s Service) addBankQuestionUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	s.addBankQuestion(w, r, "/quizzes/update/"+r.PathValue("qid"))
+}
+
+// addBankQuestion appends a reference to the bank question {bid} to the questions in the form
+func (s Service) addBankQuestion(w http.ResponseWriter, r *http.Request, actionPrefix string) {
+	bid := parseIntPathValue(r, "bid")
+	bankQst, err := s.repo.GetBankQuestion(int64(bid))
+	if err != nil {
+		slog.Error("Error getting bank question", "bid", bid, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	questions := s.mutateQuestions(r, func(qs []Question) []Question {
+		return append(qs, bankQst.Question())
+	})
+	s.renderQuestionList(w, questions, actionPrefix)
+}
+
 // ---------------------------------------------------------------------------
 // Shared helpers
 // ---------------------------------------------------------------------------
@@ -0,0 +1,99 @@
+package quiz
+
+import "strings"
+
+type QuizSort string
+
+const (
+	QuizSortRelevance  QuizSort = ""        // Best text match first, newest first when there is no text
+	QuizSortCreated    QuizSort = "created" // Newest first
+	QuizSortUpdated    QuizSort = "updated" // Most recently updated first
+	QuizSortMostPlayed QuizSort = "played"  // Most played first
+	QuizSortTitle      QuizSort = "title"   // Alphabetically
+)
+
+var QuizSorts = []QuizSort{QuizSortRelevance, QuizSortCreated, QuizSortUpdated, QuizSortMostPlayed, QuizSortTitle}
+
+func (s QuizSort) IsValid() bool {
+	for _, sort := range QuizSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the human readable name of the sort
+func (s QuizSort) Label() string {
+	switch s {
+	case QuizSortCreated:
+		return "Newest"
+	case QuizSortUpdated:
+		return "Recently updated"
+	case QuizSortMostPlayed:
+		return "Most played"
+	case QuizSortTitle:
+		return "Title"
+	default:
+		return "Relevance"
+	}
+}
+
+const DefaultQuizzesPerPage = 20
+
+// QuizQuery is used for searching quizzes, empty fields are ignored
+type QuizQuery struct {
+	Text    string   // Matches title, description and question texts
+	Tags    []string // Quizzes need to have all of the tags
+	Sort    QuizSort
+	Page    int // First page is of index 1
+	PerPage int // DefaultQuizzesPerPage is used if not set
+}
+
+// normalize returns the query with defaults applied
+func (q QuizQuery) normalize() QuizQuery {
+	q.Text = strings.TrimSpace(q.Text)
+	q.Tags = NormalizeTags(q.Tags)
+	if !q.Sort.IsValid() {
+		q.Sort = QuizSortRelevance
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultQuizzesPerPage
+	}
+	return q
+}
+
+// QuizPage is a single page of quizzes matching a QuizQuery
+type QuizPage struct {
+	Quizzes []QuizMetadata
+	Query   QuizQuery // Query with defaults applied
+	Total   int       // Number of matching quizzes on all pages
+}
+
+func (p QuizPage) Pages() int {
+	if p.Query.PerPage < 1 {
+		return 1
+	}
+	return max(1, (p.Total+p.Query.PerPage-1)/p.Query.PerPage)
+}
+
+func (p QuizPage) HasPrev() bool { return p.Query.Page > 1 }
+
+func (p QuizPage) HasNext() bool { return p.Query.Page < p.Pages() }
+
+func (p QuizPage) PrevPage() int { return p.Query.Page - 1 }
+
+func (p QuizPage) NextPage() int { return p.Query.Page + 1 }
+
+// ftsMatch converts user input into an FTS5 match expression
+// Every word is quoted (so FTS5 operators can't be injected) and matched as a prefix
+func ftsMatch(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+	return strings.Join(words, " ")
+}
@@ -1,8 +1,14 @@
 package quiz
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/erykksc/kwikquiz/internal/common"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -11,7 +17,8 @@ type RepositorySQLite struct {
 }
 
 type repositorySQLite struct {
-	db *sqlx.DB
+	db  *sqlx.DB
+	fts bool // Whether the quiz_fts (FTS5) search index is available
 }
 
 func NewRepositorySQLite(db *sqlx.DB) (RepositorySQLite, error) {
@@ -30,13 +37,27 @@ func (repo *repositorySQLite) createTables() error {
 		quiz_id     INTEGER PRIMARY KEY,
 		title       TEXT,
 		password    TEXT,
-		description TEXT
+		description TEXT,
+		revision    INTEGER NOT NULL DEFAULT 1,
+		deleted_at  DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS quiz_revision (
+		quiz_id     INTEGER REFERENCES quiz(quiz_id) ON DELETE CASCADE,
+		revision    INTEGER,
+		title       TEXT,
+		password    TEXT,
+		description TEXT,
+		created_at  DATETIME,
+		PRIMARY KEY (quiz_id, revision)
 	);
 
 	CREATE TABLE IF NOT EXISTS question (
-		question_id   INTEGER PRIMARY KEY,
-		quiz_id       INTEGER REFERENCES quiz(quiz_id) ON DELETE CASCADE,
-		question_text TEXT
+		question_id      INTEGER PRIMARY KEY,
+		quiz_id          INTEGER REFERENCES quiz(quiz_id) ON DELETE CASCADE,
+		revision         INTEGER NOT NULL DEFAULT 1,
+		bank_question_id INTEGER NOT NULL DEFAULT 0,
+		question_text    TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS answer (
@@ -44,53 +65,244 @@ func (repo *repositorySQLite) createTables() error {
 		question_id INTEGER REFERENCES question(question_id) ON DELETE CASCADE,
 		is_correct  INTEGER,
 		answer_text TEXT,
-		latex       TEXT
+		latex       TEXT,
+		image_name  TEXT NOT NULL DEFAULT '',
+		image       BLOB
+	);
+
+	CREATE TABLE IF NOT EXISTS bank_question (
+		bank_question_id INTEGER PRIMARY KEY,
+		question_text    TEXT,
+		difficulty       TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS bank_answer (
+		answer_id   INTEGER PRIMARY KEY,
+		question_id INTEGER REFERENCES bank_question(bank_question_id) ON DELETE CASCADE,
+		is_correct  INTEGER,
+		answer_text TEXT,
+		latex       TEXT,
+		image_name  TEXT NOT NULL DEFAULT '',
+		image       BLOB
+	);
+
+	CREATE TABLE IF NOT EXISTS bank_question_tag (
+		bank_question_id INTEGER REFERENCES bank_question(bank_question_id) ON DELETE CASCADE,
+		tag              TEXT,
+		PRIMARY KEY (bank_question_id, tag)
 	);
+
+	CREATE INDEX IF NOT EXISTS idx_bank_question_tag_tag ON bank_question_tag(tag);
+
+	CREATE TABLE IF NOT EXISTS quiz_tag (
+		quiz_id INTEGER REFERENCES quiz(quiz_id) ON DELETE CASCADE,
+		tag     TEXT,
+		PRIMARY KEY (quiz_id, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_quiz_tag_tag ON quiz_tag(tag);
+
+	CREATE TABLE IF NOT EXISTS quiz_play (
+		quiz_id   INTEGER REFERENCES quiz(quiz_id) ON DELETE CASCADE,
+		played_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_quiz_play_quiz_id ON quiz_play(quiz_id);
 	`
 
 	_, err := repo.db.Exec(schema)
-	return err
-}
+	if err != nil {
+		return err
+	}
 
-func (repo *repositorySQLite) Insert(quiz *Quiz) (int64, error) {
-	if quiz == nil {
-		return 0, errors.New("quiz is nil")
+	// Databases created before quiz revisions existed lack the revision columns
+	if err := common.AddColumnIfMissing(repo.db, "quiz", "revision", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "question", "revision", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "quiz", "deleted_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "question", "bank_question_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err = repo.db.Exec("CREATE INDEX IF NOT EXISTS idx_question_bank_question_id ON question(bank_question_id)")
+	if err != nil {
+		return err
+	}
+	// Same for the answer images, which weren't stored before quizzes could be duplicated
+	if err := common.AddColumnIfMissing(repo.db, "answer", "image_name", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := common.AddColumnIfMissing(repo.db, "answer", "image", "BLOB"); err != nil {
+		return err
 	}
 
-	tx, err := repo.db.Beginx()
+	// Revisions stored before bank questions were copied into them only reference the bank,
+	// they get the current content of the bank question as the best approximation.
+	// It runs only once, later revisions may have bank questions without answers on purpose
+	err = common.RunMigrationOnce(repo.db, "quiz_copy_bank_answers", func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO answer (question_id, is_correct, answer_text, latex, image_name, image)
+			SELECT question.question_id, bank_answer.is_correct, bank_answer.answer_text, bank_answer.latex,
+				bank_answer.image_name, bank_answer.image
+			FROM question
+			JOIN bank_answer ON bank_answer.question_id = question.bank_question_id
+			WHERE question.bank_question_id != 0
+			AND NOT EXISTS (SELECT 1 FROM answer WHERE answer.question_id = question.question_id)
+			ORDER BY question.question_id, bank_answer.answer_id
+		`)
+		return err
+	})
 	if err != nil {
-		return 0, err
+		return err
 	}
-	// Rollback if no tx.Commit (if there is commit, this is no-op)
-	defer tx.Rollback() //nolint
 
-	// Insert the game
-	res, err := tx.NamedExec(`
-        INSERT INTO quiz (title, password, description)
-		VALUES (:title, :password, :description)
-    `, &quiz)
+	// Every quiz should have its current revision recorded in the history
+	_, err = repo.db.Exec(`
+		INSERT OR IGNORE INTO quiz_revision (quiz_id, revision, title, password, description, created_at)
+		SELECT quiz_id, revision, title, password, description, ?
+		FROM quiz
+	`, time.Now())
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	insertedQuizID, err := res.LastInsertId()
+	return repo.createSearchIndex()
+}
+
+// createSearchIndex creates and fills the full-text search index of quizzes
+// FTS5 is only available if the binary is built with the sqlite_fts5 tag,
+// without it searching falls back to (slower) LIKE queries
+func (repo *repositorySQLite) createSearchIndex() error {
+	_, err := repo.db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS quiz_fts USING fts5(title, description, questions)
+	`)
 	if err != nil {
-		return 0, err
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			slog.Warn("FTS5 is not available, quiz search falls back to LIKE queries")
+			return nil
+		}
+		return err
+	}
+	repo.fts = true
+
+	// The index is rebuilt on every start, so it never gets out of sync for long
+	if _, err := repo.db.Exec("DELETE FROM quiz_fts"); err != nil {
+		return err
+	}
+	_, err = repo.db.Exec(ftsIndexQuery + " FROM quiz WHERE deleted_at IS NULL")
+	return err
+}
+
+// ftsIndexQuery inserts quizzes into the search index, needs to be followed by a FROM clause
+// The questions column holds the texts of all questions of the current revision
+const ftsIndexQuery = `
+	INSERT INTO quiz_fts (rowid, title, description, questions)
+	SELECT
+		quiz.quiz_id,
+		COALESCE(quiz.title, ''),
+		COALESCE(quiz.description, ''),
+		COALESCE((
+			SELECT group_concat(question.question_text, ' ')
+			FROM question
+			WHERE question.quiz_id = quiz.quiz_id AND question.revision = quiz.revision
+		), '')
+`
+
+// indexQuizzes updates the search index of the given quizzes
+// It is a no-op if FTS5 isn't available
+func (repo *repositorySQLite) indexQuizzes(tx *sqlx.Tx, quizIDs ...int64) error {
+	if !repo.fts {
+		return nil
 	}
 
-	// Insert all questions
-	for _, question := range quiz.Questions {
+	for _, id := range quizIDs {
+		if _, err := tx.Exec("DELETE FROM quiz_fts WHERE rowid = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ftsIndexQuery+" FROM quiz WHERE quiz_id = ? AND deleted_at IS NULL", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTags replaces the tags of the quiz
+// Tags aren't part of quiz revisions, they always describe the quiz as a whole
+func insertTags(tx *sqlx.Tx, quizID int64, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM quiz_tag WHERE quiz_id = ?", quizID); err != nil {
+		return err
+	}
+
+	for _, tag := range NormalizeTags(tags) {
+		_, err := tx.Exec("INSERT INTO quiz_tag (quiz_id, tag) VALUES (?, ?)", quizID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getTags returns the tags of the quiz, sorted alphabetically
+func getTags(q sqlx.Queryer, quizID int64) ([]string, error) {
+	tags := []string{}
+	err := sqlx.Select(q, &tags, "SELECT tag FROM quiz_tag WHERE quiz_id = ? ORDER BY tag", quizID)
+	return tags, err
+}
+
+// insertRevision records the quiz fields and questions as the given revision of the quiz
+// With refreshBank the questions from the bank get its current content, see insertQuestions
+// Thread unsafe, should be run inside a transaction
+func insertRevision(tx *sqlx.Tx, quiz *Quiz, revision int64, refreshBank bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO quiz_revision (quiz_id, revision, title, password, description, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, quiz.ID, revision, quiz.TitleField, quiz.Password, quiz.Description, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return insertQuestions(tx, quiz.ID, revision, quiz.Questions, refreshBank)
+}
+
+// insertQuestions inserts the questions with their answers for the given quiz revision
+// With refreshBank questions from the bank are stored with the current content of the bank question,
+// so the revision doesn't change when the bank question does. Without it they are stored as they are,
+// e.g. when an older revision is restored
+func insertQuestions(tx *sqlx.Tx, quizID, revision int64, questions []Question, refreshBank bool) error {
+	for _, question := range questions {
+		if question.BankQuestionID != 0 && refreshBank {
+			bankQst, err := getBankQuestion(tx, question.BankQuestionID)
+			switch {
+			case errors.Is(err, ErrBankQuestionNotFound{}):
+				// Keep the content the question came with
+				question.BankQuestionID = 0
+			case err != nil:
+				return err
+			default:
+				question.Text = bankQst.Text
+				question.answers = bankQst.Question().answers
+			}
+		}
+
 		res, err := tx.Exec(`
-			INSERT INTO question (quiz_id, question_text)
-			VALUES (?, ?)
-		`, insertedQuizID, question.Text)
+			INSERT INTO question (quiz_id, revision, bank_question_id, question_text)
+			VALUES (?, ?, ?, ?)
+		`, quizID, revision, question.BankQuestionID, question.Text)
 		if err != nil {
-			return 0, err
+			return err
+		}
+
+		if len(question.answers) == 0 {
+			continue
 		}
 
 		insertedQuestionID, err := res.LastInsertId()
 		if err != nil {
-			return 0, err
+			return err
 		}
 
 		for i := range question.answers {
@@ -98,83 +310,126 @@ func (repo *repositorySQLite) Insert(quiz *Quiz) (int64, error) {
 		}
 
 		_, err = tx.NamedExec(`
-			INSERT INTO answer (question_id, is_correct, answer_text, latex)
-			VALUES (:question_id, :is_correct, :answer_text, :latex)
+			INSERT INTO answer (question_id, is_correct, answer_text, latex, image_name, image)
+			VALUES (:question_id, :is_correct, :answer_text, :latex, :image_name, :image)
 		`, question.answers)
 		if err != nil {
-			return 0, err
+			return err
 		}
 	}
-
-	err = tx.Commit()
-
-	return insertedQuizID, err
+	return nil
 }
-func (repo *repositorySQLite) Upsert(quiz *Quiz) (int64, error) {
+
+func (repo *repositorySQLite) Insert(quiz *Quiz) (int64, error) {
 	if quiz == nil {
 		return 0, errors.New("quiz is nil")
 	}
+
 	tx, err := repo.db.Beginx()
 	if err != nil {
 		return 0, err
 	}
 	// Rollback if no tx.Commit (if there is commit, this is no-op)
-	defer tx.Rollback() // nolint
+	defer tx.Rollback() //nolint
 
 	// Insert the game
-	_, err = tx.NamedExec(`
-		INSERT INTO quiz (quiz_id, title, password, description)
-		VALUES (:quiz_id, :title, :password, :description)
-		ON CONFLICT(quiz_id) DO UPDATE SET
-		title = EXCLUDED.title,
-		password = EXCLUDED.password,
-		description = EXCLUDED.description
-	`, &quiz)
+	res, err := tx.NamedExec(`
+        INSERT INTO quiz (title, password, description, revision)
+		VALUES (:title, :password, :description, 1)
+    `, &quiz)
 	if err != nil {
 		return 0, err
 	}
 
-	// Remove all questions (and answers because of CASCADE)
-	_, err = tx.NamedExec(`
-		DELETE FROM question WHERE quiz_id = :quiz_id
-	`, &quiz)
+	insertedQuizID, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	// Insert all questions
-	for _, question := range quiz.Questions {
-		res, err := tx.Exec(`
-			INSERT INTO question (quiz_id, question_text)
-			VALUES (?, ?)
-		`, quiz.ID, question.Text)
-		if err != nil {
-			return 0, err
-		}
+	inserted := *quiz
+	inserted.ID = insertedQuizID
+	if err := insertRevision(tx, &inserted, 1, true); err != nil {
+		return 0, err
+	}
 
-		insertedQuestionID, err := res.LastInsertId()
+	if err := insertTags(tx, insertedQuizID, quiz.Tags); err != nil {
+		return 0, err
+	}
+
+	if err := repo.indexQuizzes(tx, insertedQuizID); err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err == nil {
+		quiz.Revision = 1
+	}
+
+	return insertedQuizID, err
+}
+
+// Upsert inserts the quiz with its ID or stores it as a new revision if its content changed
+// Nothing is stored if it is the same as the current revision, revisions are never changed.
+// A deleted quiz is restored
+func (repo *repositorySQLite) Upsert(quiz *Quiz) (int64, error) {
+	if quiz == nil {
+		return 0, errors.New("quiz is nil")
+	}
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() // nolint
+
+	var current Quiz
+	err = tx.Get(&current, "SELECT quiz_id, title, password, description, revision FROM quiz WHERE quiz_id = ?", quiz.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = tx.NamedExec(`
+			INSERT INTO quiz (quiz_id, title, password, description, revision)
+			VALUES (:quiz_id, :title, :password, :description, 1)
+		`, quiz)
 		if err != nil {
 			return 0, err
 		}
-
-		for i := range question.answers {
-			question.answers[i].QuestionID = insertedQuestionID
+		current.Revision = 1
+		err = insertRevision(tx, quiz, current.Revision, true)
+	case err != nil:
+		return 0, err
+	default:
+		if _, err := tx.Exec("UPDATE quiz SET deleted_at = NULL WHERE quiz_id = ?", quiz.ID); err != nil {
+			return 0, err
 		}
-
-		_, err = tx.NamedExec(`
-			INSERT INTO answer (question_id, is_correct, answer_text, latex)
-			VALUES (:question_id, :is_correct, :answer_text, :latex)
-		`, question.answers)
+		current.Questions, err = getQuestions(tx, quiz.ID, current.Revision)
 		if err != nil {
 			return 0, err
 		}
+		if !sameContent(current, *quiz) {
+			current.Revision, err = insertNewRevision(tx, quiz, true)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertTags(tx, quiz.ID, quiz.Tags); err != nil {
+		return 0, err
+	}
+
+	if err := repo.indexQuizzes(tx, quiz.ID); err != nil {
+		return 0, err
 	}
 
 	err = tx.Commit()
+	if err == nil {
+		quiz.Revision = current.Revision
+	}
 
 	return quiz.ID, err
 }
 
+// Update stores the quiz as a new revision, previous revisions are kept unchanged
 func (repo *repositorySQLite) Update(quiz *Quiz) (int64, error) {
 	if quiz == nil {
 		return 0, errors.New("quiz is nil")
@@ -186,81 +441,189 @@ func (repo *repositorySQLite) Update(quiz *Quiz) (int64, error) {
 	// Rollback if no tx.Commit (if there is commit, this is no-op)
 	defer tx.Rollback() //nolint
 
-	// Update the quiz row
-	_, err = tx.NamedExec(`
-		UPDATE quiz SET
-		title = :title,
-		password = :password,
-		description = :description
-		WHERE quiz_id = :quiz_id
-	`, &quiz)
+	revision, err := insertNewRevision(tx, quiz, true)
 	if err != nil {
 		return 0, err
 	}
 
-	// Remove all questions (and answers because of CASCADE)
-	_, err = tx.Exec(`
-		DELETE FROM question WHERE quiz_id = ?
-	`, quiz.ID)
-	if err != nil {
+	if err := insertTags(tx, quiz.ID, quiz.Tags); err != nil {
 		return 0, err
 	}
 
-	// Insert all questions
-	for _, question := range quiz.Questions {
-		res, err := tx.Exec(`
-			INSERT INTO question (quiz_id, question_text)
-			VALUES (?, ?)
-		`, quiz.ID, question.Text)
-		if err != nil {
-			return 0, err
-		}
+	if err := repo.indexQuizzes(tx, quiz.ID); err != nil {
+		return 0, err
+	}
 
-		insertedQuestionID, err := res.LastInsertId()
-		if err != nil {
-			return 0, err
-		}
+	err = tx.Commit()
+	if err == nil {
+		quiz.Revision = revision
+	}
 
-		for i := range question.answers {
-			question.answers[i].QuestionID = insertedQuestionID
-		}
+	return quiz.ID, err
+}
 
-		_, err = tx.NamedExec(`
-			INSERT INTO answer (question_id, is_correct, answer_text, latex)
-			VALUES (:question_id, :is_correct, :answer_text, :latex)
-		`, question.answers)
-		if err != nil {
-			return 0, err
-		}
+// insertNewRevision stores the quiz as its next revision and makes it the current one
+// Returns the number of the new revision, thread unsafe, should be run inside a transaction
+func insertNewRevision(tx *sqlx.Tx, quiz *Quiz, refreshBank bool) (int64, error) {
+	var revision int64
+	err := tx.Get(&revision, `
+		SELECT COALESCE(MAX(quiz_revision.revision), 0)
+		FROM quiz_revision
+		JOIN quiz ON quiz.quiz_id = quiz_revision.quiz_id
+		WHERE quiz_revision.quiz_id = ? AND quiz.deleted_at IS NULL
+	`, quiz.ID)
+	if err != nil {
+		return 0, err
 	}
+	if revision == 0 {
+		return 0, ErrQuizNotFound{}
+	}
+	revision++
 
-	err = tx.Commit()
+	// Update the quiz row to point to the new revision
+	_, err = tx.Exec(`
+		UPDATE quiz SET
+		title = ?,
+		password = ?,
+		description = ?,
+		revision = ?
+		WHERE quiz_id = ?
+	`, quiz.TitleField, quiz.Password, quiz.Description, revision, quiz.ID)
+	if err != nil {
+		return 0, err
+	}
 
-	return quiz.ID, err
+	return revision, insertRevision(tx, quiz, revision, refreshBank)
 }
 
+// Get returns the current revision of the quiz, deleted quizzes aren't found
 func (repo *repositorySQLite) Get(id int64) (*Quiz, error) {
-	query := "SELECT * FROM quiz WHERE quiz_id = ?"
+	query := "SELECT quiz_id, title, password, description, revision FROM quiz WHERE quiz_id = ? AND deleted_at IS NULL"
 	var quiz Quiz
 	err := repo.db.Get(&quiz, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQuizNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	quiz.Questions, err = getQuestions(repo.db, id, quiz.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	quiz.Tags, err = getTags(repo.db, id)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
-	// Hydrate the quiz Questions (the answers should also be hydrated)
-	query = `
-		SELECT question.*, answer.*
+	return &quiz, nil
+}
+
+// GetRevision returns the quiz as it was at the given revision
+// Revisions of deleted quizzes are kept, past games and assignments are pinned to them
+func (repo *repositorySQLite) GetRevision(id int64, revision int64) (*Quiz, error) {
+	query := `
+		SELECT quiz_id, revision, title, password, description
+		FROM quiz_revision
+		WHERE quiz_id = ? AND revision = ?
+	`
+	var quiz Quiz
+	err := repo.db.Get(&quiz, query, id, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQuizNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	quiz.Questions, err = getQuestions(repo.db, id, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tags aren't versioned, the current ones are returned
+	quiz.Tags, err = getTags(repo.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &quiz, nil
+}
+
+// GetRevisions returns the metadata of all revisions of the quiz, newest first
+func (repo *repositorySQLite) GetRevisions(id int64) ([]QuizRevision, error) {
+	query := `
+		SELECT quiz_id, revision, title, created_at
+		FROM quiz_revision
+		WHERE quiz_id = ?
+		ORDER BY revision DESC
+	`
+	var revisions []QuizRevision
+	err := repo.db.Select(&revisions, query, id)
+	return revisions, err
+}
+
+// Rollback stores the given revision as a new revision of the quiz
+// Questions from the bank keep the content they had in the revision
+// Returns the number of the newly created revision
+func (repo *repositorySQLite) Rollback(id int64, revision int64) (int64, error) {
+	quiz, err := repo.GetRevision(id, revision)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	newRevision, err := insertNewRevision(tx, quiz, false)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := repo.indexQuizzes(tx, quiz.ID); err != nil {
+		return 0, err
+	}
+
+	return newRevision, tx.Commit()
+}
+
+// getQuestions returns the hydrated questions (with answers) of a quiz revision
+// Questions from the question bank have the content they had when the revision was stored
+// It accepts a transaction as well, so it can be used while one is running
+func getQuestions(q sqlx.Queryer, quizID int64, revision int64) ([]Question, error) {
+	query := `
+		SELECT
+			question.question_id,
+			question.quiz_id,
+			question.revision,
+			question.bank_question_id,
+			question.question_text,
+			COALESCE(answer.answer_id, 0) AS answer_id,
+			COALESCE(answer.is_correct, 0) AS is_correct,
+			COALESCE(answer.answer_text, '') AS answer_text,
+			COALESCE(answer.latex, '') AS latex,
+			COALESCE(answer.image_name, '') AS image_name,
+			answer.image
 		FROM question
 		LEFT JOIN answer
 		ON question.question_id = answer.question_id
-		WHERE question.quiz_id = ?
+		WHERE question.quiz_id = ? AND question.revision = ?
 		ORDER BY question.question_id, answer.answer_id
 	`
-	rows, err := repo.db.Queryx(query, id)
+	rows, err := q.Queryx(query, quizID, revision)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
 	// Scan join query result
+	var questions []Question
 	var currentQst *Question
 	for rows.Next() {
 		type Result struct {
@@ -278,36 +641,488 @@ func (repo *repositorySQLite) Get(id int64) (*Quiz, error) {
 		}
 
 		if res.Question.ID != currentQst.ID {
-			quiz.Questions = append(quiz.Questions, *currentQst)
+			questions = append(questions, *currentQst)
 			currentQst = res.Question
 		}
 
-		currentQst.answers = append(currentQst.answers, res.Answer)
+		// Questions without answers have a NULL answer
+		if res.Answer.ID != 0 {
+			res.Answer.QuestionID = currentQst.ID
+			currentQst.answers = append(currentQst.answers, res.Answer)
+		}
 	}
 	if currentQst != nil {
-		quiz.Questions = append(quiz.Questions, *currentQst)
+		questions = append(questions, *currentQst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return &quiz, err
+	return questions, nil
 }
 
+// Delete hides the quiz from the lists and from Get
+// Its revisions are kept, past games and assignments can still be replayed and closed with them
 func (repo *repositorySQLite) Delete(id int64) error {
-	query := "DELETE FROM quiz WHERE quiz_id=?"
-	_, err := repo.db.Exec(query, id)
+	query := "UPDATE quiz SET deleted_at = ? WHERE quiz_id = ? AND deleted_at IS NULL"
+	res, err := repo.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrQuizNotFound{}
+		}
+		return err
+	}
+	if !repo.fts {
+		return nil
+	}
+
+	_, err = repo.db.Exec("DELETE FROM quiz_fts WHERE rowid = ?", id)
 	return err
 }
 
 // NOTE: This function will return unhydrated Quizzes
 func (repo *repositorySQLite) GetAll() ([]Quiz, error) {
-	query := "SELECT * FROM quiz"
+	query := "SELECT quiz_id, title, password, description, revision FROM quiz WHERE deleted_at IS NULL"
 	var quizzes []Quiz
 	err := repo.db.Select(&quizzes, query)
 	return quizzes, err
 }
 
 func (repo *repositorySQLite) GetAllQuizzesMetadata() ([]QuizMetadata, error) {
-	query := "SELECT quiz_id, title FROM quiz"
+	query := "SELECT quiz_id, title FROM quiz WHERE deleted_at IS NULL"
 	var quizzes []QuizMetadata
 	err := repo.db.Select(&quizzes, query)
 	return quizzes, err
 }
+
+// RecordPlay records that a game with the quiz has been played
+func (repo *repositorySQLite) RecordPlay(id int64) error {
+	_, err := repo.db.Exec("INSERT INTO quiz_play (quiz_id, played_at) VALUES (?, ?)", id, time.Now())
+	return err
+}
+
+// likePattern returns the LIKE pattern matching the text anywhere, its wildcards are escaped with \
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// SearchQuizzes returns a page of metadata of quizzes matching the query
+func (repo *repositorySQLite) SearchQuizzes(query QuizQuery) (QuizPage, error) {
+	query = query.normalize()
+	page := QuizPage{Query: query, Quizzes: []QuizMetadata{}}
+
+	from := `
+		FROM quiz
+		JOIN quiz_revision AS created ON created.quiz_id = quiz.quiz_id
+			AND created.revision = (SELECT MIN(revision) FROM quiz_revision WHERE quiz_id = quiz.quiz_id)
+		JOIN quiz_revision AS updated ON updated.quiz_id = quiz.quiz_id
+			AND updated.revision = quiz.revision`
+	where := " WHERE quiz.deleted_at IS NULL"
+	var args []any
+
+	textSearch := query.Text != ""
+	if textSearch && repo.fts {
+		match := ftsMatch(query.Text)
+		from += " JOIN quiz_fts ON quiz_fts.rowid = quiz.quiz_id"
+		where += " AND quiz_fts MATCH ?"
+		args = append(args, match)
+	} else if textSearch {
+		pattern := likePattern(query.Text)
+		where += `
+			AND (
+				quiz.title LIKE ? ESCAPE '\'
+				OR quiz.description LIKE ? ESCAPE '\'
+				OR EXISTS (
+					SELECT 1 FROM question
+					WHERE question.quiz_id = quiz.quiz_id AND question.revision = quiz.revision
+					AND question.question_text LIKE ? ESCAPE '\'
+				)
+			)`
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	for _, tag := range query.Tags {
+		where += `
+			AND EXISTS (
+				SELECT 1 FROM quiz_tag
+				WHERE quiz_tag.quiz_id = quiz.quiz_id
+				AND tag = ?
+			)`
+		args = append(args, tag)
+	}
+
+	if err := repo.db.Get(&page.Total, "SELECT COUNT(*)"+from+where, args...); err != nil {
+		return QuizPage{}, err
+	}
+
+	var orderBy string
+	switch query.Sort {
+	case QuizSortCreated:
+		orderBy = "created.created_at DESC, quiz.quiz_id DESC"
+	case QuizSortUpdated:
+		orderBy = "updated.created_at DESC, quiz.quiz_id DESC"
+	case QuizSortMostPlayed:
+		orderBy = "times_played DESC, quiz.quiz_id DESC"
+	case QuizSortTitle:
+		orderBy = "quiz.title COLLATE NOCASE ASC, quiz.quiz_id ASC"
+	default:
+		orderBy = "quiz.quiz_id DESC"
+		if textSearch && repo.fts {
+			orderBy = "quiz_fts.rank, " + orderBy
+		}
+	}
+
+	sQuery := `
+		SELECT
+			quiz.quiz_id,
+			COALESCE(quiz.title, '') AS title,
+			COALESCE(quiz.description, '') AS description,
+			created.created_at AS created_at,
+			updated.created_at AS updated_at,
+			(SELECT COUNT(*) FROM quiz_play WHERE quiz_play.quiz_id = quiz.quiz_id) AS times_played` +
+		from + where +
+		" ORDER BY " + orderBy +
+		" LIMIT ? OFFSET ?"
+	args = append(args, query.PerPage, (query.Page-1)*query.PerPage)
+
+	if err := repo.db.Select(&page.Quizzes, sQuery, args...); err != nil {
+		return QuizPage{}, err
+	}
+
+	for i := range page.Quizzes {
+		tags, err := getTags(repo.db, int64(page.Quizzes[i].ID))
+		if err != nil {
+			return QuizPage{}, err
+		}
+		page.Quizzes[i].Tags = tags
+	}
+
+	return page, nil
+}
+
+// insertBankQuestionContent inserts the answers and tags of the bank question
+func insertBankQuestionContent(tx *sqlx.Tx, question *BankQuestion) error {
+	for i := range question.answers {
+		question.answers[i].QuestionID = question.ID
+	}
+
+	if len(question.answers) > 0 {
+		_, err := tx.NamedExec(`
+			INSERT INTO bank_answer (question_id, is_correct, answer_text, latex, image_name, image)
+			VALUES (:question_id, :is_correct, :answer_text, :latex, :image_name, :image)
+		`, question.answers)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range NormalizeTags(question.Tags) {
+		_, err := tx.Exec(`
+			INSERT INTO bank_question_tag (bank_question_id, tag)
+			VALUES (?, ?)
+		`, question.ID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *repositorySQLite) InsertBankQuestion(question *BankQuestion) (int64, error) {
+	if question == nil {
+		return 0, errors.New("question is nil")
+	}
+
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	res, err := tx.Exec(`
+		INSERT INTO bank_question (question_text, difficulty)
+		VALUES (?, ?)
+	`, question.Text, question.Difficulty)
+	if err != nil {
+		return 0, err
+	}
+
+	question.ID, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertBankQuestionContent(tx, question); err != nil {
+		return 0, err
+	}
+
+	return question.ID, tx.Commit()
+}
+
+// UpdateBankQuestion updates the bank question and stores a new revision of every quiz currently using it
+// Previous revisions keep the content the question had, so games played with them stay reproducible
+func (repo *repositorySQLite) UpdateBankQuestion(question *BankQuestion) error {
+	if question == nil {
+		return errors.New("question is nil")
+	}
+
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	res, err := tx.Exec(`
+		UPDATE bank_question SET
+		question_text = ?,
+		difficulty = ?
+		WHERE bank_question_id = ?
+	`, question.Text, question.Difficulty, question.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrBankQuestionNotFound{}
+	}
+
+	// Answers and tags are replaced as updating isn't an option
+	if _, err := tx.Exec("DELETE FROM bank_answer WHERE question_id = ?", question.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM bank_question_tag WHERE bank_question_id = ?", question.ID); err != nil {
+		return err
+	}
+
+	if err := insertBankQuestionContent(tx, question); err != nil {
+		return err
+	}
+
+	var quizIDs []int64
+	err = tx.Select(&quizIDs, `
+		SELECT DISTINCT quiz.quiz_id FROM quiz
+		JOIN question ON question.quiz_id = quiz.quiz_id AND question.revision = quiz.revision
+		WHERE question.bank_question_id = ? AND quiz.deleted_at IS NULL
+	`, question.ID)
+	if err != nil {
+		return err
+	}
+
+	// The new revisions copy the updated content of the question from the bank
+	for _, quizID := range quizIDs {
+		var quiz Quiz
+		err := tx.Get(&quiz, "SELECT quiz_id, title, password, description, revision FROM quiz WHERE quiz_id = ?", quizID)
+		if err != nil {
+			return err
+		}
+		quiz.Questions, err = getQuestions(tx, quizID, quiz.Revision)
+		if err != nil {
+			return err
+		}
+		if _, err := insertNewRevision(tx, &quiz, true); err != nil {
+			return err
+		}
+	}
+	if err := repo.indexQuizzes(tx, quizIDs...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *repositorySQLite) GetBankQuestion(id int64) (*BankQuestion, error) {
+	return getBankQuestion(repo.db, id)
+}
+
+// getBankQuestion returns the hydrated (answers and tags) bank question
+// It accepts a transaction as well, so it can be used while one is running
+func getBankQuestion(q sqlx.Queryer, id int64) (*BankQuestion, error) {
+	var question BankQuestion
+	err := sqlx.Get(q, &question, `
+		SELECT
+			bank_question_id,
+			question_text,
+			difficulty,
+			(
+				SELECT COUNT(DISTINCT question.quiz_id)
+				FROM question
+				JOIN quiz ON quiz.quiz_id = question.quiz_id AND quiz.revision = question.revision
+				WHERE question.bank_question_id = bank_question.bank_question_id AND quiz.deleted_at IS NULL
+			) AS used_by
+		FROM bank_question
+		WHERE bank_question_id = ?
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBankQuestionNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlx.Select(q, &question.answers, `
+		SELECT answer_id, question_id, is_correct, answer_text, latex, image_name, image
+		FROM bank_answer
+		WHERE question_id = ?
+		ORDER BY answer_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlx.Select(q, &question.Tags, `
+		SELECT tag FROM bank_question_tag WHERE bank_question_id = ? ORDER BY tag
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &question, nil
+}
+
+// DeleteBankQuestion deletes the question from the bank
+// Quizzes using the question keep their own copy of it
+func (repo *repositorySQLite) DeleteBankQuestion(id int64) error {
+	tx, err := repo.db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Rollback if no tx.Commit (if there is commit, this is no-op)
+	defer tx.Rollback() //nolint
+
+	if _, err := getBankQuestion(tx, id); err != nil {
+		return err
+	}
+
+	// Quiz revisions store a copy of the question, they only need to be detached
+	if _, err := tx.Exec("UPDATE question SET bank_question_id = 0 WHERE bank_question_id = ?", id); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM bank_answer WHERE question_id = ?",
+		"DELETE FROM bank_question_tag WHERE bank_question_id = ?",
+		"DELETE FROM bank_question WHERE bank_question_id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchBankQuestions returns the hydrated bank questions matching the query, newest first
+// The questions are loaded with their answers and tags in a single query
+func (repo *repositorySQLite) SearchBankQuestions(query BankQuery) ([]BankQuestion, error) {
+	filter := "1=1"
+	var args []any
+
+	if query.Text != "" {
+		pattern := fmt.Sprintf("%%%s%%", query.Text)
+		filter += `
+			AND (
+				bank_question.question_text LIKE ?
+				OR EXISTS (
+					SELECT 1 FROM bank_answer
+					WHERE bank_answer.question_id = bank_question.bank_question_id
+					AND (bank_answer.answer_text LIKE ? OR bank_answer.latex LIKE ?)
+				)
+			)`
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	if query.Difficulty != DifficultyUnset {
+		filter += " AND bank_question.difficulty = ?"
+		args = append(args, query.Difficulty)
+	}
+
+	for _, tag := range NormalizeTags(query.Tags) {
+		filter += `
+			AND EXISTS (
+				SELECT 1 FROM bank_question_tag
+				WHERE bank_question_tag.bank_question_id = bank_question.bank_question_id
+				AND tag = ?
+			)`
+		args = append(args, tag)
+	}
+
+	rows, err := repo.db.Queryx(`
+		SELECT
+			bank_question.bank_question_id,
+			bank_question.question_text,
+			bank_question.difficulty,
+			(
+				SELECT COUNT(DISTINCT question.quiz_id)
+				FROM question
+				JOIN quiz ON quiz.quiz_id = question.quiz_id AND quiz.revision = question.revision
+				WHERE question.bank_question_id = bank_question.bank_question_id AND quiz.deleted_at IS NULL
+			) AS used_by,
+			(
+				SELECT COALESCE(group_concat(tag, ','), '')
+				FROM (
+					SELECT tag FROM bank_question_tag
+					WHERE bank_question_tag.bank_question_id = bank_question.bank_question_id
+					ORDER BY tag
+				)
+			) AS tags,
+			COALESCE(bank_answer.answer_id, 0) AS answer_id,
+			COALESCE(bank_answer.is_correct, 0) AS is_correct,
+			COALESCE(bank_answer.answer_text, '') AS answer_text,
+			COALESCE(bank_answer.latex, '') AS latex,
+			COALESCE(bank_answer.image_name, '') AS image_name,
+			bank_answer.image
+		FROM bank_question
+		LEFT JOIN bank_answer ON bank_answer.question_id = bank_question.bank_question_id
+		WHERE `+filter+`
+		ORDER BY bank_question.bank_question_id DESC, bank_answer.answer_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Scan join query result
+	questions := []BankQuestion{}
+	var currentQst *BankQuestion
+	for rows.Next() {
+		type Result struct {
+			*BankQuestion
+			Tags string `db:"tags"` // Tags are entered as a comma separated list, so they contain no commas
+			Answer
+		}
+		res := Result{BankQuestion: &BankQuestion{}}
+		if err := rows.StructScan(&res); err != nil {
+			return nil, err
+		}
+
+		if currentQst == nil || res.BankQuestion.ID != currentQst.ID {
+			if currentQst != nil {
+				questions = append(questions, *currentQst)
+			}
+			currentQst = res.BankQuestion
+			if res.Tags != "" {
+				currentQst.Tags = strings.Split(res.Tags, ",")
+			}
+		}
+
+		// Questions without answers have a NULL answer
+		if res.Answer.ID != 0 {
+			res.Answer.QuestionID = currentQst.ID
+			currentQst.answers = append(currentQst.answers, res.Answer)
+		}
+	}
+	if currentQst != nil {
+		questions = append(questions, *currentQst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return questions, nil
+}
@@ -1,6 +1,7 @@
 package quiz
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -153,6 +154,503 @@ func TestRepositorySQLite(t *testing.T) {
 		if testQuiz.Questions[0].answers[0].IsCorrect != upsertedQuiz.Questions[0].answers[0].IsCorrect {
 			t.Errorf("Expected Answer IsCorrect %v, got: %v", testQuiz.Questions[0].answers[0].IsCorrect, upsertedQuiz.Questions[0].answers[0].IsCorrect)
 		}
+
+		// The same content, like the examples on every start, doesn't create a revision
+		same := testQuiz
+		if _, err := repo.Upsert(&same); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if same.Revision != 1 {
+			t.Errorf("Expected revision 1 to stay current, got: %d", same.Revision)
+		}
+
+		changed := testQuiz
+		changed.Questions = []Question{{Text: "What is the capital of Italy?", answers: []Answer{{TextField: "Rome", IsCorrect: true}}}}
+		if _, err := repo.Upsert(&changed); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if changed.Revision != 2 {
+			t.Errorf("Expected the changed content to be revision 2, got: %d", changed.Revision)
+		}
+		first, err := repo.GetRevision(id, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if first.Questions[0].Text != testQuiz.Questions[0].Text {
+			t.Errorf("Expected revision 1 to be unchanged, got: %s", first.Questions[0].Text)
+		}
+
+		// Upserting a deleted quiz restores it
+		if err := repo.Delete(id); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if _, err := repo.Upsert(&changed); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if restored, err := repo.Get(id); err != nil || restored.Revision != 2 {
+			t.Errorf("Expected the quiz to be restored at revision 2, got: %+v (%v)", restored, err)
+		}
+	})
+
+	t.Run("Update creates new revision", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		quiz := testQuiz
+		quiz.ID = 0
+		id, err := repo.Insert(&quiz)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		updated, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		updated.TitleField = "Updated Quiz"
+		updated.Questions[0].Text = "What is the capital of Germany?"
+		if _, err := repo.Update(updated); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if updated.Revision != 2 {
+			t.Errorf("Expected revision 2, got: %d", updated.Revision)
+		}
+
+		current, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if current.Revision != 2 || current.TitleField != "Updated Quiz" {
+			t.Errorf("Expected current revision 2 titled 'Updated Quiz', got: %d %s", current.Revision, current.TitleField)
+		}
+		if len(current.Questions) != 1 {
+			t.Fatalf("Expected 1 question, got: %d", len(current.Questions))
+		}
+
+		first, err := repo.GetRevision(id, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if first.TitleField != testQuiz.TitleField {
+			t.Errorf("Expected Title %s, got %s", testQuiz.TitleField, first.TitleField)
+		}
+		if first.Questions[0].Text != testQuiz.Questions[0].Text {
+			t.Errorf("Expected Question Text %s, got %s", testQuiz.Questions[0].Text, first.Questions[0].Text)
+		}
+		if len(first.Questions[0].answers) != len(testQuiz.Questions[0].answers) {
+			t.Errorf("Expected %d answers, got %d", len(testQuiz.Questions[0].answers), len(first.Questions[0].answers))
+		}
+
+		revisions, err := repo.GetRevisions(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(revisions) != 2 || revisions[0].Revision != 2 {
+			t.Errorf("Expected 2 revisions with newest first, got: %+v", revisions)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		quiz := testQuiz
+		quiz.ID = 0
+		id, err := repo.Insert(&quiz)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		updated, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		updated.TitleField = "Updated Quiz"
+		if _, err := repo.Update(updated); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		newRev, err := repo.Rollback(id, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if newRev != 3 {
+			t.Errorf("Expected rollback to create revision 3, got: %d", newRev)
+		}
+
+		current, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if current.TitleField != testQuiz.TitleField {
+			t.Errorf("Expected Title %s, got %s", testQuiz.TitleField, current.TitleField)
+		}
+		if len(current.Questions) != len(testQuiz.Questions) {
+			t.Errorf("Expected %d questions, got %d", len(testQuiz.Questions), len(current.Questions))
+		}
+
+		if _, err := repo.Rollback(id, 42); err == nil {
+			t.Errorf("Expected error for rolling back to non-existent revision, got nil")
+		}
+	})
+
+	t.Run("insert and get quiz with image answer", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		quiz := Quiz{
+			TitleField: "Images",
+			Questions: []Question{
+				{
+					Text: "Which one is a circle?",
+					answers: []Answer{
+						{ImageName: "circle.png", Image: []byte{1, 2, 3}, IsCorrect: true},
+						{LaTeX: `\square`},
+					},
+				},
+			},
+		}
+		id, err := repo.Insert(&quiz)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		inserted, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		answers := inserted.Questions[0].answers
+		if answers[0].ImageName != "circle.png" || len(answers[0].Image) != 3 {
+			t.Errorf("Expected image answer to be stored, got: %+v", answers[0])
+		}
+		if answers[1].LaTeX != `\square` {
+			t.Errorf("Expected LaTeX answer to be stored, got: %+v", answers[1])
+		}
+	})
+
+	t.Run("bank question shared between quizzes", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		bankQst := BankQuestion{
+			Text:       "What is the capital of Frnace?",
+			Difficulty: DifficultyEasy,
+			Tags:       []string{"Geography", " europe "},
+			answers: []Answer{
+				{TextField: "Paris", IsCorrect: true},
+				{TextField: "Lyon"},
+			},
+		}
+		bid, err := repo.InsertBankQuestion(&bankQst)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		// Both quizzes reference the same bank question
+		var quizIDs []int64
+		for _, title := range []string{"Quiz A", "Quiz B"} {
+			quiz := Quiz{TitleField: title, Questions: []Question{bankQst.Question()}}
+			id, err := repo.Insert(&quiz)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			quizIDs = append(quizIDs, id)
+		}
+
+		stored, err := repo.GetBankQuestion(bid)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if stored.UsedBy != 2 {
+			t.Errorf("Expected question to be used by 2 quizzes, got: %d", stored.UsedBy)
+		}
+		if len(stored.Tags) != 2 || !stored.HasTag("geography") || !stored.HasTag("europe") {
+			t.Errorf("Expected normalized tags, got: %v", stored.Tags)
+		}
+
+		// Fixing the typo in the bank fixes it in a new revision of every quiz
+		stored.Text = "What is the capital of France?"
+		if err := repo.UpdateBankQuestion(stored); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		for _, id := range quizIDs {
+			quiz, err := repo.Get(id)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if quiz.Revision != 2 {
+				t.Errorf("Expected the update to create revision 2, got %d", quiz.Revision)
+			}
+			if quiz.Questions[0].Text != stored.Text || quiz.Questions[0].BankQuestionID != bid {
+				t.Errorf("Expected Question Text %s, got %s", stored.Text, quiz.Questions[0].Text)
+			}
+			if len(quiz.Questions[0].answers) != 2 || !quiz.Questions[0].IsAnswerCorrect(0) {
+				t.Errorf("Expected bank answers to be copied, got: %+v", quiz.Questions[0].answers)
+			}
+
+			// Revisions are immutable, the first one keeps the typo
+			first, err := repo.GetRevision(id, 1)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if first.Questions[0].Text != bankQst.Text || len(first.Questions[0].answers) != 2 {
+				t.Errorf("Expected revision 1 to be unchanged, got: %+v", first.Questions[0])
+			}
+		}
+		if page, err := repo.SearchQuizzes(QuizQuery{Text: "France"}); err != nil || page.Total != 2 {
+			t.Errorf("Expected both quizzes to be found by the new text, got %+v (%v)", page, err)
+		}
+
+		// Deleting the bank question leaves a copy in the quizzes
+		if err := repo.DeleteBankQuestion(bid); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if _, err := repo.GetBankQuestion(bid); err == nil {
+			t.Errorf("Expected error for getting deleted bank question, got nil")
+		}
+		quiz, err := repo.Get(quizIDs[0])
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if quiz.Questions[0].BankQuestionID != 0 || quiz.Questions[0].Text != stored.Text {
+			t.Errorf("Expected detached question with bank text, got: %+v", quiz.Questions[0])
+		}
+		if len(quiz.Questions[0].answers) != 2 {
+			t.Errorf("Expected detached question to keep 2 answers, got: %d", len(quiz.Questions[0].answers))
+		}
+	})
+
+	t.Run("Rollback keeps the content of bank questions", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		bankQst := BankQuestion{
+			Text:    "What is the capital of Frnace?",
+			answers: []Answer{{TextField: "Paris", IsCorrect: true}, {TextField: "Lyon"}},
+		}
+		if _, err := repo.InsertBankQuestion(&bankQst); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		quiz := Quiz{TitleField: "Quiz", Questions: []Question{bankQst.Question()}}
+		id, err := repo.Insert(&quiz)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		// Creates revision 2 with the new text
+		edited := bankQst
+		edited.Text = "What is the capital of France?"
+		edited.answers = []Answer{{TextField: "Paris", IsCorrect: true}}
+		if err := repo.UpdateBankQuestion(&edited); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		newRev, err := repo.Rollback(id, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		current, err := repo.Get(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if newRev != 3 || current.Revision != 3 {
+			t.Errorf("Expected rollback to create revision 3, got: %d", newRev)
+		}
+		question := current.Questions[0]
+		if question.Text != bankQst.Text || len(question.answers) != 2 {
+			t.Errorf("Expected the content of revision 1, got: %+v", question)
+		}
+		if question.BankQuestionID != bankQst.ID {
+			t.Errorf("Expected the question to stay linked to the bank, got: %d", question.BankQuestionID)
+		}
+	})
+
+	t.Run("restart keeps revisions with bank questions", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		// Every connection to :memory: is a new database
+		db.SetMaxOpenConns(1)
+		repo := newRepo(db)
+
+		// The answers are added to the bank question only later
+		bankQst := BankQuestion{Text: "Name a prime number"}
+		if _, err := repo.InsertBankQuestion(&bankQst); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		quiz := Quiz{TitleField: "Primes", Questions: []Question{bankQst.Question()}}
+		id, err := repo.Insert(&quiz)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		bankQst.answers = []Answer{{TextField: "7", IsCorrect: true}}
+		if err := repo.UpdateBankQuestion(&bankQst); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		restarted := newRepo(db)
+		first, err := restarted.GetRevision(id, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(first.Questions[0].answers) != 0 {
+			t.Errorf("Expected revision 1 to stay without answers, got: %+v", first.Questions[0].answers)
+		}
+	})
+
+	t.Run("SearchBankQuestions", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		questions := []BankQuestion{
+			{Text: "What is 2 + 2?", Difficulty: DifficultyEasy, Tags: []string{"math"}, answers: []Answer{{TextField: "4", IsCorrect: true}}},
+			{Text: "What is the integral of x?", Difficulty: DifficultyHard, Tags: []string{"math", "calculus"}, answers: []Answer{{LaTeX: `x^2/2`, IsCorrect: true}}},
+			{Text: "Which river flows through Paris?", Difficulty: DifficultyEasy, Tags: []string{"geography"}, answers: []Answer{{TextField: "Seine", IsCorrect: true}}},
+		}
+		for i := range questions {
+			if _, err := repo.InsertBankQuestion(&questions[i]); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+		}
+
+		tests := []struct {
+			name     string
+			query    BankQuery
+			expected int
+		}{
+			{"everything", BankQuery{}, 3},
+			{"question text", BankQuery{Text: "paris"}, 1},
+			{"answer text", BankQuery{Text: "x^2"}, 1},
+			{"tag", BankQuery{Tags: []string{"Math"}}, 2},
+			{"all tags", BankQuery{Tags: []string{"math", "calculus"}}, 1},
+			{"difficulty", BankQuery{Difficulty: DifficultyEasy}, 2},
+			{"combined", BankQuery{Tags: []string{"math"}, Difficulty: DifficultyEasy}, 1},
+			{"no match", BankQuery{Text: "history"}, 0},
+		}
+		for _, tt := range tests {
+			found, err := repo.SearchBankQuestions(tt.query)
+			if err != nil {
+				t.Fatalf("%s: Expected no error, got: %v", tt.name, err)
+			}
+			if len(found) != tt.expected {
+				t.Errorf("%s: Expected %d questions, got %d", tt.name, tt.expected, len(found))
+			}
+		}
+	})
+
+	t.Run("SearchQuizzes", func(t *testing.T) {
+		db := newDB()
+		defer db.Close()
+		repo := newRepo(db)
+
+		quizzes := []Quiz{
+			{TitleField: "European capitals", Description: "Geography basics", Tags: []string{"geography", "Europe"}, Questions: []Question{
+				{Text: "What is the capital of France?", answers: []Answer{{TextField: "Paris", IsCorrect: true}}},
+			}},
+			{TitleField: "Algebra", Description: "Equations", Tags: []string{"math"}, Questions: []Question{
+				{Text: "Solve x + 1 = 2", answers: []Answer{{TextField: "1", IsCorrect: true}}},
+			}},
+			{TitleField: "Rivers", Description: "Rivers of Europe", Tags: []string{"geography"}, Questions: []Question{
+				{Text: "Which river flows through Paris?", answers: []Answer{{TextField: "Seine", IsCorrect: true}}},
+			}},
+		}
+		ids := make([]int64, len(quizzes))
+		for i := range quizzes {
+			id, err := repo.Insert(&quizzes[i])
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			ids[i] = id
+		}
+
+		// Algebra is the most played, Rivers was updated last
+		for i := 0; i < 2; i++ {
+			if err := repo.RecordPlay(ids[1]); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+		}
+		rivers := quizzes[2]
+		rivers.ID = ids[2]
+		if _, err := repo.Update(&rivers); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			query    QuizQuery
+			expected []int64
+		}{
+			{"everything", QuizQuery{}, []int64{ids[2], ids[1], ids[0]}},
+			{"title", QuizQuery{Text: "algebra"}, []int64{ids[1]}},
+			{"description", QuizQuery{Text: "europe", Sort: QuizSortTitle}, []int64{ids[0], ids[2]}},
+			{"question text", QuizQuery{Text: "river flows"}, []int64{ids[2]}},
+			{"newest", QuizQuery{Text: "Europe", Sort: QuizSortCreated}, []int64{ids[2], ids[0]}},
+			{"tag", QuizQuery{Tags: []string{"Geography"}, Sort: QuizSortTitle}, []int64{ids[0], ids[2]}},
+			{"all tags", QuizQuery{Tags: []string{"geography", "europe"}}, []int64{ids[0]}},
+			{"most played", QuizQuery{Sort: QuizSortMostPlayed}, []int64{ids[1], ids[2], ids[0]}},
+			{"recently updated", QuizQuery{Sort: QuizSortUpdated, PerPage: 1}, []int64{ids[2]}},
+			{"second page", QuizQuery{Sort: QuizSortTitle, Page: 2, PerPage: 2}, []int64{ids[2]}},
+			{"no match", QuizQuery{Text: "history"}, []int64{}},
+		}
+		for _, tt := range tests {
+			page, err := repo.SearchQuizzes(tt.query)
+			if err != nil {
+				t.Fatalf("%s: Expected no error, got: %v", tt.name, err)
+			}
+
+			found := make([]int64, len(page.Quizzes))
+			for i, meta := range page.Quizzes {
+				found[i] = int64(meta.ID)
+			}
+			if !slices.Equal(found, tt.expected) {
+				t.Errorf("%s: Expected quizzes %v, got %v", tt.name, tt.expected, found)
+			}
+		}
+
+		page, err := repo.SearchQuizzes(QuizQuery{Text: "algebra"})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		algebra := page.Quizzes[0]
+		if algebra.TimesPlayed != 2 {
+			t.Errorf("Expected quiz to be played 2 times, got %d", algebra.TimesPlayed)
+		}
+		if !slices.Equal(algebra.Tags, []string{"math"}) {
+			t.Errorf("Expected tags [math], got %v", algebra.Tags)
+		}
+		if algebra.CreatedAt.IsZero() || algebra.UpdatedAt.IsZero() {
+			t.Errorf("Expected creation and update times to be set")
+		}
+
+		page, err = repo.SearchQuizzes(QuizQuery{PerPage: 2})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if page.Total != 3 || page.Pages() != 2 || !page.HasNext() || page.HasPrev() {
+			t.Errorf("Expected first of 2 pages with 3 quizzes, got page %d of %d with %d quizzes", page.Query.Page, page.Pages(), page.Total)
+		}
+
+		// Wildcards of LIKE are searched for literally
+		for _, title := range []string{"Get 100% right", "Get 1000 points", "snake_case", "snakexcase"} {
+			if _, err := repo.Insert(&Quiz{TitleField: title}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+		}
+		for text, expected := range map[string]string{"0%": "Get 100% right", "e_c": "snake_case"} {
+			page, err := repo.SearchQuizzes(QuizQuery{Text: text})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if page.Total != 1 || page.Quizzes[0].Title != expected {
+				t.Errorf("Expected only %q for %q, got %+v", expected, text, page.Quizzes)
+			}
+		}
 	})
 
 	t.Run("DeleteQuiz", func(t *testing.T) {
@@ -166,6 +664,26 @@ func TestRepositorySQLite(t *testing.T) {
 		if err != nil {
 			t.Errorf("Failed to delete quiz: %v", err)
 		}
+
+		if _, err := repo.Get(id); err != (ErrQuizNotFound{}) {
+			t.Errorf("Expected ErrQuizNotFound for a deleted quiz, got: %v", err)
+		}
+		if quizzes, err := repo.GetAll(); err != nil || len(quizzes) != 0 {
+			t.Errorf("Expected no quizzes, got: %v (%v)", quizzes, err)
+		}
+		if page, err := repo.SearchQuizzes(QuizQuery{Text: "Test"}); err != nil || page.Total != 0 {
+			t.Errorf("Expected the deleted quiz not to be found, got: %+v (%v)", page, err)
+		}
+		// Past games and assignments are pinned to the revisions of the quiz
+		if revision, err := repo.GetRevision(id, 1); err != nil || revision.TitleField != quiz.TitleField {
+			t.Errorf("Expected the revision to be kept, got: %+v (%v)", revision, err)
+		}
+		if _, err := repo.Update(quiz); err != (ErrQuizNotFound{}) {
+			t.Errorf("Expected ErrQuizNotFound for updating a deleted quiz, got: %v", err)
+		}
+		if err := repo.Delete(id); err != (ErrQuizNotFound{}) {
+			t.Errorf("Expected ErrQuizNotFound for deleting twice, got: %v", err)
+		}
 	})
 
 	t.Run("GetAll", func(t *testing.T) {
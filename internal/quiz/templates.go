@@ -10,11 +10,13 @@ import (
 )
 
 var funcMap = template.FuncMap{
-	"add": func(a, b int) int { return a + b },
+	"add":  func(a, b int) int { return a + b },
+	"join": strings.Join,
 }
 
 var QuizzesTemplate = common.TmplParseWithBase("templates/quizzes/quizzes.html")
 var QuizPreviewTemplate = common.TmplParseWithBase("templates/quizzes/quiz-preview.html")
+var QuizRemixTemplate = common.TmplParseWithBase("templates/quizzes/quiz-remix.html")
 
 func parseWithFuncs(path string) *template.Template {
 	embedPath := strings.TrimPrefix(path, "templates/")
@@ -39,3 +41,22 @@ type QuestionListData struct {
 	Questions    []Question
 	ActionPrefix string
 }
+
+// BankPickerData is used to render "bank-picker-results" of QuestionListTmpl
+type BankPickerData struct {
+	Questions    []BankQuestion
+	ActionPrefix string // Prefix of the quiz form the questions are added to
+}
+
+func parseBankTemplate(path string) *template.Template {
+	embedPath := strings.TrimPrefix(path, "templates/")
+	baseName := filepath.Base(embedPath)
+	return template.Must(
+		template.New(baseName).Funcs(funcMap).ParseFS(
+			templates.FS, embedPath, "quizzes/bank-question-form-partial.html", "base.html",
+		),
+	)
+}
+
+var QuestionBankTemplate = parseBankTemplate("templates/quizzes/question-bank.html")
+var BankQuestionTemplate = parseBankTemplate("templates/quizzes/bank-question.html")
@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config configures the outgoing webhooks
+type Config struct {
+	Endpoints   []string      // Every event is delivered to all endpoints, none disables the webhooks
+	Secret      string        // Key of the HMAC signatures
+	MaxAttempts int           // Deliveries are given up after this many failed attempts
+	Backoff     time.Duration // Pause before the first retry, it doubles with every attempt
+	MaxBackoff  time.Duration
+	Timeout     time.Duration // Timeout of a single attempt
+	Workers     int           // Number of deliveries sent at the same time
+}
+
+// DefaultConfig returns the config with the default retry policy and no endpoints
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Minute,
+		Timeout:     10 * time.Second,
+		Workers:     2,
+	}
+}
+
+// Number of deliveries queued before new events are dropped
+const queueSize = 256
+
+type delivery struct {
+	endpoint string
+	payload  Payload
+	body     []byte
+	attempt  int // Number of failed attempts so far
+}
+
+// Dispatcher delivers the webhooks in the background, failed deliveries are retried with exponential backoff
+// A nil Dispatcher is valid and drops all events
+type Dispatcher struct {
+	cfg      Config
+	client   *http.Client
+	queue    chan *delivery
+	stop     chan struct{}
+	stopOnce sync.Once
+	pending  sync.WaitGroup // Deliveries that are neither delivered nor given up
+}
+
+func NewDispatcher(cfg Config) *Dispatcher {
+	return &Dispatcher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan *delivery, queueSize),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start starts the background workers delivering the webhooks
+func (d *Dispatcher) Start() {
+	if d == nil {
+		return
+	}
+	for range max(d.cfg.Workers, 1) {
+		go d.work()
+	}
+}
+
+// Shutdown waits for the queued deliveries, including their retries, and stops the workers
+// It returns the context's error if the deadline passes first, the remaining deliveries are dropped then
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	defer d.stopOnce.Do(func() { close(d.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify queues the payload for all endpoints, it never blocks
+// The ID and the time of the event are set if they are empty
+func (d *Dispatcher) Notify(payload Payload) {
+	if d == nil || len(d.cfg.Endpoints) == 0 {
+		return
+	}
+	if payload.ID == "" {
+		payload.ID = newDeliveryID()
+	}
+	if payload.OccurredAt.IsZero() {
+		payload.OccurredAt = time.Now()
+	}
+	if payload.Scores == nil {
+		payload.Scores = []Score{}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Error encoding webhook payload", "event", payload.Event, "err", err)
+		return
+	}
+
+	for _, endpoint := range d.cfg.Endpoints {
+		d.pending.Add(1)
+		select {
+		case d.queue <- &delivery{endpoint: endpoint, payload: payload, body: body}:
+		default:
+			d.pending.Done()
+			slog.Warn("Webhook queue is full, dropping event", "event", payload.Event, "endpoint", endpoint)
+		}
+	}
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.stop:
+			return
+		case del := <-d.queue:
+			d.attempt(del)
+		}
+	}
+}
+
+// attempt sends the delivery once, on failure it is queued again after the backoff
+func (d *Dispatcher) attempt(del *delivery) {
+	err := d.send(del)
+	if err == nil {
+		slog.Debug("Webhook delivered", "event", del.payload.Event, "endpoint", del.endpoint, "id", del.payload.ID)
+		d.pending.Done()
+		return
+	}
+
+	del.attempt++
+	if _, permanent := err.(errPermanent); permanent || del.attempt >= d.cfg.MaxAttempts {
+		slog.Error("Giving up webhook delivery", "event", del.payload.Event, "endpoint", del.endpoint, "id", del.payload.ID, "attempts", del.attempt, "err", err)
+		d.pending.Done()
+		return
+	}
+
+	backoff := d.backoff(del.attempt)
+	slog.Warn("Webhook delivery failed, retrying", "event", del.payload.Event, "endpoint", del.endpoint, "attempt", del.attempt, "retry-in", backoff, "err", err)
+	time.AfterFunc(backoff, func() {
+		select {
+		case <-d.stop:
+			d.pending.Done()
+		case d.queue <- del:
+		}
+	})
+}
+
+// backoff returns the pause after the given number of failed attempts
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.cfg.Backoff << (attempt - 1)
+	if d.cfg.MaxBackoff > 0 && (backoff > d.cfg.MaxBackoff || backoff <= 0) {
+		return d.cfg.MaxBackoff
+	}
+	return backoff
+}
+
+// errPermanent is a failed delivery that retrying won't fix, like a rejected request
+type errPermanent struct {
+	reason string
+}
+
+func (e errPermanent) Error() string {
+	return e.reason
+}
+
+func (d *Dispatcher) send(del *delivery) error {
+	req, err := http.NewRequest(http.MethodPost, del.endpoint, bytes.NewReader(del.body))
+	if err != nil {
+		return errPermanent{reason: "invalid request: " + err.Error()}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, del.payload.Event)
+	req.Header.Set(HeaderDelivery, del.payload.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.cfg.Secret, timestamp, del.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	default:
+		return errPermanent{reason: fmt.Sprintf("endpoint rejected the webhook with status %d", resp.StatusCode)}
+	}
+}
+
+// Sign returns the signature of the delivery: "sha256=" followed by the hex HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature of the delivery is valid
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
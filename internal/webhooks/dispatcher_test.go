@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDispatcher(t *testing.T, endpoint string) *Dispatcher {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Endpoints = []string{endpoint}
+	cfg.Secret = "secret"
+	cfg.Backoff = time.Millisecond
+	cfg.MaxAttempts = 3
+	d := NewDispatcher(cfg)
+	d.Start()
+	return d
+}
+
+func shutdown(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestDeliverToReceiver(t *testing.T) {
+	receiver := NewTestReceiver("secret")
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	d := newTestDispatcher(t, server.URL)
+	d.Notify(Payload{Event: EventGameEnded, LobbyPin: "1234", Scores: []Score{{Username: "alice", Points: 900}}})
+	shutdown(t, d)
+
+	received := receiver.Received()
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(received))
+	}
+	if got := received[0]; got.Event != EventGameEnded || got.ID == "" || got.Scores[0].Points != 900 {
+		t.Errorf("Unexpected payload: %+v", got)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	d := newTestDispatcher(t, server.URL)
+	d.Notify(Payload{Event: EventGameStarted})
+	shutdown(t, d)
+
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+}
+
+func TestGiveUpOnRejection(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	d := newTestDispatcher(t, server.URL)
+	d.Notify(Payload{Event: EventGameStarted})
+	shutdown(t, d)
+
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Expected a rejected delivery not to be retried, got %d attempts", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(Config{Backoff: time.Second, MaxBackoff: 5 * time.Second})
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 80: 5 * time.Second} {
+		if backoff := d.backoff(attempt); backoff != expected {
+			t.Errorf("Expected backoff %s after %d attempts, got %s", expected, attempt, backoff)
+		}
+	}
+}
+
+func TestReceiverRejectsInvalidSignature(t *testing.T) {
+	receiver := NewTestReceiver("secret")
+	body := `{"event": "game.ended"}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, signature := range []string{"", Sign("other", timestamp, []byte(body))} {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/test", strings.NewReader(body))
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, signature)
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d for signature %q, got %d", http.StatusUnauthorized, signature, w.Code)
+		}
+	}
+	if len(receiver.Received()) != 0 {
+		t.Error("Expected no deliveries to be kept")
+	}
+}
+
+func TestNilDispatcher(t *testing.T) {
+	var d *Dispatcher
+	d.Start()
+	d.Notify(Payload{Event: EventLobbyCreated})
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"time"
+)
+
+// Lifecycle events of a game the webhooks are fired on
+const (
+	EventLobbyCreated  = "lobby.created"
+	EventGameStarted   = "game.started"
+	EventRoundFinished = "round.finished"
+	EventGameEnded     = "game.ended"
+)
+
+// Headers of a delivery, the signature is computed over the timestamp and the body, see Sign
+const (
+	HeaderEvent     = "X-Kwikquiz-Event"
+	HeaderDelivery  = "X-Kwikquiz-Delivery"
+	HeaderTimestamp = "X-Kwikquiz-Timestamp"
+	HeaderSignature = "X-Kwikquiz-Signature"
+)
+
+// Payload is the JSON body of a webhook delivery
+type Payload struct {
+	ID           string    `json:"id"` // Same for all retries of a delivery, receivers can use it to drop duplicates
+	Event        string    `json:"event"`
+	OccurredAt   time.Time `json:"occurredAt"`
+	LobbyPin     string    `json:"lobbyPin"`
+	QuizID       int64     `json:"quizId,omitempty"` // 0 if the quiz isn't stored in the database
+	QuizRevision int64     `json:"quizRevision,omitempty"`
+	QuizTitle    string    `json:"quizTitle"`
+	Questions    int       `json:"questions"`            // Number of questions asked in the game
+	Round        *int      `json:"round,omitempty"`      // Round that finished, rounds are of index 0
+	Scores       []Score   `json:"scores"`               // Sorted by points, descending, empty before the game starts
+	PastGameID   int64     `json:"pastGameId,omitempty"` // Set once the game ended and was stored
+}
+
+type Score struct {
+	Username string `json:"username"`
+	Points   int    `json:"points"`
+	// Points of the finished round, only set for round.finished
+	RoundPoints *int `json:"roundPoints,omitempty"`
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deliveries with a timestamp further off are rejected, so a captured delivery can't be replayed later
+const maxClockSkew = 5 * time.Minute
+
+// Number of deliveries the test receiver keeps
+const receivedLimit = 100
+
+// TestReceiver is a webhook endpoint for trying out the webhooks locally
+// It verifies the signatures, logs every delivery and lists the last ones on GET
+type TestReceiver struct {
+	secret   string
+	mu       sync.Mutex
+	received []Payload // Newest last
+}
+
+func NewTestReceiver(secret string) *TestReceiver {
+	return &TestReceiver{secret: secret}
+}
+
+func (rcv *TestReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		rcv.receive(w, r)
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rcv.Received()); err != nil {
+			slog.Error("Error encoding received webhooks", "err", err)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (rcv *TestReceiver) receive(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(unix, 0)).Abs() > maxClockSkew {
+		http.Error(w, "Invalid timestamp", http.StatusUnauthorized)
+		return
+	}
+	if !Verify(rcv.secret, timestamp, body, r.Header.Get(HeaderSignature)) {
+		slog.Warn("Test receiver got a webhook with an invalid signature", "delivery", r.Header.Get(HeaderDelivery))
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	slog.Info("Test receiver got a webhook", "event", payload.Event, "id", payload.ID, "lobby-pin", payload.LobbyPin, "scores", len(payload.Scores))
+
+	rcv.mu.Lock()
+	rcv.received = append(rcv.received, payload)
+	if len(rcv.received) > receivedLimit {
+		rcv.received = rcv.received[len(rcv.received)-receivedLimit:]
+	}
+	rcv.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Received returns the last deliveries, oldest first
+func (rcv *TestReceiver) Received() []Payload {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]Payload{}, rcv.received...)
+}
@@ -1,31 +1,69 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/erykksc/kwikquiz/internal/assignments"
 	"github.com/erykksc/kwikquiz/internal/common"
 	"github.com/erykksc/kwikquiz/internal/lobbies"
 	"github.com/erykksc/kwikquiz/internal/pastgames"
 	"github.com/erykksc/kwikquiz/internal/quiz"
+	"github.com/erykksc/kwikquiz/internal/webhooks"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Variables used for command line parameters
 var (
-	Port       uint
-	InProdMode bool
-	InDevMode  bool
+	Port            uint
+	InProdMode      bool
+	InDevMode       bool
+	LobbyReaper     lobbies.ReaperConfig
+	Retention       pastgames.RetentionConfig
+	ShutdownTimeout time.Duration
+	ReplayGameID    int64
+	Webhooks        = webhooks.DefaultConfig()
+	// The webhook secret itself isn't a flag, the command line is visible to other processes
+	WebhookSecretFile string
+	LTIPlatforms      []string
 )
 
+// webhookSecretEnv is the environment variable with the key of the webhook signatures
+const webhookSecretEnv = "KWIKQUIZ_WEBHOOK_SECRET"
+
 func init() {
 	flag.UintVar(&Port, "port", 3000, "Port to host the app")
 	flag.BoolVar(&InProdMode, "prod", false, "Run the app in production mode")
+	flag.DurationVar(&LobbyReaper.Interval, "lobby-reap-interval", time.Minute, "How often abandoned lobbies are looked for")
+	flag.DurationVar(&LobbyReaper.IdleTimeout, "lobby-idle-timeout", 30*time.Minute, "Close lobbies without any activity for this long, 0 to never close them")
+	flag.DurationVar(&LobbyReaper.MaxLifetime, "lobby-max-lifetime", 6*time.Hour, "Close lobbies existing for this long, 0 to never close them")
+	flag.DurationVar(&Retention.Interval, "past-game-retention-interval", time.Hour, "How often the retention policy of past games is enforced")
+	flag.DurationVar(&Retention.AnonymiseAfter, "past-game-anonymise-after", 0, "Anonymise the usernames of past games that ended this long ago, 0 to keep them")
+	flag.DurationVar(&Retention.DeleteAfter, "past-game-delete-after", 0, "Delete past games that ended this long ago, 0 to keep them forever")
+	flag.DurationVar(&ShutdownTimeout, "shutdown-timeout", 10*time.Second, "How long saving running games and closing connections may take on shutdown")
+	flag.Func("webhook-url", "Endpoint notified about the lifecycle of games, can be repeated", func(url string) error {
+		Webhooks.Endpoints = append(Webhooks.Endpoints, url)
+		return nil
+	})
+	flag.StringVar(&WebhookSecretFile, "webhook-secret-file", "", "File with the key of the HMAC signatures of the webhooks, "+webhookSecretEnv+" is used if not set")
+	flag.Func("lti-platform", "Origin of an LTI platform grades may be passed back to, e.g. https://lms.example.com, can be repeated", func(origin string) error {
+		LTIPlatforms = append(LTIPlatforms, origin)
+		return nil
+	})
+	flag.Int64Var(&ReplayGameID, "replay", 0, "Replay the event log of the past game with this ID, print its scores and exit")
 	flag.Parse()
 
 	InDevMode = !InProdMode
@@ -40,6 +78,77 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// metricsHandler serves the expvars with the given names as a JSON object, in the format of expvar.Handler
+func metricsHandler(names ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(w, "{\n")
+		for i, name := range names {
+			if i > 0 {
+				fmt.Fprint(w, ",\n")
+			}
+			value := "null"
+			if v := expvar.Get(name); v != nil {
+				value = v.String()
+			}
+			fmt.Fprintf(w, "%q: %s", name, value)
+		}
+		fmt.Fprint(w, "\n}\n")
+	})
+}
+
+// loadWebhookSecret returns the key of the webhook signatures from -webhook-secret-file or the environment
+func loadWebhookSecret() (string, error) {
+	if WebhookSecretFile == "" {
+		return os.Getenv(webhookSecretEnv), nil
+	}
+	secret, err := os.ReadFile(WebhookSecretFile)
+	if err != nil {
+		return "", fmt.Errorf("reading the webhook secret: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// replayPastGame prints the scores stored with the past game next to the scores of its replayed event log
+func replayPastGame(pgRepo pastgames.Repository, qRepo quiz.Repository, id int64) error {
+	pastGame, replayed, err := lobbies.ReplayPastGame(pgRepo, qRepo, id)
+	if err != nil {
+		return err
+	}
+
+	scores := make(map[string]int, len(replayed))
+	for _, score := range replayed {
+		scores[string(score.Username)] = score.Points
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Past game %d: %s\n", pastGame.ID, pastGame.QuizTitle)
+	fmt.Fprintln(w, "PLAYER\tSTORED\tREPLAYED\t")
+	mismatches := 0
+	for _, stored := range pastGame.Scores {
+		points, ok := scores[stored.Username]
+		delete(scores, stored.Username)
+		mark := ""
+		if !ok || points != stored.Score {
+			mark = "MISMATCH"
+			mismatches++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", stored.Username, stored.Score, points, mark)
+	}
+	for username, points := range scores {
+		fmt.Fprintf(w, "%s\t-\t%d\tMISMATCH\n", username, points)
+		mismatches++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("replayed scores of %d players don't match", mismatches)
+	}
+	return nil
+}
+
 func main() {
 	// Set up logging
 	opts := slog.HandlerOptions{
@@ -55,12 +164,16 @@ func main() {
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
+	// Cancelled on SIGINT or SIGTERM, which starts the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Open sqlite database connection
+	// It is closed during the graceful shutdown, after the running games are saved
 	db, err := sqlx.Open("sqlite3", "kwikquiz.db")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
 	// Enforce CASCADE in sqlite, this needs to run before any other query
 	_, err = db.Exec("PRAGMA foreign_keys = ON;")
@@ -74,7 +187,17 @@ func main() {
 		slog.Error("failed to set up pastgames repo", "err", err)
 		panic(err)
 	}
-	pastGamesService := pastgames.NewService(pastGamesRepo)
+	if InDevMode {
+		// The stub standing in for an LMS, served below
+		LTIPlatforms = append(LTIPlatforms, fmt.Sprintf("http://localhost:%d", Port))
+	}
+	pastGamesService, err := pastgames.NewService(pastGamesRepo).WithLTIPlatforms(LTIPlatforms...)
+	if err != nil {
+		log.Fatal("-lti-platform: ", err)
+	}
+	if Retention.DeleteAfter > 0 && Retention.AnonymiseAfter >= Retention.DeleteAfter {
+		slog.Warn("Past games are deleted before they would be anonymised", "anonymise-after", Retention.AnonymiseAfter, "delete-after", Retention.DeleteAfter)
+	}
 
 	// Setup Quiz Service
 	quizRepo, err := quiz.NewRepositorySQLite(db)
@@ -84,9 +207,54 @@ func main() {
 	}
 	quizService := quiz.NewService(quizRepo)
 
+	if ReplayGameID != 0 {
+		if err := replayPastGame(pastGamesRepo, quizRepo, ReplayGameID); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Started after the replay, so replaying never anonymises or deletes past games
+	go pastGamesService.RunRetention(ctx, Retention)
+
+	// Setup webhooks
+	Webhooks.Secret, err = loadWebhookSecret()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// In development the webhooks go to the test receiver, unless other endpoints are given
+	var webhookReceiver *webhooks.TestReceiver
+	if InDevMode {
+		if Webhooks.Secret == "" {
+			Webhooks.Secret = "dev-secret"
+		}
+		webhookReceiver = webhooks.NewTestReceiver(Webhooks.Secret)
+		if len(Webhooks.Endpoints) == 0 {
+			Webhooks.Endpoints = []string{fmt.Sprintf("http://localhost:%d/webhooks/test", Port)}
+		}
+	}
+	if len(Webhooks.Endpoints) > 0 && Webhooks.Secret == "" {
+		log.Fatal("-webhook-secret-file or " + webhookSecretEnv + " is required to sign the webhooks")
+	}
+	webhookDispatcher := webhooks.NewDispatcher(Webhooks)
+	webhookDispatcher.Start()
+
 	// Setup lobbies Service
 	lobbiesRepo := lobbies.NewRepositoryInMemory()
-	lobbiesService := lobbies.NewService(lobbiesRepo, pastGamesRepo, quizRepo)
+	lobbiesService := lobbies.NewService(lobbiesRepo, pastGamesRepo, quizRepo).WithWebhooks(webhookDispatcher)
+	go lobbiesService.RunReaper(ctx, LobbyReaper)
+
+	// Setup assignments Service
+	assignmentsRepo, err := assignments.NewRepositorySQLite(db, quizRepo)
+	if err != nil {
+		slog.Error("failed to set up assignments repo", "err", err)
+		panic(err)
+	}
+	assignmentsService := assignments.NewService(assignmentsRepo, pastGamesRepo, quizRepo)
+	if err := assignmentsService.ScheduleOpenAssignments(); err != nil {
+		slog.Error("failed to schedule open assignments", "err", err)
+		panic(err)
+	}
 
 	// Set up routes
 	router := http.NewServeMux()
@@ -94,6 +262,16 @@ func main() {
 	router.Handle("/quizzes/", quizService.NewQuizzesRouter())
 	router.Handle("/lobbies/", lobbiesService.NewLobbiesRouter())
 	router.Handle("/past-games/", pastGamesService.NewPastGamesRouter())
+	router.Handle("/assignments/", assignmentsService.NewAssignmentsRouter())
+	// Only the counters of the app are public, not the command line and the memory stats expvar publishes too
+	router.Handle("GET /debug/vars", metricsHandler("lobbies_reaper", "pastgames_retention"))
+	if webhookReceiver != nil {
+		router.Handle("/webhooks/test", webhookReceiver)
+	}
+	if InDevMode {
+		// Line items for trying out the grade passback, e.g. http://localhost:3000/lti/stub/quiz-1
+		router.Handle("/lti/stub/", http.StripPrefix("/lti/stub/", pastgames.NewLTIStub()))
+	}
 	router.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
 		if err := common.IndexTmpl.Execute(w, nil); err != nil {
 			slog.Error("Error rendering template", "error", err)
@@ -121,7 +299,7 @@ func main() {
 		// Lobbies
 		slog.Debug("Adding example lobbies")
 		for _, example := range lobbies.GetExamples() {
-			err := lobbiesRepo.AddLobby(example)
+			err := lobbiesService.AddLobby(example)
 			if err != nil {
 				slog.Error("Failed to add example lobbies", "err", err)
 			}
@@ -131,10 +309,36 @@ func main() {
 
 	// Start server
 	addr := fmt.Sprintf(":%d", Port)
-	slog.Info("Server listening", "addr", addr)
+	server := &http.Server{
+		Addr:    addr,
+		Handler: loggingMiddleware(router),
+	}
+	go func() {
+		slog.Info("Server listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server stopped", "err", err)
+			stop()
+		}
+	}()
 
-	err = http.ListenAndServe(addr, loggingMiddleware(router))
-	if err != nil {
-		slog.Error("Server shutting down", "err", err.Error())
+	<-ctx.Done()
+	slog.Info("Shutting down", "timeout", ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	// Lobbies are closed first, websockets are hijacked connections the server doesn't wait for
+	if err := lobbiesService.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error closing lobbies", "err", err)
+	}
+	// The games saved while closing the lobbies are still reported, the test receiver needs the server for it
+	if err := webhookDispatcher.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error delivering the remaining webhooks", "err", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down the server", "err", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing the database", "err", err)
 	}
+	slog.Info("Server shut down")
 }